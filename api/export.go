@@ -0,0 +1,273 @@
+package api
+
+import (
+	"blockbook/bchain"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+)
+
+// Supported formats of the export of address history
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+var exportCSVHeader = []string{"address", "txid", "blockheight", "blocktime", "direction", "received", "sent", "amount", "fees", "balance"}
+
+// exportWriter writes the exported transactions one by one in the given format
+type exportWriter interface {
+	write(et *ExportedTx) error
+	flush() error
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) write(et *ExportedTx) error {
+	return e.w.Write([]string{
+		et.Address,
+		et.Txid,
+		strconv.Itoa(et.Blockheight),
+		strconv.FormatInt(et.Blocktime, 10),
+		et.Direction,
+		et.Received,
+		et.Sent,
+		et.Amount,
+		et.Fees,
+		et.Balance,
+	})
+}
+
+func (e *csvExportWriter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlExportWriter struct {
+	e *json.Encoder
+}
+
+func (e *jsonlExportWriter) write(et *ExportedTx) error {
+	return e.e.Encode(et)
+}
+
+func (e *jsonlExportWriter) flush() error {
+	return nil
+}
+
+// IsExportFormat returns true if format is a supported export format
+func IsExportFormat(format string) bool {
+	return format == ExportFormatCSV || format == ExportFormatJSONL
+}
+
+// ExportOptions restricts the exported transactions, zero values do not restrict the export
+type ExportOptions struct {
+	// FromTime and ToTime are the unix times in seconds of the blocks of the exported transactions
+	FromTime, ToTime int64
+	// FromHeight and ToHeight are the heights of the blocks of the exported transactions
+	FromHeight, ToHeight uint32
+	// MaxTxs is the maximal number of the exported transactions of all addresses
+	MaxTxs int
+}
+
+// flusher is implemented by the outputs which buffer the written data, e.g. http.ResponseWriter
+type flusher interface {
+	Flush()
+}
+
+// errExportLimit stops the export when the maximal number of transactions is reached
+var errExportLimit = errors.New("Export limit reached")
+
+// ExportAddressHistory writes confirmed transactions of the addresses to w in the given format, one row per address and transaction
+// only the transactions in blocks in the height range and mined in the time range of opts are written, at most opts.MaxTxs of them
+// the running balance starts with the balance of the address before the first block of the range
+// the transactions are streamed as they are read from the db, they are not kept in memory
+func (w *Worker) ExportAddressHistory(addresses []string, opts *ExportOptions, format string, out io.Writer) error {
+	var ew exportWriter
+	switch format {
+	case ExportFormatCSV:
+		cw := csv.NewWriter(out)
+		if err := cw.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		ew = &csvExportWriter{w: cw}
+	case ExportFormatJSONL:
+		ew = &jsonlExportWriter{e: json.NewEncoder(out)}
+	default:
+		return errors.Errorf("Unsupported export format %v", format)
+	}
+	if f, ok := out.(flusher); ok {
+		ew = &flushingExportWriter{exportWriter: ew, f: f}
+	}
+	lower, higher, inRange, err := w.db.GetHeightRangeForTime(opts.FromTime, opts.ToTime)
+	if err != nil {
+		return err
+	}
+	if opts.FromHeight > lower {
+		lower = opts.FromHeight
+	}
+	if opts.ToHeight != 0 && opts.ToHeight < higher {
+		higher = opts.ToHeight
+	}
+	if inRange && lower <= higher {
+		bestheight, _, err := w.db.GetBestBlock()
+		if err != nil {
+			return err
+		}
+		e := &addressExporter{
+			parser:   w.chainParser,
+			getTxids: w.db.GetTransactions,
+			getTx: func(txid string) (*Tx, error) {
+				return w.GetTransaction(txid, bestheight, false)
+			},
+			ew: ew,
+		}
+		if err = e.exportAddresses(addresses, lower, higher, opts.MaxTxs); err != nil {
+			return err
+		}
+	}
+	return ew.flush()
+}
+
+// flushingExportWriter flushes the output after the written transactions are flushed by exportWriter
+type flushingExportWriter struct {
+	exportWriter
+	f flusher
+}
+
+func (e *flushingExportWriter) flush() error {
+	if err := e.exportWriter.flush(); err != nil {
+		return err
+	}
+	e.f.Flush()
+	return nil
+}
+
+// addressExporter writes the transactions of the addresses to ew,
+// the outpoints of the addresses are read by getTxids (RocksDB.GetTransactions) and the transactions by getTx
+type addressExporter struct {
+	parser   bchain.BlockChainParser
+	getTxids func(address string, lower, higher uint32, fn func(txid string, vout uint32, isOutput bool) error) error
+	getTx    func(txid string) (*Tx, error)
+	ew       exportWriter
+}
+
+// exportAddresses writes transactions of the addresses in the height range lower-higher, at most maxTxs of them if maxTxs>0
+func (e *addressExporter) exportAddresses(addresses []string, lower, higher uint32, maxTxs int) error {
+	remaining := maxTxs
+	for _, address := range addresses {
+		rows, err := e.exportAddress(address, lower, higher, remaining)
+		if err == errExportLimit {
+			glog.Info("export stopped after ", maxTxs, " transactions")
+			return nil
+		}
+		if err != nil {
+			return errors.Annotatef(err, "address %v", address)
+		}
+		remaining -= rows
+	}
+	return nil
+}
+
+// forEachTx passes the transactions of the address in the height range lower-higher to fn from the oldest, each transaction once
+func (e *addressExporter) forEachTx(address string, lower, higher uint32, fn func(tx *Tx) error) error {
+	// the outputs and inputs of a transaction are indexed separately, the transaction may be returned multiple times within its block
+	height := -1
+	seen := make(map[string]struct{})
+	return e.getTxids(address, lower, higher, func(txid string, vout uint32, isOutput bool) error {
+		if _, found := seen[txid]; found {
+			return nil
+		}
+		tx, err := e.getTx(txid)
+		if err != nil {
+			return err
+		}
+		if tx.Blockheight != height {
+			height = tx.Blockheight
+			seen = make(map[string]struct{})
+		}
+		seen[txid] = struct{}{}
+		return fn(tx)
+	})
+}
+
+// openingBalance returns the balance of the address computed from its transactions in the blocks below lower
+func (e *addressExporter) openingBalance(address string, lower uint32) (*big.Int, error) {
+	var balance big.Int
+	if lower == 0 {
+		return &balance, nil
+	}
+	err := e.forEachTx(address, 0, lower-1, func(tx *Tx) error {
+		balance.Add(&balance, tx.getAddrVoutValue(address))
+		balance.Sub(&balance, tx.getAddrVinValue(address))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// exportAddress writes transactions of one address in the height range lower-higher from the oldest, at most maxTxs of them if maxTxs>0
+// returns the number of written transactions, errExportLimit if the export was stopped by maxTxs
+func (e *addressExporter) exportAddress(address string, lower, higher uint32, maxTxs int) (int, error) {
+	glog.Info("export ", address, " start")
+	balance, err := e.openingBalance(address, lower)
+	if err != nil {
+		return 0, err
+	}
+	rows := 0
+	err = e.forEachTx(address, lower, higher, func(tx *Tx) error {
+		received := tx.getAddrVoutValue(address)
+		sent := tx.getAddrVinValue(address)
+		var amount big.Int
+		amount.Sub(received, sent)
+		balance.Add(balance, &amount)
+		direction := "in"
+		if amount.Sign() < 0 {
+			direction = "out"
+		}
+		fees := tx.FeesSat
+		if fees == nil {
+			fees = new(big.Int)
+		}
+		bal := new(big.Int).Set(balance)
+		if err := e.ew.write(&ExportedTx{
+			Address:     address,
+			Txid:        tx.Txid,
+			Blockheight: tx.Blockheight,
+			Blocktime:   tx.Blocktime,
+			Direction:   direction,
+			Received:    e.parser.AmountToDecimalString(received),
+			ReceivedSat: received,
+			Sent:        e.parser.AmountToDecimalString(sent),
+			SentSat:     sent,
+			Amount:      e.parser.AmountToDecimalString(&amount),
+			AmountSat:   &amount,
+			Fees:        e.parser.AmountToDecimalString(fees),
+			FeesSat:     fees,
+			Balance:     e.parser.AmountToDecimalString(bal),
+			BalanceSat:  bal,
+		}); err != nil {
+			return err
+		}
+		rows++
+		if maxTxs > 0 && rows >= maxTxs {
+			return errExportLimit
+		}
+		// flush regularly so that the output is streamed
+		if rows%100 == 0 {
+			return e.ew.flush()
+		}
+		return nil
+	})
+	glog.Info("export ", address, " finished, ", rows, " transactions")
+	return rows, err
+}
@@ -0,0 +1,165 @@
+// +build unittest
+
+package api
+
+import (
+	"blockbook/bchain/coins/btc"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+type exportTestTxRef struct {
+	address string
+	txid    string
+	height  uint32
+}
+
+// exportTestTxs are the transactions of the addresses A and B, the transaction a3 is indexed twice (output and input)
+var exportTestTxs = map[string]*Tx{
+	"a1": {Txid: "a1", Blockheight: 1, Blocktime: 1001, Vout: []Vout{exportTestVout("A", 100)}},
+	"a2": {Txid: "a2", Blockheight: 2, Blocktime: 1002, FeesSat: big.NewInt(5), Vin: []Vin{exportTestVin("A", 30)}, Vout: []Vout{exportTestVout("C", 25)}},
+	"a3": {Txid: "a3", Blockheight: 3, Blocktime: 1003, Vin: []Vin{exportTestVin("A", 10)}, Vout: []Vout{exportTestVout("A", 60)}},
+	"a4": {Txid: "a4", Blockheight: 4, Blocktime: 1004, Vout: []Vout{exportTestVout("A", 10), exportTestVout("B", 7)}},
+	"b5": {Txid: "b5", Blockheight: 5, Blocktime: 1005, Vout: []Vout{exportTestVout("B", 3)}},
+}
+
+var exportTestRefs = []exportTestTxRef{
+	{"A", "a1", 1}, {"A", "a2", 2}, {"A", "a3", 3}, {"A", "a3", 3}, {"A", "a4", 4},
+	{"B", "a4", 4}, {"B", "b5", 5},
+}
+
+func exportTestVout(address string, value int64) Vout {
+	v := Vout{ValueSat: big.NewInt(value)}
+	v.ScriptPubKey.Addresses = []string{address}
+	return v
+}
+
+func exportTestVin(address string, value int64) Vin {
+	return Vin{Addr: address, ValueSat: big.NewInt(value)}
+}
+
+func newTestAddressExporter(ew exportWriter) *addressExporter {
+	return &addressExporter{
+		parser: btc.NewBitcoinParser(btc.GetChainParams("test"), &btc.Configuration{}),
+		getTxids: func(address string, lower, higher uint32, fn func(txid string, vout uint32, isOutput bool) error) error {
+			for _, r := range exportTestRefs {
+				if r.address == address && r.height >= lower && r.height <= higher {
+					if err := fn(r.txid, 0, true); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		getTx: func(txid string) (*Tx, error) {
+			tx, found := exportTestTxs[txid]
+			if !found {
+				return nil, errors.Errorf("tx %v not found", txid)
+			}
+			return tx, nil
+		},
+		ew: ew,
+	}
+}
+
+type exportTestRow struct {
+	address string
+	txid    string
+	amount  int64
+	balance int64
+}
+
+func Test_addressExporter_exportAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		lower     uint32
+		higher    uint32
+		maxTxs    int
+		want      []exportTestRow
+	}{
+		{
+			name:      "all transactions",
+			addresses: []string{"A", "B"},
+			higher:    10,
+			want: []exportTestRow{
+				{"A", "a1", 100, 100}, {"A", "a2", -30, 70}, {"A", "a3", 50, 120}, {"A", "a4", 10, 130},
+				{"B", "a4", 7, 7}, {"B", "b5", 3, 10},
+			},
+		},
+		{
+			name:      "balance before the range",
+			addresses: []string{"A", "B"},
+			lower:     3,
+			higher:    4,
+			want:      []exportTestRow{{"A", "a3", 50, 120}, {"A", "a4", 10, 130}, {"B", "a4", 7, 7}},
+		},
+		{
+			name:      "limit within address",
+			addresses: []string{"A", "B"},
+			lower:     2,
+			higher:    10,
+			maxTxs:    2,
+			want:      []exportTestRow{{"A", "a2", -30, 70}, {"A", "a3", 50, 120}},
+		},
+		{
+			name:      "limit across addresses",
+			addresses: []string{"A", "B"},
+			higher:    10,
+			maxTxs:    5,
+			want: []exportTestRow{
+				{"A", "a1", 100, 100}, {"A", "a2", -30, 70}, {"A", "a3", 50, 120}, {"A", "a4", 10, 130},
+				{"B", "a4", 7, 7},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := newTestAddressExporter(&jsonlExportWriter{e: json.NewEncoder(&buf)})
+			if err := e.exportAddresses(tt.addresses, tt.lower, tt.higher, tt.maxTxs); err != nil {
+				t.Fatal(err)
+			}
+			var got []exportTestRow
+			d := json.NewDecoder(&buf)
+			for d.More() {
+				var et ExportedTx
+				if err := d.Decode(&et); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, exportTestRow{et.Address, et.Txid, et.AmountSat.Int64(), et.BalanceSat.Int64()})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exportAddresses() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csvExportWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(exportCSVHeader); err != nil {
+		t.Fatal(err)
+	}
+	cw := &csvExportWriter{w: w}
+	e := newTestAddressExporter(cw)
+	if err := e.exportAddresses([]string{"A"}, 2, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join(exportCSVHeader, ",") + "\n" +
+		"A,a2,2,1002,out,0,0.0000003,-0.0000003,0.00000005,0.0000007\n"
+	if got := buf.String(); got != want {
+		t.Errorf("csv export = %q, want %q", got, want)
+	}
+}
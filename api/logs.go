@@ -0,0 +1,77 @@
+package api
+
+import (
+	"blockbook/bchain"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const logsOnPage = 100
+
+// GetContractLogs returns the event logs of the contract in blocks fromHeight-toHeight matching the topics, from the oldest
+// empty topic matches any value, toHeight 0 means the best block, the logs are paged by logsOnPage,
+// the total number of the logs is not known, the scan of the index stops at the end of the page
+func (w *Worker) GetContractLogs(contract string, topics []string, fromHeight, toHeight uint32, page int) (*ContractLogs, error) {
+	if !w.chainParser.IndexContractLogs() {
+		return nil, errors.New("Index of contract logs is not enabled")
+	}
+	contract = strings.ToLower(contract)
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if toHeight == 0 || toHeight > bestheight {
+		toHeight = bestheight
+	}
+	if fromHeight > toHeight {
+		return nil, errors.Errorf("Invalid block range %d-%d", fromHeight, toHeight)
+	}
+	if page < 0 {
+		page = 0
+	}
+	// one log more than the page is requested to find out if there is a next page
+	refs, err := w.db.GetContractLogs(contract, topics, fromHeight, toHeight, page*logsOnPage, logsOnPage+1)
+	if err != nil {
+		return nil, err
+	}
+	hasNextPage := len(refs) > logsOnPage
+	if hasNextPage {
+		refs = refs[:logsOnPage]
+	}
+	logs := make([]ContractLog, 0, len(refs))
+	// the logs of one transaction are usually on the same page, parse each transaction only once
+	txLogs := make(map[string][]bchain.ContractLog)
+	for _, ref := range refs {
+		tl, found := txLogs[ref.Txid]
+		if !found {
+			tx, _, err := w.txCache.GetTransaction(ref.Txid, bestheight)
+			if err != nil {
+				return nil, errors.Annotatef(err, "txid %v", ref.Txid)
+			}
+			if tl, err = w.chainParser.GetContractLogsFromTx(tx); err != nil {
+				return nil, errors.Annotatef(err, "txid %v", ref.Txid)
+			}
+			txLogs[ref.Txid] = tl
+		}
+		if int(ref.LogIndex) >= len(tl) {
+			return nil, errors.Errorf("Log %d of txid %v not found", ref.LogIndex, ref.Txid)
+		}
+		l := &tl[ref.LogIndex]
+		logs = append(logs, ContractLog{
+			Txid:        ref.Txid,
+			Blockheight: ref.Height,
+			LogIndex:    ref.LogIndex,
+			Topics:      l.Topics,
+			Data:        l.Data,
+		})
+	}
+	return &ContractLogs{
+		Contract:    contract,
+		FromHeight:  fromHeight,
+		ToHeight:    toHeight,
+		Page:        page,
+		HasNextPage: hasNextPage,
+		Logs:        logs,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"blockbook/bchain"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// getTokenTransfers returns the ERC20 token transfers of the transaction, the chains without tokens return none
+func (w *Worker) getTokenTransfers(tx *bchain.Tx) ([]TokenTransfer, error) {
+	transfers, err := w.chainParser.GetErc20TransfersFromTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return nil, nil
+	}
+	r := make([]TokenTransfer, len(transfers))
+	for i := range transfers {
+		t := &transfers[i]
+		r[i] = TokenTransfer{
+			Contract: t.Contract,
+			From:     t.From,
+			To:       t.To,
+			Value:    t.Tokens.String(),
+		}
+	}
+	return r, nil
+}
+
+// getTokenBalances returns the contracts with which the address has token transfers, with the current balance of the address
+// the balance is read from the backend, if it cannot be read, it is left empty
+func (w *Worker) getTokenBalances(address string) ([]TokenBalance, error) {
+	addrID, err := w.chainParser.GetAddrIDFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	contracts, err := w.db.GetAddrIDErc20Contracts(addrID)
+	if err != nil {
+		return nil, err
+	}
+	if len(contracts) == 0 {
+		return nil, nil
+	}
+	r := make([]TokenBalance, len(contracts))
+	for i, c := range contracts {
+		r[i].Contract = c.Contract
+		r[i].TxApperances = c.Txs
+		b, err := w.chain.GetErc20ContractBalance(address, c.Contract)
+		if err != nil {
+			glog.Error("GetErc20ContractBalance ", address, ", ", c.Contract, ": ", err)
+			continue
+		}
+		r[i].Balance = b.String()
+	}
+	return r, nil
+}
+
+// GetAddressTokenTransfers returns the transactions with transfers of tokens of the contract from or to the address, from the newest
+// the transactions are paged by txsOnPage
+func (w *Worker) GetAddressTokenTransfers(address, contract string, page int) (*TokenTransfers, error) {
+	addrID, err := w.chainParser.GetAddrIDFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	contractID, err := w.chainParser.GetAddrIDFromAddress(strings.ToLower(contract))
+	if err != nil {
+		return nil, err
+	}
+	txids := make([]string, 0)
+	err = w.db.GetAddrIDErc20Transactions(addrID, contractID, 0, ^uint32(0), func(txid string, height uint32, vout uint32, isOutput bool) error {
+		txids = append(txids, txid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	txids = UniqueTxidsInReverse(txids)
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	totalPages := (len(txids) + txsOnPage - 1) / txsOnPage
+	if page < 0 || page >= totalPages {
+		page = 0
+	}
+	from := page * txsOnPage
+	to := from + txsOnPage
+	if to > len(txids) {
+		to = len(txids)
+	}
+	txs := make([]*Tx, 0, to-from)
+	for _, txid := range txids[from:to] {
+		tx, err := w.GetTransaction(txid, bestheight, false)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return &TokenTransfers{
+		AddrStr:      address,
+		Contract:     strings.ToLower(contract),
+		TxApperances: len(txids),
+		Page:         page,
+		TotalPages:   totalPages,
+		Transactions: txs,
+	}, nil
+}
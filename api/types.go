@@ -1,5 +1,7 @@
 package api
 
+import "math/big"
+
 type ScriptSig struct {
 	Hex string `json:"hex"`
 	Asm string `json:"asm,omitempty"`
@@ -12,8 +14,8 @@ type Vin struct {
 	N         int       `json:"n"`
 	ScriptSig ScriptSig `json:"scriptSig"`
 	Addr      string    `json:"addr"`
-	ValueSat  int64     `json:"valueSat"`
-	Value     float64   `json:"value"`
+	ValueSat  *big.Int  `json:"valueSat"`
+	Value     string    `json:"value"`
 }
 
 type ScriptPubKey struct {
@@ -23,7 +25,8 @@ type ScriptPubKey struct {
 	Type      string   `json:"type,omitempty"`
 }
 type Vout struct {
-	Value        float64      `json:"value"`
+	ValueSat     *big.Int     `json:"valueSat"`
+	Value        string       `json:"value"`
 	N            int          `json:"n"`
 	ScriptPubKey ScriptPubKey `json:"scriptPubKey"`
 	SpentTxID    string       `json:"spentTxId,omitempty"`
@@ -31,35 +34,204 @@ type Vout struct {
 	SpentHeight  int          `json:"spentHeight,omitempty"`
 }
 
+type TokenTransfer struct {
+	Contract string `json:"contract"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+}
+
+type InternalTransfer struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Value    string   `json:"value"`
+	ValueSat *big.Int `json:"valueSat"`
+}
+
+type TxReceipt struct {
+	Status            int      `json:"status"`
+	GasLimit          *big.Int `json:"gasLimit"`
+	GasUsed           *big.Int `json:"gasUsed"`
+	CumulativeGasUsed *big.Int `json:"cumulativeGasUsed"`
+	GasPriceSat       *big.Int `json:"gasPriceSat"`
+	GasPrice          string   `json:"gasPrice"`
+	ContractAddress   string   `json:"contractAddress,omitempty"`
+}
+
 type Tx struct {
-	Txid          string  `json:"txid"`
-	Version       int32   `json:"version,omitempty"`
-	Locktime      uint32  `json:"locktime,omitempty"`
-	Vin           []Vin   `json:"vin"`
-	Vout          []Vout  `json:"vout"`
-	Blockhash     string  `json:"blockhash,omitempty"`
-	Blockheight   int     `json:"blockheight"`
-	Confirmations uint32  `json:"confirmations"`
-	Time          int64   `json:"time,omitempty"`
-	Blocktime     int64   `json:"blocktime"`
-	ValueOut      float64 `json:"valueOut"`
-	Size          int     `json:"size,omitempty"`
-	ValueIn       float64 `json:"valueIn"`
-	Fees          float64 `json:"fees"`
-	WithSpends    bool    `json:"withSpends,omitempty"`
+	Txid              string             `json:"txid"`
+	Version           int32              `json:"version,omitempty"`
+	Locktime          uint32             `json:"locktime,omitempty"`
+	Vin               []Vin              `json:"vin"`
+	Vout              []Vout             `json:"vout"`
+	Blockhash         string             `json:"blockhash,omitempty"`
+	Blockheight       int                `json:"blockheight"`
+	Confirmations     uint32             `json:"confirmations"`
+	Time              int64              `json:"time,omitempty"`
+	Blocktime         int64              `json:"blocktime"`
+	ValueOutSat       *big.Int           `json:"valueOutSat"`
+	ValueOut          string             `json:"valueOut"`
+	Size              int                `json:"size,omitempty"`
+	ValueInSat        *big.Int           `json:"valueInSat"`
+	ValueIn           string             `json:"valueIn"`
+	FeesSat           *big.Int           `json:"feesSat"`
+	Fees              string             `json:"fees"`
+	WithSpends        bool               `json:"withSpends,omitempty"`
+	TokenTransfers    []TokenTransfer    `json:"tokenTransfers,omitempty"`
+	InternalTransfers []InternalTransfer `json:"internalTransfers,omitempty"`
+	Receipt           *TxReceipt         `json:"receipt,omitempty"`
+	ReplacedBy        string             `json:"replacedBy,omitempty"`
+}
+
+type TokenBalance struct {
+	Contract     string `json:"contract"`
+	Balance      string `json:"balance"`
+	TxApperances int    `json:"txApperances"`
 }
 
 type Address struct {
-	AddrStr                 string  `json:"addrStr"`
-	Balance                 float64 `json:"balance"`
-	BalanceSat              int64   `json:"balanceSat"`
-	TotalReceived           float64 `json:"totalReceived"`
-	TotalReceivedSat        int64   `json:"totalReceivedSat"`
-	TotalSent               float64 `json:"totalSent"`
-	TotalSentSat            int64   `json:"totalSentSat"`
-	UnconfirmedBalance      float64 `json:"unconfirmedBalance"`
-	UnconfirmedBalanceSat   int64   `json:"unconfirmedBalanceSat"`
-	UnconfirmedTxApperances int     `json:"unconfirmedTxApperances"`
-	TxApperances            int     `json:"txApperances"`
-	Transactions            []*Tx   `json:"transactions"`
+	AddrStr                 string         `json:"addrStr"`
+	Balance                 string         `json:"balance"`
+	BalanceSat              *big.Int       `json:"balanceSat"`
+	TotalReceived           string         `json:"totalReceived,omitempty"`
+	TotalReceivedSat        *big.Int       `json:"totalReceivedSat,omitempty"`
+	TotalSent               string         `json:"totalSent,omitempty"`
+	TotalSentSat            *big.Int       `json:"totalSentSat,omitempty"`
+	UnconfirmedBalance      string         `json:"unconfirmedBalance"`
+	UnconfirmedBalanceSat   *big.Int       `json:"unconfirmedBalanceSat"`
+	UnconfirmedTxApperances int            `json:"unconfirmedTxApperances"`
+	TxApperances            int            `json:"txApperances"`
+	Transactions            []*Tx          `json:"transactions"`
+	Tokens                  []TokenBalance `json:"tokens,omitempty"`
+	Nonce                   string         `json:"nonce,omitempty"`
+}
+
+type AccountState struct {
+	AddrStr    string   `json:"addrStr"`
+	Height     uint32   `json:"height"`
+	Balance    string   `json:"balance"`
+	BalanceSat *big.Int `json:"balanceSat"`
+	Nonce      string   `json:"nonce"`
+}
+
+type TokenTransfers struct {
+	AddrStr      string `json:"addrStr"`
+	Contract     string `json:"contract"`
+	TxApperances int    `json:"txApperances"`
+	Page         int    `json:"page"`
+	TotalPages   int    `json:"totalPages"`
+	Transactions []*Tx  `json:"transactions"`
+}
+
+type MempoolFeeRateBucket struct {
+	FeeRate int64 `json:"feeRate"`
+	Txs     int   `json:"txs"`
+	Vsize   int64 `json:"vsize"`
+}
+
+type MempoolStats struct {
+	Txs              int                    `json:"txs"`
+	Vsize            int64                  `json:"vsize"`
+	TotalFee         string                 `json:"totalFee"`
+	TotalFeeSat      *big.Int               `json:"totalFeeSat"`
+	FeeRateHistogram []MempoolFeeRateBucket `json:"feeRateHistogram"`
+}
+
+type FeeLevels struct {
+	Blocks   int      `json:"blocks"`
+	Slow     *big.Int `json:"slow"`
+	Standard *big.Int `json:"standard"`
+	Fast     *big.Int `json:"fast"`
+}
+
+type ContractLog struct {
+	Txid        string   `json:"txid"`
+	Blockheight uint32   `json:"blockheight"`
+	LogIndex    uint32   `json:"logIndex"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+}
+
+type ContractLogs struct {
+	Contract    string        `json:"contract"`
+	FromHeight  uint32        `json:"fromHeight"`
+	ToHeight    uint32        `json:"toHeight"`
+	Page        int           `json:"page"`
+	HasNextPage bool          `json:"hasNextPage"`
+	Logs        []ContractLog `json:"logs"`
+}
+
+type AddressUtxo struct {
+	Txid          string   `json:"txid"`
+	Vout          uint32   `json:"vout"`
+	AmountSat     *big.Int `json:"satoshis"`
+	Amount        string   `json:"amount"`
+	Height        int      `json:"height,omitempty"`
+	Confirmations int      `json:"confirmations"`
+	AddrStr       string   `json:"address,omitempty"`
+	Path          string   `json:"path,omitempty"`
+}
+
+type XpubAddress struct {
+	AddrStr                 string   `json:"addrStr"`
+	Path                    string   `json:"path"`
+	Balance                 string   `json:"balance"`
+	BalanceSat              *big.Int `json:"balanceSat"`
+	TotalReceived           string   `json:"totalReceived"`
+	TotalReceivedSat        *big.Int `json:"totalReceivedSat"`
+	TotalSent               string   `json:"totalSent"`
+	TotalSentSat            *big.Int `json:"totalSentSat"`
+	UnconfirmedTxApperances int      `json:"unconfirmedTxApperances"`
+	TxApperances            int      `json:"txApperances"`
+}
+
+type Xpub struct {
+	Xpub                    string        `json:"xpub"`
+	Balance                 string        `json:"balance"`
+	BalanceSat              *big.Int      `json:"balanceSat"`
+	TotalReceived           string        `json:"totalReceived"`
+	TotalReceivedSat        *big.Int      `json:"totalReceivedSat"`
+	TotalSent               string        `json:"totalSent"`
+	TotalSentSat            *big.Int      `json:"totalSentSat"`
+	UnconfirmedBalance      string        `json:"unconfirmedBalance"`
+	UnconfirmedBalanceSat   *big.Int      `json:"unconfirmedBalanceSat"`
+	UnconfirmedTxApperances int           `json:"unconfirmedTxApperances"`
+	TxApperances            int           `json:"txApperances"`
+	Addresses               []XpubAddress `json:"addresses"`
+	Utxos                   []AddressUtxo `json:"utxos"`
+	Transactions            []*Tx         `json:"transactions"`
+}
+
+type Block struct {
+	Hash          string `json:"hash"`
+	Prev          string `json:"previousBlockHash,omitempty"`
+	Next          string `json:"nextBlockHash,omitempty"`
+	Height        uint32 `json:"height"`
+	Confirmations int    `json:"confirmations"`
+	Size          int    `json:"size"`
+	Time          int64  `json:"time,omitempty"`
+	TxCount       int    `json:"txCount"`
+	Page          int    `json:"page"`
+	TotalPages    int    `json:"totalPages"`
+	Transactions  []*Tx  `json:"transactions"`
+}
+
+// ExportedTx is one row of the export of the address history, the amounts are in coins and in satoshi (the Sat fields),
+// Amount is Received minus Sent, Balance is the running balance of the address after the transaction
+type ExportedTx struct {
+	Address     string   `json:"address"`
+	Txid        string   `json:"txid"`
+	Blockheight int      `json:"blockheight"`
+	Blocktime   int64    `json:"blocktime"`
+	Direction   string   `json:"direction"`
+	Received    string   `json:"received"`
+	ReceivedSat *big.Int `json:"receivedSat"`
+	Sent        string   `json:"sent"`
+	SentSat     *big.Int `json:"sentSat"`
+	Amount      string   `json:"amount"`
+	AmountSat   *big.Int `json:"amountSat"`
+	Fees        string   `json:"fees"`
+	FeesSat     *big.Int `json:"feesSat"`
+	Balance     string   `json:"balance"`
+	BalanceSat  *big.Int `json:"balanceSat"`
 }
@@ -4,8 +4,12 @@ import (
 	"blockbook/bchain"
 	"blockbook/common"
 	"blockbook/db"
+	"bytes"
+	"math/big"
+	"strconv"
 
 	"github.com/golang/glog"
+	"github.com/juju/errors"
 )
 
 const txsOnPage = 30
@@ -35,6 +39,10 @@ func NewWorker(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCache, is
 func (w *Worker) GetTransaction(txid string, bestheight uint32, spendingTx bool) (*Tx, error) {
 	bchainTx, height, err := w.txCache.GetTransaction(txid, bestheight)
 	if err != nil {
+		// the transaction replaced in mempool is not available any more, return at least its replacement
+		if replacedBy, e := w.chain.GetMempoolReplacedBy(txid); e == nil && replacedBy != "" {
+			return &Tx{Txid: txid, ReplacedBy: replacedBy}, nil
+		}
 		return nil, err
 	}
 	var blockhash string
@@ -44,7 +52,7 @@ func (w *Worker) GetTransaction(txid string, bestheight uint32, spendingTx bool)
 			return nil, err
 		}
 	}
-	var valIn, valOut, fees float64
+	var valInSat, valOutSat, feesSat big.Int
 	vins := make([]Vin, len(bchainTx.Vin))
 	for i := range bchainTx.Vin {
 		bchainVin := &bchainTx.Vin[i]
@@ -61,9 +69,9 @@ func (w *Worker) GetTransaction(txid string, bestheight uint32, spendingTx bool)
 			}
 			if len(otx.Vout) > int(vin.Vout) {
 				vout := &otx.Vout[vin.Vout]
-				vin.Value = vout.Value
-				valIn += vout.Value
-				vin.ValueSat = int64(vout.Value*1E8 + 0.5)
+				vin.ValueSat = &vout.ValueSat
+				vin.Value = w.chainParser.AmountToDecimalString(&vout.ValueSat)
+				valInSat.Add(&valInSat, &vout.ValueSat)
 				if vout.Address != nil {
 					a := vout.Address.String()
 					vin.Addr = a
@@ -76,81 +84,171 @@ func (w *Worker) GetTransaction(txid string, bestheight uint32, spendingTx bool)
 		bchainVout := &bchainTx.Vout[i]
 		vout := &vouts[i]
 		vout.N = i
-		vout.Value = bchainVout.Value
-		valOut += bchainVout.Value
+		vout.ValueSat = &bchainVout.ValueSat
+		vout.Value = w.chainParser.AmountToDecimalString(&bchainVout.ValueSat)
+		valOutSat.Add(&valOutSat, &bchainVout.ValueSat)
 		vout.ScriptPubKey.Hex = bchainVout.ScriptPubKey.Hex
 		vout.ScriptPubKey.Addresses = bchainVout.ScriptPubKey.Addresses
 		if spendingTx {
-			// TODO
+			if err = w.setSpendingTx(vout, bchainTx.Txid, uint32(i)); err != nil {
+				return nil, err
+			}
 		}
 	}
-	// for coinbase transactions valIn is 0
-	fees = valIn - valOut
-	if fees < 0 {
-		fees = 0
+	tokenTransfers, err := w.getTokenTransfers(bchainTx)
+	if err != nil {
+		return nil, err
+	}
+	internalTransfers, err := w.getInternalTransfers(bchainTx)
+	if err != nil {
+		return nil, err
+	}
+	bchainReceipt, err := w.chainParser.GetTxReceipt(bchainTx)
+	if err != nil {
+		return nil, err
+	}
+	var receipt *TxReceipt
+	if bchainReceipt != nil {
+		receipt = &TxReceipt{
+			Status:            int(bchainReceipt.Status),
+			GasLimit:          &bchainReceipt.GasLimit,
+			GasUsed:           &bchainReceipt.GasUsed,
+			CumulativeGasUsed: &bchainReceipt.CumulativeGasUsed,
+			GasPriceSat:       &bchainReceipt.GasPrice,
+			GasPrice:          w.chainParser.AmountToDecimalString(&bchainReceipt.GasPrice),
+			ContractAddress:   bchainReceipt.ContractAddress,
+		}
+		// the fee is paid for the gas used, also by failed transactions
+		feesSat.Mul(&bchainReceipt.GasUsed, &bchainReceipt.GasPrice)
+	} else {
+		// for coinbase transactions valIn is 0
+		feesSat.Sub(&valInSat, &valOutSat)
+		if feesSat.Sign() == -1 {
+			feesSat.SetUint64(0)
+		}
 	}
 	// for now do not return size, we would have to compute vsize of segwit transactions
 	// size:=len(bchainTx.Hex) / 2
 	r := &Tx{
-		Blockhash:     blockhash,
-		Blockheight:   int(height),
-		Blocktime:     bchainTx.Blocktime,
-		Confirmations: bchainTx.Confirmations,
-		Fees:          fees,
-		Locktime:      bchainTx.LockTime,
-		WithSpends:    spendingTx,
-		Time:          bchainTx.Time,
-		Txid:          bchainTx.Txid,
-		ValueIn:       valIn,
-		ValueOut:      valOut,
-		Version:       bchainTx.Version,
-		Vin:           vins,
-		Vout:          vouts,
+		Blockhash:         blockhash,
+		Blockheight:       int(height),
+		Blocktime:         bchainTx.Blocktime,
+		Confirmations:     bchainTx.Confirmations,
+		Fees:              w.chainParser.AmountToDecimalString(&feesSat),
+		FeesSat:           &feesSat,
+		Locktime:          bchainTx.LockTime,
+		WithSpends:        spendingTx,
+		Time:              bchainTx.Time,
+		Txid:              bchainTx.Txid,
+		ValueIn:           w.chainParser.AmountToDecimalString(&valInSat),
+		ValueInSat:        &valInSat,
+		ValueOut:          w.chainParser.AmountToDecimalString(&valOutSat),
+		ValueOutSat:       &valOutSat,
+		Version:           bchainTx.Version,
+		Vin:               vins,
+		Vout:              vouts,
+		TokenTransfers:    tokenTransfers,
+		InternalTransfers: internalTransfers,
+		Receipt:           receipt,
+	}
+	if bchainTx.Confirmations == 0 {
+		r.ReplacedBy, err = w.chain.GetMempoolReplacedBy(txid)
+		if err != nil {
+			glog.Error("GetMempoolReplacedBy error ", err, " for ", txid)
+		}
+	}
+	return r, nil
+}
+
+// setSpendingTx sets to vout the input which spends it, first looking in the index of blocks, then in mempool
+// the SpentHeight of the output spent in mempool is 0
+func (w *Worker) setSpendingTx(vout *Vout, txid string, n uint32) error {
+	stx, err := w.db.GetSpendingTx(txid, n)
+	if err != nil {
+		return err
+	}
+	if stx != nil {
+		vout.SpentTxID = stx.Txid
+		vout.SpentIndex = int(stx.Vin)
+		vout.SpentHeight = int(stx.Height)
+		return nil
+	}
+	mtxid, vin, err := w.chain.GetMempoolSpendingTx(txid, n)
+	if err != nil {
+		return err
+	}
+	if mtxid != "" {
+		vout.SpentTxID = mtxid
+		vout.SpentIndex = vin
+	}
+	return nil
+}
+
+// getInternalTransfers returns the value transfers made by contracts during the execution of the transaction
+func (w *Worker) getInternalTransfers(tx *bchain.Tx) ([]InternalTransfer, error) {
+	transfers, err := w.chainParser.GetInternalTransfersFromTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		return nil, nil
+	}
+	r := make([]InternalTransfer, len(transfers))
+	for i := range transfers {
+		t := &transfers[i]
+		r[i] = InternalTransfer{
+			From:     t.From,
+			To:       t.To,
+			Value:    w.chainParser.AmountToDecimalString(&t.Value),
+			ValueSat: &t.Value,
+		}
 	}
 	return r, nil
 }
 
 func (s *Worker) getAddressTxids(address string, mempool bool) ([]string, error) {
-	var err error
-	txids := make([]string, 0)
 	if !mempool {
-		err = s.db.GetTransactions(address, 0, ^uint32(0), func(txid string, vout uint32, isOutput bool) error {
-			txids = append(txids, txid)
-			return nil
-		})
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		m, err := s.chain.GetMempoolTransactions(address)
-		if err != nil {
-			return nil, err
-		}
-		txids = append(txids, m...)
+		return s.getConfirmedAddressTxids(address, 0, ^uint32(0))
+	}
+	m, err := s.chain.GetMempoolTransactions(address)
+	if err != nil {
+		return nil, err
+	}
+	return append(make([]string, 0, len(m)), m...), nil
+}
+
+func (s *Worker) getConfirmedAddressTxids(address string, lower, higher uint32) ([]string, error) {
+	txids := make([]string, 0)
+	err := s.db.GetTransactions(address, lower, higher, func(txid string, vout uint32, isOutput bool) error {
+		txids = append(txids, txid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return txids, nil
 }
 
-func (t *Tx) getAddrVoutValue(addrID string) float64 {
-	var val float64
+func (t *Tx) getAddrVoutValue(addrID string) *big.Int {
+	var val big.Int
 	for _, vout := range t.Vout {
 		for _, a := range vout.ScriptPubKey.Addresses {
 			if a == addrID {
-				val += vout.Value
+				val.Add(&val, vout.ValueSat)
 			}
 		}
 	}
-	return val
+	return &val
 }
 
-func (t *Tx) getAddrVinValue(addrID string) float64 {
-	var val float64
+func (t *Tx) getAddrVinValue(addrID string) *big.Int {
+	var val big.Int
 	for _, vin := range t.Vin {
-		if vin.Addr == addrID {
-			val += vin.Value
+		if vin.Addr == addrID && vin.ValueSat != nil {
+			val.Add(&val, vin.ValueSat)
 		}
 	}
-	return val
+	return &val
 }
 
 // UniqueTxidsInReverse reverts the order of transactions (so that newest are first) and removes duplicate transactions
@@ -170,17 +268,42 @@ func UniqueTxidsInReverse(txids []string) []string {
 }
 
 // GetAddress computes address value and gets transactions for given address
-func (w *Worker) GetAddress(addrID string, page int) (*Address, error) {
+// if the time range fromTime-toTime (unix time in seconds) is specified, only the transactions in blocks mined in the range are returned
+// and TxApperances is the number of these transactions, the balances are always computed from all transactions
+func (w *Worker) GetAddress(addrID string, page int, fromTime, toTime int64) (*Address, error) {
 	glog.Info(addrID, " start")
-	txc, err := w.getAddressTxids(addrID, false)
-	txc = UniqueTxidsInReverse(txc)
+	ba, err := w.db.GetAddressBalance(addrID)
 	if err != nil {
 		return nil, err
 	}
-	txm, err := w.getAddressTxids(addrID, true)
+	// address without transactions in blocks is not in the balance column
+	if ba == nil {
+		ba = &db.AddrBalance{}
+	}
+	lower, higher, inRange, err := w.db.GetHeightRangeForTime(fromTime, toTime)
 	if err != nil {
 		return nil, err
 	}
+	txc := []string{}
+	if inRange {
+		txc, err = w.getConfirmedAddressTxids(addrID, lower, higher)
+		if err != nil {
+			return nil, err
+		}
+		txc = UniqueTxidsInReverse(txc)
+	}
+	txm := []string{}
+	// mempool transactions are not yet in any block, they belong only to the range not bounded from above
+	if toTime == 0 {
+		txm, err = w.getAddressTxids(addrID, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	txApperances := int(ba.Txs)
+	if fromTime != 0 || toTime != 0 {
+		txApperances = len(txc)
+	}
 	bestheight, _, err := w.db.GetBestBlock()
 	if err != nil {
 		return nil, err
@@ -191,14 +314,15 @@ func (w *Worker) GetAddress(addrID string, page int) (*Address, error) {
 	}
 	txs := make([]*Tx, len(txm)+lc)
 	txi := 0
-	var uBal, bal, totRecv, totSent float64
+	var uBalSat big.Int
 	for _, tx := range txm {
 		tx, err := w.GetTransaction(tx, bestheight, false)
 		// mempool transaction may fail
 		if err != nil {
 			glog.Error("GetTransaction ", tx, ": ", err)
 		} else {
-			uBal = tx.getAddrVoutValue(addrID) - tx.getAddrVinValue(addrID)
+			uBalSat.Add(&uBalSat, tx.getAddrVoutValue(addrID))
+			uBalSat.Sub(&uBalSat, tx.getAddrVinValue(addrID))
 			txs[txi] = tx
 			txi++
 		}
@@ -211,33 +335,312 @@ func (w *Worker) GetAddress(addrID string, page int) (*Address, error) {
 		from = 0
 	}
 	to := from + txsOnPage
-	for i, tx := range txc {
+	if to > len(txc) {
+		to = len(txc)
+	}
+	// the amounts are in the balance column, only the transactions on the page must be loaded
+	for _, tx := range txc[from:to] {
 		tx, err := w.GetTransaction(tx, bestheight, false)
 		if err != nil {
 			return nil, err
-		} else {
-			totRecv += tx.getAddrVoutValue(addrID)
-			totSent += tx.getAddrVinValue(addrID)
-			if i >= from && i < to {
-				txs[txi] = tx
-				txi++
-			}
 		}
+		txs[txi] = tx
+		txi++
 	}
-	bal = totRecv - totSent
+	totRecvSat := ba.ReceivedSat()
 	r := &Address{
 		AddrStr:                 addrID,
-		Balance:                 bal,
-		BalanceSat:              int64(bal*1E8 + 0.5),
-		TotalReceived:           totRecv,
-		TotalReceivedSat:        int64(totRecv*1E8 + 0.5),
-		TotalSent:               totSent,
-		TotalSentSat:            int64(totSent*1E8 + 0.5),
+		Balance:                 w.chainParser.AmountToDecimalString(&ba.BalanceSat),
+		BalanceSat:              &ba.BalanceSat,
+		TotalReceived:           w.chainParser.AmountToDecimalString(totRecvSat),
+		TotalReceivedSat:        totRecvSat,
+		TotalSent:               w.chainParser.AmountToDecimalString(&ba.SentSat),
+		TotalSentSat:            &ba.SentSat,
 		Transactions:            txs[:txi],
-		TxApperances:            len(txc),
-		UnconfirmedBalance:      uBal,
+		TxApperances:            txApperances,
+		UnconfirmedBalance:      w.chainParser.AmountToDecimalString(&uBalSat),
+		UnconfirmedBalanceSat:   &uBalSat,
 		UnconfirmedTxApperances: len(txm),
 	}
+	if !w.chainParser.IsUTXOChain() {
+		if err = w.setAccountState(r, addrID, bestheight); err != nil {
+			return nil, err
+		}
+		r.Tokens, err = w.getTokenBalances(addrID)
+		if err != nil {
+			return nil, err
+		}
+	}
 	glog.Info(addrID, " finished")
 	return r, nil
 }
+
+// setAccountState sets the balance and the nonce of the address of an account based chain from the backend,
+// the balance computed from the values of the transactions does not contain fees, rewards and internal transfers
+// the state is queried at the indexed best block so that it is consistent with the transactions of the address
+func (w *Worker) setAccountState(r *Address, addrID string, bestheight uint32) error {
+	s, err := w.chain.GetAccountState(addrID, int64(bestheight))
+	if err != nil {
+		return err
+	}
+	r.BalanceSat = &s.Balance
+	r.Balance = w.chainParser.AmountToDecimalString(&s.Balance)
+	// the received and sent amounts are not indexed for non UTXO chains
+	r.TotalReceived = ""
+	r.TotalReceivedSat = nil
+	r.TotalSent = ""
+	r.TotalSentSat = nil
+	r.Nonce = strconv.FormatUint(s.Nonce, 10)
+	return nil
+}
+
+// GetAccountState returns the balance and the nonce of the address of an account based chain at the block height,
+// negative height means the best block
+func (w *Worker) GetAccountState(address string, height int64) (*AccountState, error) {
+	if w.chainParser.IsUTXOChain() {
+		return nil, errors.New("Account state is not supported by UTXO chains")
+	}
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if height > int64(bestheight) {
+		return nil, errors.Errorf("Height %v is higher than the best block %v", height, bestheight)
+	}
+	if height < 0 {
+		// query the state at the indexed best block so that it is consistent with the index
+		height = int64(bestheight)
+	}
+	s, err := w.chain.GetAccountState(address, height)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountState{
+		AddrStr:    address,
+		Height:     uint32(height),
+		Balance:    w.chainParser.AmountToDecimalString(&s.Balance),
+		BalanceSat: &s.Balance,
+		Nonce:      strconv.FormatUint(s.Nonce, 10),
+	}, nil
+}
+
+// GetMempoolStats returns the number of transactions, their total size and fees and the fee rate histogram of the mempool,
+// the buckets of the histogram are in satoshi per vbyte
+func (w *Worker) GetMempoolStats() (*MempoolStats, error) {
+	s, err := w.chain.GetMempoolStats()
+	if err != nil {
+		return nil, err
+	}
+	h := make([]MempoolFeeRateBucket, len(s.FeeRateHistogram))
+	for i := range s.FeeRateHistogram {
+		h[i] = MempoolFeeRateBucket(s.FeeRateHistogram[i])
+	}
+	return &MempoolStats{
+		Txs:              s.Txs,
+		Vsize:            s.Vsize,
+		TotalFee:         w.chainParser.AmountToDecimalString(&s.TotalFee),
+		TotalFeeSat:      &s.TotalFee,
+		FeeRateHistogram: h,
+	}, nil
+}
+
+// EstimateFeeLevels returns the slow, standard and fast fees per unit of size in the smallest units of the coin
+// for the confirmation within the number of blocks
+func (w *Worker) EstimateFeeLevels(blocks int) (*FeeLevels, error) {
+	if blocks < 1 {
+		return nil, errors.Errorf("Invalid number of blocks %v", blocks)
+	}
+	fl, err := w.chain.EstimateFeeLevels(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return &FeeLevels{
+		Blocks:   fl.Blocks,
+		Slow:     &fl.Slow,
+		Standard: &fl.Standard,
+		Fast:     &fl.Fast,
+	}, nil
+}
+
+// GetAddressUtxo returns unspent outputs for given address, the unconfirmed outputs first followed by the confirmed ones from the newest
+// The outputs spent by mempool transactions are omitted
+func (w *Worker) GetAddressUtxo(address string) ([]AddressUtxo, error) {
+	addrID, err := w.chainParser.GetAddrIDFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	txm, err := w.getAddressTxids(address, true)
+	if err != nil {
+		return nil, err
+	}
+	// outputs spent in mempool, in format txid:vout
+	spentInMempool := make(map[string]struct{})
+	r := make([]AddressUtxo, 0)
+	for _, txid := range txm {
+		bchainTx, _, err := w.txCache.GetTransaction(txid, bestheight)
+		// mempool transaction may fail
+		if err != nil {
+			glog.Error("GetTransaction ", txid, ": ", err)
+			continue
+		}
+		for _, vin := range bchainTx.Vin {
+			if vin.Txid != "" {
+				spentInMempool[utxoKey(vin.Txid, vin.Vout)] = struct{}{}
+			}
+		}
+		for i := range bchainTx.Vout {
+			vout := &bchainTx.Vout[i]
+			voutAddrID, err := w.chainParser.GetAddrIDFromVout(vout)
+			if err != nil || !bytes.Equal(voutAddrID, addrID) {
+				continue
+			}
+			r = append(r, AddressUtxo{
+				Txid:      bchainTx.Txid,
+				Vout:      vout.N,
+				AmountSat: &vout.ValueSat,
+				Amount:    w.chainParser.AmountToDecimalString(&vout.ValueSat),
+			})
+		}
+	}
+	// remove unconfirmed outputs spent by other mempool transactions
+	j := 0
+	for _, u := range r {
+		if _, spent := spentInMempool[utxoKey(u.Txid, u.Vout)]; !spent {
+			r[j] = u
+			j++
+		}
+	}
+	r = r[:j]
+	utxos, err := w.db.GetAddressUtxos(address)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(utxos) - 1; i >= 0; i-- {
+		u := &utxos[i]
+		if _, spent := spentInMempool[utxoKey(u.Txid, u.Vout)]; spent {
+			continue
+		}
+		r = append(r, AddressUtxo{
+			Txid:          u.Txid,
+			Vout:          u.Vout,
+			AmountSat:     &u.ValueSat,
+			Amount:        w.chainParser.AmountToDecimalString(&u.ValueSat),
+			Height:        int(u.Height),
+			Confirmations: int(bestheight-u.Height) + 1,
+		})
+	}
+	return r, nil
+}
+
+func utxoKey(txid string, vout uint32) string {
+	return txid + ":" + strconv.Itoa(int(vout))
+}
+
+// GetBlock returns block with given hash or height, the transactions are paged by txsOnPage
+func (w *Worker) GetBlock(bid string, page int) (*Block, error) {
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	var hdr *bchain.BlockHeader
+	// hashes are 64 hex characters, shorter identifiers are heights
+	if height, err := strconv.Atoi(bid); err == nil && len(bid) < 64 && height >= 0 {
+		hdr, err = w.getBlockHeaderFromDB(uint32(height), bestheight)
+		if err != nil {
+			return nil, err
+		}
+		if hdr == nil {
+			return nil, errors.Errorf("Block %v not found", bid)
+		}
+	} else {
+		hdr, err = w.chain.GetBlockHeader(bid)
+		if err != nil {
+			return nil, errors.Annotatef(err, "GetBlock %v", bid)
+		}
+		// use the header of the indexed block, some backends do not return all header data
+		// the header of the backend is used for blocks which are not indexed, e.g. orphaned blocks
+		h, err := w.getBlockHeaderFromDB(hdr.Height, bestheight)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil && h.Hash == hdr.Hash {
+			hdr = h
+		}
+	}
+	glog.Info("GetBlock ", hdr.Hash, " start")
+	txids, err := w.chain.GetBlockTxids(hdr.Hash)
+	if err != nil {
+		return nil, errors.Annotatef(err, "GetBlock %v", bid)
+	}
+	txCount := len(txids)
+	totalPages := (txCount + txsOnPage - 1) / txsOnPage
+	if page < 0 || page >= totalPages {
+		page = 0
+	}
+	from := page * txsOnPage
+	to := from + txsOnPage
+	if to > txCount {
+		to = txCount
+	}
+	txs := make([]*Tx, 0, to-from)
+	for _, txid := range txids[from:to] {
+		// read the transactions through the cache
+		tx, err := w.GetTransaction(txid, bestheight, false)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	glog.Info("GetBlock ", hdr.Hash, " finished")
+	return &Block{
+		Hash:          hdr.Hash,
+		Prev:          hdr.Prev,
+		Next:          hdr.Next,
+		Height:        hdr.Height,
+		Confirmations: hdr.Confirmations,
+		Size:          hdr.Size,
+		Time:          hdr.Time,
+		TxCount:       txCount,
+		Page:          page,
+		TotalPages:    totalPages,
+		Transactions:  txs,
+	}, nil
+}
+
+// getBlockHeaderFromDB returns the header of the block at the height composed from the height column, nil if the block is not indexed
+func (w *Worker) getBlockHeaderFromDB(height uint32, bestheight uint32) (*bchain.BlockHeader, error) {
+	bi, err := w.db.GetBlockInfo(height)
+	if err != nil || bi == nil {
+		return nil, err
+	}
+	hdr := &bchain.BlockHeader{
+		Hash:          bi.Hash,
+		Height:        height,
+		Confirmations: int(bestheight) - int(height) + 1,
+		Size:          int(bi.Size),
+		Time:          bi.Time,
+	}
+	if height > 0 {
+		prev, err := w.db.GetBlockInfo(height - 1)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil {
+			hdr.Prev = prev.Hash
+		}
+	}
+	if height < bestheight {
+		next, err := w.db.GetBlockInfo(height + 1)
+		if err != nil {
+			return nil, err
+		}
+		if next != nil {
+			hdr.Next = next.Hash
+		}
+	}
+	return hdr, nil
+}
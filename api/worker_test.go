@@ -0,0 +1,56 @@
+// +build unittest
+
+package api
+
+import (
+	"blockbook/bchain"
+	"blockbook/bchain/coins/eth"
+	"math/big"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+// testAccountChain returns the account states of the addresses by height
+type testAccountChain struct {
+	bchain.BlockChain
+	states map[int64]*bchain.AccountState
+}
+
+func (c *testAccountChain) GetAccountState(address string, height int64) (*bchain.AccountState, error) {
+	s, found := c.states[height]
+	if !found {
+		return nil, errors.Errorf("state of %v at height %d not found", address, height)
+	}
+	return s, nil
+}
+
+func Test_setAccountState(t *testing.T) {
+	s := &bchain.AccountState{Nonce: 12}
+	s.Balance.SetString("1230000000000000000", 10)
+	w := &Worker{
+		chain:       &testAccountChain{states: map[int64]*bchain.AccountState{100: s}},
+		chainParser: eth.NewEthereumParser(),
+	}
+	r := &Address{
+		Balance:          "0.5",
+		BalanceSat:       big.NewInt(500000000000000000),
+		TotalReceived:    "1",
+		TotalReceivedSat: big.NewInt(1000000000000000000),
+		TotalSent:        "0.5",
+		TotalSentSat:     big.NewInt(500000000000000000),
+	}
+	// the state must be queried at the indexed best height, not at the tip of the backend
+	if err := w.setAccountState(r, "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", 100); err != nil {
+		t.Fatal(err)
+	}
+	if r.Balance != "1.23" || r.BalanceSat.Cmp(&s.Balance) != 0 || r.Nonce != "12" {
+		t.Errorf("setAccountState() balance %v, balanceSat %v, nonce %v, want 1.23, %v, 12", r.Balance, r.BalanceSat, r.Nonce, &s.Balance)
+	}
+	if r.TotalReceived != "" || r.TotalReceivedSat != nil || r.TotalSent != "" || r.TotalSentSat != nil {
+		t.Errorf("setAccountState() did not clear the received and sent amounts: %+v", r)
+	}
+	if err := w.setAccountState(r, "0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", 101); err == nil {
+		t.Error("setAccountState() expected error for missing state")
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"blockbook/db"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang/glog"
+)
+
+// xpubGap is the number of consecutive unused addresses after which the derivation of a chain stops
+const xpubGap = 20
+
+// xpubChains are the derivation chains of an account, receive (0) and change (1)
+var xpubChains = []uint32{0, 1}
+
+type xpubAddress struct {
+	addrID   []byte
+	addrStr  string
+	path     string
+	balance  *db.AddrBalance
+	mempool  []string
+	txHeight map[string]uint32
+}
+
+// deriveXpubAddresses derives addresses of given chain of the xpub until xpubGap consecutive unused addresses are found
+// returns only the used addresses
+func (w *Worker) deriveXpubAddresses(xpub string, change uint32) ([]*xpubAddress, error) {
+	used := make([]*xpubAddress, 0)
+	lastUsed := -1
+	for from := uint32(0); int(from) <= lastUsed+xpubGap; from += xpubGap {
+		addrIDs, err := w.chainParser.DeriveAddrIDsFromXpub(xpub, change, from, from+xpubGap)
+		if err != nil {
+			return nil, err
+		}
+		for i, addrID := range addrIDs {
+			index := from + uint32(i)
+			xa := &xpubAddress{
+				addrID: addrID,
+				path:   fmt.Sprintf("%d/%d", change, index),
+			}
+			addrs, err := w.chainParser.OutputScriptToAddresses(addrID)
+			if err != nil {
+				return nil, err
+			}
+			if len(addrs) > 0 {
+				xa.addrStr = addrs[0]
+				xa.mempool, err = w.getAddressTxids(xa.addrStr, true)
+				if err != nil {
+					return nil, err
+				}
+			}
+			xa.balance, err = w.db.GetAddrIDBalance(addrID)
+			if err != nil {
+				return nil, err
+			}
+			if xa.balance != nil || len(xa.mempool) > 0 {
+				if xa.balance == nil {
+					xa.balance = &db.AddrBalance{}
+				}
+				lastUsed = int(index)
+				used = append(used, xa)
+			}
+		}
+	}
+	return used, nil
+}
+
+// GetXpub derives used addresses of the extended public key and aggregates their balances, transactions and utxos
+func (w *Worker) GetXpub(xpub string, page int) (*Xpub, error) {
+	glog.Info(xpub, " start")
+	bestheight, _, err := w.db.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]*xpubAddress, 0)
+	for _, change := range xpubChains {
+		a, err := w.deriveXpubAddresses(xpub, change)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a...)
+	}
+	var balSat, sentSat, uBalSat big.Int
+	// heights of confirmed transactions of all addresses
+	txHeight := make(map[string]uint32)
+	mempoolTxids := make([]string, 0)
+	mempoolSet := make(map[string]struct{})
+	r := &Xpub{
+		Xpub:      xpub,
+		Addresses: make([]XpubAddress, len(addresses)),
+		Utxos:     make([]AddressUtxo, 0),
+	}
+	for i, xa := range addresses {
+		xa.txHeight = make(map[string]uint32)
+		err = w.db.GetAddrIDTransactions(xa.addrID, 0, ^uint32(0), func(txid string, height uint32, vout uint32, isOutput bool) error {
+			xa.txHeight[txid] = height
+			txHeight[txid] = height
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, txid := range xa.mempool {
+			if _, e := mempoolSet[txid]; !e {
+				mempoolSet[txid] = struct{}{}
+				mempoolTxids = append(mempoolTxids, txid)
+			}
+		}
+		balSat.Add(&balSat, &xa.balance.BalanceSat)
+		sentSat.Add(&sentSat, &xa.balance.SentSat)
+		recvSat := xa.balance.ReceivedSat()
+		r.Addresses[i] = XpubAddress{
+			AddrStr:                 xa.addrStr,
+			Path:                    xa.path,
+			Balance:                 w.chainParser.AmountToDecimalString(&xa.balance.BalanceSat),
+			BalanceSat:              &xa.balance.BalanceSat,
+			TotalReceived:           w.chainParser.AmountToDecimalString(recvSat),
+			TotalReceivedSat:        recvSat,
+			TotalSent:               w.chainParser.AmountToDecimalString(&xa.balance.SentSat),
+			TotalSentSat:            &xa.balance.SentSat,
+			UnconfirmedTxApperances: len(xa.mempool),
+			TxApperances:            len(xa.txHeight),
+		}
+		if xa.addrStr != "" {
+			utxos, err := w.GetAddressUtxo(xa.addrStr)
+			if err != nil {
+				return nil, err
+			}
+			for j := range utxos {
+				utxos[j].AddrStr = xa.addrStr
+				utxos[j].Path = xa.path
+			}
+			r.Utxos = append(r.Utxos, utxos...)
+		}
+	}
+	// mempool transactions first, then confirmed transactions from the newest
+	txs := make([]*Tx, 0, len(mempoolTxids)+txsOnPage)
+	for _, txid := range mempoolTxids {
+		tx, err := w.GetTransaction(txid, bestheight, false)
+		// mempool transaction may fail
+		if err != nil {
+			glog.Error("GetTransaction ", txid, ": ", err)
+			continue
+		}
+		for _, xa := range addresses {
+			if xa.addrStr != "" {
+				uBalSat.Add(&uBalSat, tx.getAddrVoutValue(xa.addrStr))
+				uBalSat.Sub(&uBalSat, tx.getAddrVinValue(xa.addrStr))
+			}
+		}
+		txs = append(txs, tx)
+	}
+	txc := make([]string, 0, len(txHeight))
+	for txid := range txHeight {
+		txc = append(txc, txid)
+	}
+	sort.Slice(txc, func(i, j int) bool {
+		hi, hj := txHeight[txc[i]], txHeight[txc[j]]
+		if hi != hj {
+			return hi > hj
+		}
+		return txc[i] < txc[j]
+	})
+	if page < 0 {
+		page = 0
+	}
+	from := page * txsOnPage
+	if from > len(txc) {
+		from = 0
+	}
+	to := from + txsOnPage
+	if to > len(txc) {
+		to = len(txc)
+	}
+	for _, txid := range txc[from:to] {
+		tx, err := w.GetTransaction(txid, bestheight, false)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	var recvSat big.Int
+	recvSat.Add(&balSat, &sentSat)
+	r.Balance = w.chainParser.AmountToDecimalString(&balSat)
+	r.BalanceSat = &balSat
+	r.TotalReceived = w.chainParser.AmountToDecimalString(&recvSat)
+	r.TotalReceivedSat = &recvSat
+	r.TotalSent = w.chainParser.AmountToDecimalString(&sentSat)
+	r.TotalSentSat = &sentSat
+	r.UnconfirmedBalance = w.chainParser.AmountToDecimalString(&uBalSat)
+	r.UnconfirmedBalanceSat = &uBalSat
+	r.UnconfirmedTxApperances = len(mempoolTxids)
+	r.TxApperances = len(txc)
+	r.Transactions = txs
+	glog.Info(xpub, " finished")
+	return r, nil
+}
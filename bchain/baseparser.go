@@ -3,6 +3,8 @@ package bchain
 import (
 	"encoding/hex"
 	"encoding/json"
+	"math/big"
+	"strings"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/juju/errors"
@@ -14,6 +16,7 @@ type AddressFactoryFunc func(string) (Address, error)
 type BaseParser struct {
 	AddressFactory       AddressFactoryFunc
 	BlockAddressesToKeep int
+	AmountDecimalPoint   int
 }
 
 // AddressToOutputScript converts address to ScriptPubKey - currently not implemented
@@ -26,6 +29,11 @@ func (p *BaseParser) OutputScriptToAddresses(script []byte) ([]string, error) {
 	return nil, errors.New("OutputScriptToAddresses: not implemented")
 }
 
+// DeriveAddrIDsFromXpub derives addrIDs of addresses from extended public key - currently not implemented
+func (p *BaseParser) DeriveAddrIDsFromXpub(xpub string, change uint32, fromIndex uint32, toIndex uint32) ([][]byte, error) {
+	return nil, errors.New("DeriveAddrIDsFromXpub: not implemented")
+}
+
 // ParseBlock parses raw block to our Block struct - currently not implemented
 func (p *BaseParser) ParseBlock(b []byte) (*Block, error) {
 	return nil, errors.New("ParseBlock: not implemented")
@@ -36,6 +44,91 @@ func (p *BaseParser) ParseTx(b []byte) (*Tx, error) {
 	return nil, errors.New("ParseTx: not implemented")
 }
 
+// GetErc20TransfersFromTx returns ERC20 token transfers of the transaction, the base implementation returns no transfers
+func (p *BaseParser) GetErc20TransfersFromTx(tx *Tx) ([]Erc20Transfer, error) {
+	return nil, nil
+}
+
+// GetTxReceipt returns the result of the execution of the transaction, the base implementation returns nil
+func (p *BaseParser) GetTxReceipt(tx *Tx) (*TxReceipt, error) {
+	return nil, nil
+}
+
+// GetTxFeeRate returns the fee per unit of size if it is given by the transaction itself, the base implementation returns nil
+func (p *BaseParser) GetTxFeeRate(tx *Tx) (*big.Int, error) {
+	return nil, nil
+}
+
+// IndexContractLogs returns true if the event logs of contracts are to be indexed, the base implementation returns false
+func (p *BaseParser) IndexContractLogs() bool {
+	return false
+}
+
+// GetContractLogsFromTx returns the event logs of the transaction, the base implementation returns no logs
+func (p *BaseParser) GetContractLogsFromTx(tx *Tx) ([]ContractLog, error) {
+	return nil, nil
+}
+
+// GetInternalTransfersFromTx returns internal transfers of the transaction, the base implementation returns no transfers
+func (p *BaseParser) GetInternalTransfersFromTx(tx *Tx) ([]InternalTransfer, error) {
+	return nil, nil
+}
+
+const zeros = "0000000000000000000000000000000000000000"
+
+// AmountToBigInt converts amount in json.Number (string) to big.Int
+// it uses string operations to avoid problems with rounding of float64
+func (p *BaseParser) AmountToBigInt(n json.Number) (big.Int, error) {
+	var r big.Int
+	s := string(n)
+	d := p.AmountDecimalPoint
+	if d > len(zeros) {
+		d = len(zeros)
+	}
+	i := strings.IndexByte(s, '.')
+	if i == -1 {
+		s = s + zeros[:d]
+	} else {
+		// number of digits to pad with zeros, negative if there are more decimal digits than d
+		z := d - (len(s) - i - 1)
+		if z >= 0 {
+			s = s[:i] + s[i+1:] + zeros[:z]
+		} else {
+			s = s[:i] + s[i+1:len(s)+z]
+		}
+	}
+	if _, ok := r.SetString(s, 10); !ok {
+		return r, errors.Errorf("AmountToBigInt: failed to convert %v", n)
+	}
+	return r, nil
+}
+
+// AmountToDecimalString converts amount in big.Int to string with decimal point in the place defined by AmountDecimalPoint
+func (p *BaseParser) AmountToDecimalString(a *big.Int) string {
+	if a == nil {
+		return "0"
+	}
+	n := a.String()
+	var sign string
+	if n[0] == '-' {
+		sign = "-"
+		n = n[1:]
+	}
+	d := p.AmountDecimalPoint
+	if d > len(zeros) {
+		d = len(zeros)
+	}
+	if len(n) <= d {
+		n = zeros[:d-len(n)+1] + n
+	}
+	i := len(n) - d
+	dec := strings.TrimRight(n[i:], "0")
+	if len(dec) > 0 {
+		return sign + n[:i] + "." + dec
+	}
+	return sign + n[:i]
+}
+
 // ParseTxFromJson parses JSON message containing transaction and returs Tx struct
 func (p *BaseParser) ParseTxFromJson(msg json.RawMessage) (*Tx, error) {
 	var tx Tx
@@ -44,13 +137,20 @@ func (p *BaseParser) ParseTxFromJson(msg json.RawMessage) (*Tx, error) {
 		return nil, err
 	}
 
-	for i, vout := range tx.Vout {
+	for i := range tx.Vout {
+		vout := &tx.Vout[i]
+		// convert vout.JsonValue to big.Int and clear it, it is only temporary value used for unmarshal
+		vout.ValueSat, err = p.AmountToBigInt(vout.JsonValue)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Vout %v", i)
+		}
+		vout.JsonValue = ""
 		if len(vout.ScriptPubKey.Addresses) == 1 {
 			a, err := p.AddressFactory(vout.ScriptPubKey.Addresses[0])
 			if err != nil {
 				return nil, err
 			}
-			tx.Vout[i].Address = a
+			vout.Address = a
 		}
 	}
 
@@ -127,7 +227,7 @@ func (p *BaseParser) PackTx(tx *Tx, height uint32, blockTime int64) ([]byte, err
 			Addresses:       vo.ScriptPubKey.Addresses,
 			N:               vo.N,
 			ScriptPubKeyHex: hex,
-			Value:           vo.Value,
+			ValueSat:        vo.ValueSat.Bytes(),
 		}
 	}
 	pt := &ProtoTransaction{
@@ -182,8 +282,8 @@ func (p *BaseParser) UnpackTx(buf []byte) (*Tx, uint32, error) {
 				Addresses: pto.Addresses,
 				Hex:       hex.EncodeToString(pto.ScriptPubKeyHex),
 			},
-			Value: pto.Value,
 		}
+		vout[i].ValueSat.SetBytes(pto.ValueSat)
 		if len(pto.Addresses) == 1 {
 			a, err := p.AddressFactory(pto.Addresses[0])
 			if err != nil {
@@ -0,0 +1,69 @@
+// +build unittest
+
+package bchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestBaseParser_AmountToBigInt(t *testing.T) {
+	tests := []struct {
+		name               string
+		amountDecimalPoint int
+		a                  json.Number
+		want               string
+		wantErr            bool
+	}{
+		{name: "zero", amountDecimalPoint: 8, a: "0", want: "0"},
+		{name: "integer", amountDecimalPoint: 8, a: "12", want: "1200000000"},
+		{name: "decimal", amountDecimalPoint: 8, a: "0.00038812", want: "38812"},
+		{name: "short decimal", amountDecimalPoint: 8, a: ".1", want: "10000000"},
+		{name: "large", amountDecimalPoint: 8, a: "59890867.89818935", want: "5989086789818935"},
+		{name: "truncated", amountDecimalPoint: 8, a: "1.123456789", want: "112345678"},
+		{name: "negative", amountDecimalPoint: 8, a: "-1.5", want: "-150000000"},
+		{name: "ether", amountDecimalPoint: 18, a: "1.999622", want: "1999622000000000000"},
+		{name: "invalid", amountDecimalPoint: 8, a: "1.2a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &BaseParser{AmountDecimalPoint: tt.amountDecimalPoint}
+			got, err := p.AmountToBigInt(tt.a)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BaseParser.AmountToBigInt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("BaseParser.AmountToBigInt() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseParser_AmountToDecimalString(t *testing.T) {
+	tests := []struct {
+		name               string
+		amountDecimalPoint int
+		a                  string
+		want               string
+	}{
+		{name: "zero", amountDecimalPoint: 8, a: "0", want: "0"},
+		{name: "satoshi", amountDecimalPoint: 8, a: "1", want: "0.00000001"},
+		{name: "decimal", amountDecimalPoint: 8, a: "38812", want: "0.00038812"},
+		{name: "integer", amountDecimalPoint: 8, a: "1200000000", want: "12"},
+		{name: "trailing zeros", amountDecimalPoint: 8, a: "10000000", want: "0.1"},
+		{name: "negative", amountDecimalPoint: 8, a: "-150000000", want: "-1.5"},
+		{name: "ether", amountDecimalPoint: 18, a: "1999622000000000000", want: "1.999622"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &BaseParser{AmountDecimalPoint: tt.amountDecimalPoint}
+			var a big.Int
+			a.SetString(tt.a, 10)
+			if got := p.AmountToDecimalString(&a); got != tt.want {
+				t.Errorf("BaseParser.AmountToDecimalString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -55,6 +55,10 @@ func (b *BCashRPC) Initialize() error {
 		b.Network = "testnet"
 	}
 
+	if err = b.InitializeMQ(); err != nil {
+		return err
+	}
+
 	glog.Info("rpc: block chain ", params.Name)
 
 	return nil
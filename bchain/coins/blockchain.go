@@ -18,7 +18,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/juju/errors"
@@ -82,15 +84,37 @@ func NewBlockChain(coin string, configfile string, pushHandler func(bchain.Notif
 	if !ok {
 		return nil, errors.New(fmt.Sprint("Unsupported coin '", coin, "'. Must be one of ", reflect.ValueOf(blockChainFactories).MapKeys()))
 	}
-	bc, err := bcf(config, pushHandler)
+	bc, err := bcf(config, countMissedNotifications(pushHandler, metrics))
 	if err != nil {
 		return nil, err
 	}
+	bcm := &blockChainWithMetrics{b: bc, m: metrics}
+	if o, ok := bc.(rpcLatencyObserver); ok {
+		o.SetRPCLatencyObserver(bcm.observeRPCLatency)
+	}
 	err = bc.Initialize()
 	if err != nil {
 		return nil, err
 	}
-	return &blockChainWithMetrics{b: bc, m: metrics}, nil
+	return bcm, nil
+}
+
+// rpcLatencyObserver is implemented by the coins which report the latency of the requests not made through the BlockChain interface
+type rpcLatencyObserver interface {
+	SetRPCLatencyObserver(observe func(method string, start time.Time, err error))
+}
+
+// countMissedNotifications counts the notifications missed by the message queue before passing them to the push handler
+func countMissedNotifications(pushHandler func(bchain.NotificationType), metrics *common.Metrics) func(bchain.NotificationType) {
+	return func(nt bchain.NotificationType) {
+		switch nt {
+		case bchain.NotificationMissedBlock:
+			metrics.MQSequenceGaps.With(common.Labels{"notification": "block"}).Inc()
+		case bchain.NotificationMissedTx:
+			metrics.MQSequenceGaps.With(common.Labels{"notification": "tx"}).Inc()
+		}
+		pushHandler(nt)
+	}
 }
 
 type blockChainWithMetrics struct {
@@ -160,6 +184,11 @@ func (c *blockChainWithMetrics) GetBlock(hash string, height uint32) (v *bchain.
 	return c.b.GetBlock(hash, height)
 }
 
+func (c *blockChainWithMetrics) GetBlockTxids(hash string) (v []string, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetBlockTxids", s, err) }(time.Now())
+	return c.b.GetBlockTxids(hash)
+}
+
 func (c *blockChainWithMetrics) GetMempool() (v []string, err error) {
 	defer func(s time.Time) { c.observeRPCLatency("GetMempool", s, err) }(time.Now())
 	return c.b.GetMempool()
@@ -190,20 +219,66 @@ func (c *blockChainWithMetrics) SendRawTransaction(tx string) (v string, err err
 	return c.b.SendRawTransaction(tx)
 }
 
-func (c *blockChainWithMetrics) ResyncMempool(onNewTxAddr func(txid string, addr string)) (count int, err error) {
+func (c *blockChainWithMetrics) ResyncMempool(onNewTxAddr func(txid string, addr string), onReplacedTx func(txid string, replacedBy string)) (count int, err error) {
 	defer func(s time.Time) { c.observeRPCLatency("ResyncMempool", s, err) }(time.Now())
-	count, err = c.b.ResyncMempool(onNewTxAddr)
+	count, err = c.b.ResyncMempool(onNewTxAddr, onReplacedTx)
 	if err == nil {
 		c.m.MempoolSize.Set(float64(count))
+		if s, err := c.b.GetMempoolStats(); err == nil {
+			c.m.MempoolVsize.Set(float64(s.Vsize))
+			for _, h := range s.FeeRateHistogram {
+				c.m.MempoolFeeRateBuckets.With(common.Labels{"feerate": strconv.FormatInt(h.FeeRate, 10)}).Set(float64(h.Vsize))
+			}
+		}
 	}
 	return count, err
 }
 
+func (c *blockChainWithMetrics) GetMempoolReplacedBy(txid string) (v string, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetMempoolReplacedBy", s, err) }(time.Now())
+	return c.b.GetMempoolReplacedBy(txid)
+}
+
+func (c *blockChainWithMetrics) PackMempoolState() (v []byte, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("PackMempoolState", s, err) }(time.Now())
+	return c.b.PackMempoolState()
+}
+
+func (c *blockChainWithMetrics) UnpackMempoolState(buf []byte) (err error) {
+	defer func(s time.Time) { c.observeRPCLatency("UnpackMempoolState", s, err) }(time.Now())
+	return c.b.UnpackMempoolState(buf)
+}
+
+func (c *blockChainWithMetrics) GetMempoolStats() (v *bchain.MempoolStats, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetMempoolStats", s, err) }(time.Now())
+	return c.b.GetMempoolStats()
+}
+
 func (c *blockChainWithMetrics) GetMempoolTransactions(address string) (v []string, err error) {
 	defer func(s time.Time) { c.observeRPCLatency("GetMempoolTransactions", s, err) }(time.Now())
 	return c.b.GetMempoolTransactions(address)
 }
 
+func (c *blockChainWithMetrics) GetMempoolSpendingTx(txid string, vout uint32) (v string, vin int, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetMempoolSpendingTx", s, err) }(time.Now())
+	return c.b.GetMempoolSpendingTx(txid, vout)
+}
+
+func (c *blockChainWithMetrics) GetErc20ContractBalance(address, contract string) (v *big.Int, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetErc20ContractBalance", s, err) }(time.Now())
+	return c.b.GetErc20ContractBalance(address, contract)
+}
+
+func (c *blockChainWithMetrics) GetAccountState(address string, height int64) (v *bchain.AccountState, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("GetAccountState", s, err) }(time.Now())
+	return c.b.GetAccountState(address, height)
+}
+
+func (c *blockChainWithMetrics) EstimateFeeLevels(blocks int) (v *bchain.FeeLevels, err error) {
+	defer func(s time.Time) { c.observeRPCLatency("EstimateFeeLevels", s, err) }(time.Now())
+	return c.b.EstimateFeeLevels(blocks)
+}
+
 func (c *blockChainWithMetrics) GetMempoolEntry(txid string) (v *bchain.MempoolEntry, err error) {
 	defer func(s time.Time) { c.observeRPCLatency("GetMempoolEntry", s, err) }(time.Now())
 	return c.b.GetMempoolEntry(txid)
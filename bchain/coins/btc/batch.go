@@ -0,0 +1,70 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// marshalBatch returns the JSON-RPC batch of the requests, the id of each request is its index,
+// which allows to match the responses returned by the backend in any order
+func marshalBatch(m RPCMarshaler, reqs []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, req := range reqs {
+		d, err := m.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(d) < 2 || d[0] != '{' {
+			return nil, errors.Errorf("Invalid batch request %d", i)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"id":`)
+		buf.WriteString(strconv.Itoa(i))
+		if len(d) > 2 {
+			buf.WriteByte(',')
+		}
+		buf.Write(d[1:])
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// unmarshalBatch decodes the responses of the JSON-RPC batch to ress, the responses are matched by their ids,
+// each request must have exactly one response
+func unmarshalBatch(data []byte, ress []interface{}) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.Annotatef(err, "batch response")
+	}
+	received := make([]bool, len(ress))
+	for _, r := range raw {
+		var id struct {
+			ID *int `json:"id"`
+		}
+		if err := json.Unmarshal(r, &id); err != nil {
+			return errors.Annotatef(err, "batch response")
+		}
+		if id.ID == nil || *id.ID < 0 || *id.ID >= len(ress) {
+			return errors.Errorf("Invalid id in batch response %s", r)
+		}
+		if received[*id.ID] {
+			return errors.Errorf("Duplicate id %d in batch response", *id.ID)
+		}
+		received[*id.ID] = true
+		if err := json.Unmarshal(r, ress[*id.ID]); err != nil {
+			return errors.Annotatef(err, "batch response %d", *id.ID)
+		}
+	}
+	for i := range received {
+		if !received[i] {
+			return errors.Errorf("Missing response %d in batch of %d requests", i, len(ress))
+		}
+	}
+	return nil
+}
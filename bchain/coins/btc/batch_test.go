@@ -0,0 +1,222 @@
+// +build unittest
+
+package btc
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_marshalBatch(t *testing.T) {
+	block := CmdGetBlock{Method: "getblock"}
+	block.Params.BlockHash = "0000000000000000000000000000000000000000000000000000000000000001"
+	tx := CmdGetRawTransaction{Method: "getrawtransaction"}
+	tx.Params.Txid = "a1"
+	reqs := []interface{}{&block, &tx, &CmdGetBestBlockHash{Method: "getbestblockhash"}}
+	tests := []struct {
+		name string
+		m    RPCMarshaler
+		want string
+	}{
+		{
+			name: "V2",
+			m:    JSONMarshalerV2{},
+			want: `[{"id":0,"method":"getblock","params":{"blockhash":"0000000000000000000000000000000000000000000000000000000000000001","verbosity":0}},` +
+				`{"id":1,"method":"getrawtransaction","params":{"txid":"a1","verbose":false}},` +
+				`{"id":2,"method":"getbestblockhash"}]`,
+		},
+		{
+			name: "V1",
+			m:    JSONMarshalerV1{},
+			want: `[{"id":0,"method":"getblock","params":["0000000000000000000000000000000000000000000000000000000000000001",false]},` +
+				`{"id":1,"method":"getrawtransaction","params":["a1",0]},` +
+				`{"id":2,"method":"getbestblockhash"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := marshalBatch(tt.m, reqs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("marshalBatch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_unmarshalBatch(t *testing.T) {
+	ress := []interface{}{&ResGetBlockHash{}, &ResGetBlockHash{}}
+	err := unmarshalBatch([]byte(`[{"id":1,"result":null,"error":{"code":-8,"message":"Block height out of range"}},{"id":0,"result":"00ab","error":null}]`), ress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := ress[0].(*ResGetBlockHash); r.Error != nil || r.Result != "00ab" {
+		t.Errorf("unmarshalBatch() response 0 = %+v", r)
+	}
+	if r := ress[1].(*ResGetBlockHash); r.Error == nil || r.Error.Code != -8 {
+		t.Errorf("unmarshalBatch() response 1 = %+v", r)
+	}
+	for _, data := range []string{
+		`{"result":null,"error":{"code":-32700,"message":"Parse error"}}`,
+		`[{"id":0,"result":"00ab","error":null}]`,
+		`[{"id":0,"result":"00ab","error":null},{"id":2,"result":"00ab","error":null}]`,
+		`[{"id":0,"result":"00ab","error":null},{"id":0,"result":"00cd","error":null}]`,
+		`[{"id":0,"result":"00ab","error":null},{"id":1,"result":"00cd","error":null},{"id":1,"result":"00cd","error":null}]`,
+	} {
+		if err := unmarshalBatch([]byte(data), ress); err == nil {
+			t.Errorf("unmarshalBatch(%s) did not return error", data)
+		}
+	}
+}
+
+type testBatchRequest struct {
+	ID     *int   `json:"id"`
+	Method string `json:"method"`
+	Params struct {
+		Height    uint32 `json:"height"`
+		BlockHash string `json:"blockhash"`
+	} `json:"params"`
+}
+
+// testBatchBackend serves getblockhash and getblock of the blocks up to the best height, the hash of a block is its height prefixed by "h"
+// and the raw block is the hash, the first getblock batch waits until release is closed
+type testBatchBackend struct {
+	mux     sync.Mutex
+	best    uint32
+	calls   map[string]int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *testBatchBackend) response(req *testBatchRequest) map[string]interface{} {
+	r := map[string]interface{}{"id": req.ID, "result": nil, "error": nil}
+	switch req.Method {
+	case "getblockhash":
+		if req.Params.Height > s.best {
+			r["error"] = map[string]interface{}{"code": -8, "message": "Block height out of range"}
+		} else {
+			r["result"] = "h" + strconv.Itoa(int(req.Params.Height))
+		}
+	case "getblock":
+		if h, err := strconv.Atoi(req.Params.BlockHash[1:]); err != nil || uint32(h) > s.best {
+			r["error"] = map[string]interface{}{"code": -5, "message": "Block not found"}
+		} else {
+			r["result"] = hex.EncodeToString([]byte(req.Params.BlockHash))
+		}
+	}
+	return r
+}
+
+func (s *testBatchBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var res interface{}
+	if bytes.HasPrefix(body, []byte("[")) {
+		var reqs []testBatchRequest
+		if err = json.Unmarshal(body, &reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mux.Lock()
+		s.calls[reqs[0].Method+" batch"]++
+		var started chan struct{}
+		if reqs[0].Method == "getblock" {
+			started, s.started = s.started, nil
+		}
+		s.mux.Unlock()
+		if started != nil {
+			close(started)
+			<-s.release
+		}
+		ress := make([]interface{}, len(reqs))
+		// return the responses in the reverse order, they must be matched by ids
+		for i := range reqs {
+			ress[len(reqs)-1-i] = s.response(&reqs[i])
+		}
+		res = ress
+	} else {
+		var req testBatchRequest
+		if err = json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mux.Lock()
+		s.calls[req.Method]++
+		s.mux.Unlock()
+		res = s.response(&req)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func Test_getBlockRawBatch(t *testing.T) {
+	backend := &testBatchBackend{
+		best:    11,
+		calls:   make(map[string]int),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	srv := httptest.NewServer(backend)
+	defer srv.Close()
+	b := &BitcoinRPC{
+		endpoints:         bchain.NewRPCEndpoints([]string{srv.URL}, 1, bchain.DefaultRPCMaxErrorRate),
+		ChainConfig:       &Configuration{BlockBatchSize: 3},
+		RPCMarshaler:      JSONMarshalerV2{},
+		rawBlocks:         newRawBlockCache(rawBlocksToKeep + 6),
+		blockBatchPending: make(map[uint32]chan struct{}),
+	}
+	type result struct {
+		data []byte
+		err  error
+	}
+	get := func(hash string, height uint32) chan result {
+		c := make(chan result, 1)
+		go func() {
+			data, err := b.getBlockRawBatch(hash, height)
+			c <- result{data, err}
+		}()
+		return c
+	}
+	// the batch of the blocks 10-12 is started, block 12 is beyond the best block
+	first := get("h10", 10)
+	<-backend.started
+	// the block 11 is pending in the started batch, it must be taken from the cache after the batch finishes
+	second := get("h11", 11)
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	for _, r := range []struct {
+		c    chan result
+		want string
+	}{{first, "h10"}, {second, "h11"}} {
+		got := <-r.c
+		if got.err != nil {
+			t.Fatal(got.err)
+		}
+		if string(got.data) != r.want {
+			t.Errorf("getBlockRawBatch() = %s, want %s", got.data, r.want)
+		}
+	}
+	backend.mux.Lock()
+	defer backend.mux.Unlock()
+	want := map[string]int{"getblockhash batch": 1, "getblock batch": 1}
+	if !reflect.DeepEqual(backend.calls, want) {
+		t.Errorf("backend calls = %v, want %v", backend.calls, want)
+	}
+	if len(b.blockBatchPending) != 0 {
+		t.Errorf("blockBatchPending = %v, want empty", b.blockBatchPending)
+	}
+}
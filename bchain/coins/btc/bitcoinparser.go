@@ -12,6 +12,9 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/juju/errors"
 )
 
 // OutputScriptToAddressesFunc converts ScriptPubKey to bitcoin addresses
@@ -22,18 +25,26 @@ type BitcoinParser struct {
 	*bchain.BaseParser
 	Params                      *chaincfg.Params
 	OutputScriptToAddressesFunc OutputScriptToAddressesFunc
+	// version bytes of extended public keys for segwit derivations (e.g. ypub, zpub), 0 if not supported
+	// the version of P2PKH extended public key (xpub) is taken from Params.HDPublicKeyID
+	XPubMagicSegwitP2sh   uint32
+	XPubMagicSegwitNative uint32
 }
 
 // NewBitcoinParser returns new BitcoinParser instance
 func NewBitcoinParser(params *chaincfg.Params, c *Configuration) *BitcoinParser {
-	return &BitcoinParser{
-		&bchain.BaseParser{
+	p := &BitcoinParser{
+		BaseParser: &bchain.BaseParser{
 			AddressFactory:       bchain.NewBaseAddress,
 			BlockAddressesToKeep: c.BlockAddressesToKeep,
+			AmountDecimalPoint:   8,
 		},
-		params,
-		outputScriptToAddresses,
+		Params:                      params,
+		OutputScriptToAddressesFunc: outputScriptToAddresses,
+		XPubMagicSegwitP2sh:         c.XPubMagicSegwitP2sh,
+		XPubMagicSegwitNative:       c.XPubMagicSegwitNative,
 	}
+	return p
 }
 
 // GetChainParams contains network parameters for the main Bitcoin network,
@@ -90,6 +101,85 @@ func outputScriptToAddresses(script []byte, params *chaincfg.Params) ([]string,
 	return rv, nil
 }
 
+type xpubType int
+
+const (
+	xpubP2PKH xpubType = iota
+	xpubSegwitP2sh
+	xpubSegwitNative
+)
+
+func (p *BitcoinParser) getXpubType(xpub string) (xpubType, error) {
+	// the version is in the first 4 bytes of the serialized key, the key was already validated by hdkeychain
+	version := binary.BigEndian.Uint32(base58.Decode(xpub)[:4])
+	switch {
+	case version == binary.BigEndian.Uint32(p.Params.HDPublicKeyID[:]):
+		return xpubP2PKH, nil
+	case p.XPubMagicSegwitP2sh != 0 && version == p.XPubMagicSegwitP2sh:
+		return xpubSegwitP2sh, nil
+	case p.XPubMagicSegwitNative != 0 && version == p.XPubMagicSegwitNative:
+		return xpubSegwitNative, nil
+	}
+	return 0, errors.Errorf("Unsupported extended public key version %x", version)
+}
+
+// DeriveAddrIDsFromXpub derives addrIDs (output scripts) of addresses change/fromIndex..change/toIndex-1
+// from the extended public key. The type of the addresses (P2PKH, P2SH-P2WPKH, P2WPKH) is given by the version of the key.
+func (p *BitcoinParser) DeriveAddrIDsFromXpub(xpub string, change uint32, fromIndex uint32, toIndex uint32) ([][]byte, error) {
+	if toIndex <= fromIndex {
+		return nil, errors.New("toIndex<=fromIndex")
+	}
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, err
+	}
+	if key.IsPrivate() {
+		return nil, errors.New("Private extended key is not supported")
+	}
+	t, err := p.getXpubType(xpub)
+	if err != nil {
+		return nil, err
+	}
+	changeKey, err := key.Child(change)
+	if err != nil {
+		return nil, err
+	}
+	addrIDs := make([][]byte, toIndex-fromIndex)
+	for index := fromIndex; index < toIndex; index++ {
+		indexKey, err := changeKey.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := indexKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		pkHash := btcutil.Hash160(pubKey.SerializeCompressed())
+		var addr btcutil.Address
+		switch t {
+		case xpubP2PKH:
+			addr, err = btcutil.NewAddressPubKeyHash(pkHash, p.Params)
+		case xpubSegwitP2sh:
+			// P2SH-P2WPKH, the redeem script is the P2WPKH script
+			var redeemScript []byte
+			redeemScript, err = txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(pkHash).Script()
+			if err == nil {
+				addr, err = btcutil.NewAddressScriptHash(redeemScript, p.Params)
+			}
+		case xpubSegwitNative:
+			addr, err = btcutil.NewAddressWitnessPubKeyHash(pkHash, p.Params)
+		}
+		if err != nil {
+			return nil, err
+		}
+		addrIDs[index-fromIndex], err = txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return addrIDs, nil
+}
+
 func (p *BitcoinParser) TxFromMsgTx(t *wire.MsgTx, parseAddresses bool) bchain.Tx {
 	vin := make([]bchain.Vin, len(t.TxIn))
 	for i, in := range t.TxIn {
@@ -124,10 +214,10 @@ func (p *BitcoinParser) TxFromMsgTx(t *wire.MsgTx, parseAddresses bool) bchain.T
 			// missing: Type,
 		}
 		vout[i] = bchain.Vout{
-			Value:        float64(out.Value) / 1E8,
 			N:            uint32(i),
 			ScriptPubKey: s,
 		}
+		vout[i].ValueSat.SetInt64(out.Value)
 	}
 	tx := bchain.Tx{
 		Txid:     t.TxHash().String(),
@@ -180,7 +270,13 @@ func (p *BitcoinParser) ParseBlock(b []byte) (*bchain.Block, error) {
 		txs[ti] = p.TxFromMsgTx(t, false)
 	}
 
-	return &bchain.Block{Txs: txs}, nil
+	return &bchain.Block{
+		BlockHeader: bchain.BlockHeader{
+			Size: len(b),
+			Time: w.Header.Timestamp.Unix(),
+		},
+		Txs: txs,
+	}, nil
 }
 
 // PackTx packs transaction to byte array
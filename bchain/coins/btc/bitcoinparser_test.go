@@ -5,6 +5,7 @@ package btc
 import (
 	"blockbook/bchain"
 	"encoding/hex"
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -111,6 +112,75 @@ func TestOutputScriptToAddresses(t *testing.T) {
 	}
 }
 
+func TestDeriveAddrIDsFromXpub(t *testing.T) {
+	// test vectors from BIP84
+	zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+	type args struct {
+		xpub      string
+		change    uint32
+		fromIndex uint32
+		toIndex   uint32
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "receive",
+			args: args{xpub: zpub, change: 0, fromIndex: 0, toIndex: 2},
+			want: []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+		},
+		{
+			name: "change",
+			args: args{xpub: zpub, change: 1, fromIndex: 0, toIndex: 1},
+			want: []string{"bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		},
+		{
+			name:    "invalid range",
+			args:    args{xpub: zpub, change: 0, fromIndex: 1, toIndex: 1},
+			wantErr: true,
+		},
+		{
+			name:    "invalid xpub",
+			args:    args{xpub: "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYt", change: 0, fromIndex: 0, toIndex: 1},
+			wantErr: true,
+		},
+	}
+	parser := NewBitcoinParser(GetChainParams("main"), &Configuration{XPubMagicSegwitP2sh: 0x049d7cb2, XPubMagicSegwitNative: 0x04b24746})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.DeriveAddrIDsFromXpub(tt.args.xpub, tt.args.change, tt.args.fromIndex, tt.args.toIndex)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeriveAddrIDsFromXpub() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			addrs := make([]string, len(got))
+			for i, addrID := range got {
+				a, err := parser.OutputScriptToAddresses(addrID)
+				if err != nil || len(a) != 1 {
+					t.Fatalf("OutputScriptToAddresses() = %v, error %v", a, err)
+				}
+				addrs[i] = a[0]
+			}
+			if !reflect.DeepEqual(addrs, tt.want) {
+				t.Errorf("DeriveAddrIDsFromXpub() = %v, want %v", addrs, tt.want)
+			}
+		})
+	}
+
+	// segwit extended public keys are not supported by the coins without the configured version bytes
+	parser = NewBitcoinParser(GetChainParams("main"), &Configuration{})
+	if _, err := parser.DeriveAddrIDsFromXpub(zpub, 0, 0, 1); err == nil {
+		t.Error("DeriveAddrIDsFromXpub() expected error for unsupported version of extended public key")
+	}
+}
+
 var (
 	testTx1, testTx2 bchain.Tx
 
@@ -152,8 +222,8 @@ func init() {
 		},
 		Vout: []bchain.Vout{
 			{
-				Value: 0.00038812,
-				N:     0,
+				ValueSat: *big.NewInt(38812),
+				N:        0,
 				ScriptPubKey: bchain.ScriptPubKey{
 					Hex: "a9146144d57c8aff48492c9dfb914e120b20bad72d6f87",
 					Addresses: []string{
@@ -183,8 +253,8 @@ func init() {
 		},
 		Vout: []bchain.Vout{
 			{
-				Value: .1,
-				N:     0,
+				ValueSat: *big.NewInt(10000000),
+				N:        0,
 				ScriptPubKey: bchain.ScriptPubKey{
 					Hex: "a914cd668d781ece600efa4b2404dc91fd26b8b8aed887",
 					Addresses: []string{
@@ -194,8 +264,8 @@ func init() {
 				Address: addr2,
 			},
 			{
-				Value: 9.20081157,
-				N:     1,
+				ValueSat: *big.NewInt(920081157),
+				N:        1,
 				ScriptPubKey: bchain.ScriptPubKey{
 					Hex: "a914246655bdbd54c7e477d0ea2375e86e0db2b8f80a87",
 					Addresses: []string{
@@ -8,8 +8,11 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/wire"
@@ -21,7 +24,7 @@ import (
 // BitcoinRPC is an interface to JSON-RPC bitcoind service.
 type BitcoinRPC struct {
 	client       http.Client
-	rpcURL       string
+	endpoints    *bchain.RPCEndpoints
 	user         string
 	password     string
 	Parser       bchain.BlockChainParser
@@ -33,6 +36,16 @@ type BitcoinRPC struct {
 	mq           *bchain.MQ
 	ChainConfig  *Configuration
 	RPCMarshaler RPCMarshaler
+	feeEstimator *bchain.FeeRateEstimator
+	chanRawTx    chan *bchain.Tx
+	rawBlocks    *rawBlockCache
+	// healthCheckDone stops the health checks of the endpoints
+	healthCheckDone chan struct{}
+	// observeLatency reports the latency of the batches
+	observeLatency func(method string, start time.Time, err error)
+	// blockBatchPending are the heights of the blocks being fetched in a batch, the channel is closed when the batch is done
+	blockBatchPending map[uint32]chan struct{}
+	blockBatchMux     sync.Mutex
 }
 
 type Configuration struct {
@@ -49,8 +62,48 @@ type Configuration struct {
 	MempoolWorkers       int    `json:"mempool_workers"`
 	MempoolSubWorkers    int    `json:"mempool_sub_workers"`
 	AddressFormat        string `json:"address_format"`
+	// FeeEstimator selects the source of the fee estimates, FeeEstimatorBackend (default), FeeEstimatorFallback or FeeEstimatorBlockbook
+	FeeEstimator string `json:"fee_estimator"`
+	// FeeEstimatorBlocks is the number of the last blocks used by the blockbook fee estimator, default 25
+	FeeEstimatorBlocks int `json:"fee_estimator_blocks"`
+	// MessageQueueRaw subscribes to rawtx and rawblock (only if parse is set) instead of hashtx and hashblock,
+	// the transactions are then added to mempool and the blocks connected without getting them from the backend
+	MessageQueueRaw bool `json:"message_queue_raw"`
+	// RPCURLs are the urls of additional backends used when the backend at rpc_url is not healthy, they must accept rpc_user and rpc_pass,
+	// the message queue stays bound to message_queue_binding of the backend at rpc_url, while another backend is active,
+	// the notifications may be missing and the synchronization is triggered by each health check instead
+	RPCURLs []string `json:"rpc_urls"`
+	// RPCMaxHeightLag is the number of blocks which a healthy backend can lag behind the best backend, default 2
+	RPCMaxHeightLag int `json:"rpc_max_height_lag"`
+	// RPCMaxErrorRate is the maximal rate of failed requests of a healthy backend, default 0.1
+	RPCMaxErrorRate float64 `json:"rpc_max_error_rate"`
+	// RPCHealthCheckPeriod is the period of the health checks of the backends in seconds, default 10
+	RPCHealthCheckPeriod int `json:"rpc_health_check_period"`
+	// MempoolBatchSize is the number of the input transactions requested in one JSON-RPC batch by the mempool synchronization,
+	// default 1 (no batching)
+	MempoolBatchSize int `json:"mempool_batch_size"`
+	// BlockBatchSize is the number of the following blocks requested in one JSON-RPC batch by the block synchronization,
+	// default 1 (no batching), the blocks are kept in memory until they are connected
+	BlockBatchSize int `json:"block_batch_size"`
+	// XPubMagicSegwitP2sh and XPubMagicSegwitNative are the version bytes of the extended public keys of the coin
+	// for P2SH-P2WPKH (ypub) and P2WPKH (zpub) derivations, the keys are rejected as unsupported if not set
+	XPubMagicSegwitP2sh   uint32 `json:"xpub_magic_segwit_p2sh"`
+	XPubMagicSegwitNative uint32 `json:"xpub_magic_segwit_native"`
 }
 
+const (
+	// FeeEstimatorBackend returns the fee estimates of the backend
+	FeeEstimatorBackend = "backend"
+	// FeeEstimatorFallback uses the blockbook fee estimator if the backend does not return a valid estimate
+	FeeEstimatorFallback = "fallback"
+	// FeeEstimatorBlockbook uses the blockbook fee estimator, the backend is used until the estimator has data
+	FeeEstimatorBlockbook = "blockbook"
+)
+
+const defaultFeeEstimatorBlocks = 25
+
+const defaultRPCMaxHeightLag = 2
+
 // NewBitcoinRPC returns new BitcoinRPC instance.
 func NewBitcoinRPC(config json.RawMessage, pushHandler func(bchain.NotificationType)) (bchain.BlockChain, error) {
 	var err error
@@ -64,12 +117,37 @@ func NewBitcoinRPC(config json.RawMessage, pushHandler func(bchain.NotificationT
 		c.BlockAddressesToKeep = 100
 	}
 	// at least 1 mempool worker/subworker for synchronous mempool synchronization
+	switch c.FeeEstimator {
+	case "":
+		c.FeeEstimator = FeeEstimatorBackend
+	case FeeEstimatorBackend, FeeEstimatorFallback, FeeEstimatorBlockbook:
+	default:
+		return nil, errors.Errorf("Invalid fee_estimator %v", c.FeeEstimator)
+	}
+	if c.FeeEstimatorBlocks < 1 {
+		c.FeeEstimatorBlocks = defaultFeeEstimatorBlocks
+	}
 	if c.MempoolWorkers < 1 {
 		c.MempoolWorkers = 1
 	}
 	if c.MempoolSubWorkers < 1 {
 		c.MempoolSubWorkers = 1
 	}
+	if c.RPCMaxHeightLag < 1 {
+		c.RPCMaxHeightLag = defaultRPCMaxHeightLag
+	}
+	if c.RPCMaxErrorRate <= 0 {
+		c.RPCMaxErrorRate = bchain.DefaultRPCMaxErrorRate
+	}
+	if c.RPCHealthCheckPeriod < 1 {
+		c.RPCHealthCheckPeriod = bchain.DefaultRPCHealthCheckPeriod
+	}
+	if c.MempoolBatchSize < 1 {
+		c.MempoolBatchSize = 1
+	}
+	if c.BlockBatchSize < 1 {
+		c.BlockBatchSize = 1
+	}
 
 	transport := &http.Transport{
 		Dial:                (&net.Dialer{KeepAlive: 600 * time.Second}).Dial,
@@ -79,7 +157,7 @@ func NewBitcoinRPC(config json.RawMessage, pushHandler func(bchain.NotificationT
 
 	s := &BitcoinRPC{
 		client:       http.Client{Timeout: time.Duration(c.RPCTimeout) * time.Second, Transport: transport},
-		rpcURL:       c.RPCURL,
+		endpoints:    bchain.NewRPCEndpoints(append([]string{c.RPCURL}, c.RPCURLs...), uint32(c.RPCMaxHeightLag), c.RPCMaxErrorRate),
 		user:         c.RPCUser,
 		password:     c.RPCPass,
 		ParseBlocks:  c.Parse,
@@ -93,7 +171,7 @@ func NewBitcoinRPC(config json.RawMessage, pushHandler func(bchain.NotificationT
 
 // GetChainInfoAndInitializeMempool is called by Initialize and reused by other coins
 // it contacts the blockchain rpc interface for the first time
-// and if successful it connects to ZeroMQ and creates mempool handler
+// and if successful it creates mempool handler, the message queue is connected later by InitializeMQ
 func (b *BitcoinRPC) GetChainInfoAndInitializeMempool(bc bchain.BlockChain) (string, error) {
 	// try to connect to block chain and get some info
 	chainName, err := bc.GetBlockChainInfo()
@@ -101,16 +179,96 @@ func (b *BitcoinRPC) GetChainInfoAndInitializeMempool(bc bchain.BlockChain) (str
 		return "", err
 	}
 
-	mq, err := bchain.NewMQ(b.ChainConfig.MessageQueueBinding, b.pushHandler)
+	rawBlock := b.ChainConfig.MessageQueueRaw && b.ParseBlocks
+	if b.ChainConfig.MessageQueueRaw {
+		b.chanRawTx = make(chan *bchain.Tx, rawTxQueueLen)
+	}
+	if rawBlock || b.ChainConfig.BlockBatchSize > 1 {
+		size := rawBlocksToKeep
+		if b.ChainConfig.BlockBatchSize > 1 {
+			// keep the prefetched blocks until they are connected
+			size += 2 * b.ChainConfig.BlockBatchSize
+			b.blockBatchPending = make(map[uint32]chan struct{})
+		}
+		b.rawBlocks = newRawBlockCache(size)
+	}
+	if b.endpoints.Len() > 1 && b.healthCheckDone == nil {
+		b.healthCheckDone = make(chan struct{})
+		go b.endpoints.RunHealthChecks(time.Duration(b.ChainConfig.RPCHealthCheckPeriod)*time.Second, b.getEndpointHeight, b.onHealthCheck, b.healthCheckDone)
+	}
+
+	b.Mempool = bchain.NewUTXOMempool(bc, b.ChainConfig.MempoolWorkers, b.ChainConfig.MempoolSubWorkers)
+	if b.chanRawTx != nil {
+		go b.addRawTxsToMempool()
+	}
+	if b.ChainConfig.FeeEstimator != FeeEstimatorBackend {
+		b.feeEstimator = bchain.NewFeeRateEstimator(b.ChainConfig.FeeEstimatorBlocks)
+		b.Mempool.EnableFeeEstimation(b.feeEstimator)
+		glog.Info("rpc: fee estimator ", b.ChainConfig.FeeEstimator, ", using ", b.ChainConfig.FeeEstimatorBlocks, " blocks")
+	}
+	if b.ChainConfig.MempoolBatchSize > 1 {
+		b.Mempool.EnableBatching(b.ChainConfig.MempoolBatchSize)
+	}
+	if b.ChainConfig.BlockBatchSize > 1 {
+		glog.Info("rpc: getting blocks in batches of ", b.ChainConfig.BlockBatchSize)
+	}
+
+	return chainName, nil
+}
+
+// InitializeMQ connects to ZeroMQ, it must be called by Initialize after the parser is created,
+// the parser is used by the handler of the notifications without synchronization
+func (b *BitcoinRPC) InitializeMQ() error {
+	rawBlock := b.ChainConfig.MessageQueueRaw && b.ParseBlocks
+	mq, err := bchain.NewMQ(b.ChainConfig.MessageQueueBinding, b.ChainConfig.MessageQueueRaw, rawBlock, b.onMessage)
 	if err != nil {
 		glog.Error("mq: ", err)
-		return "", err
+		return err
 	}
 	b.mq = mq
+	return nil
+}
 
-	b.Mempool = bchain.NewUTXOMempool(bc, b.ChainConfig.MempoolWorkers, b.ChainConfig.MempoolSubWorkers)
+// rawTxQueueLen is the number of the transactions from the message queue waiting to be added to mempool,
+// if the queue is full, the whole mempool is resynchronized instead
+const rawTxQueueLen = 1000
+
+// onMessage handles the message queue notifications, the raw transactions are added directly to mempool
+// and the raw blocks are kept for GetBlockRaw, other notifications are passed to the push handler
+func (b *BitcoinRPC) onMessage(nt bchain.NotificationType, data []byte) {
+	if b.ChainConfig.MessageQueueRaw {
+		switch nt {
+		case bchain.NotificationNewTx:
+			tx, err := b.Parser.ParseTx(data)
+			if err != nil {
+				glog.Error("mq: rawtx ", err)
+				break
+			}
+			select {
+			case b.chanRawTx <- tx:
+				return
+			default:
+				glog.Warning("mq: rawtx queue is full, resyncing mempool")
+			}
+		case bchain.NotificationNewBlock:
+			if b.rawBlocks != nil {
+				b.rawBlocks.add(data)
+			}
+		}
+	}
+	if b.pushHandler != nil {
+		b.pushHandler(nt)
+	}
+}
 
-	return chainName, nil
+// addRawTxsToMempool adds the transactions from the message queue to mempool
+// the mempool is resynchronized if a transaction cannot be added
+func (b *BitcoinRPC) addRawTxsToMempool() {
+	for tx := range b.chanRawTx {
+		if !b.Mempool.AddTransaction(tx) && b.pushHandler != nil {
+			b.pushHandler(bchain.NotificationNewTx)
+		}
+	}
 }
 
 // Initialize initializes BitcoinRPC instance.
@@ -135,12 +293,20 @@ func (b *BitcoinRPC) Initialize() error {
 		b.Network = "testnet"
 	}
 
+	if err = b.InitializeMQ(); err != nil {
+		return err
+	}
+
 	glog.Info("rpc: block chain ", params.Name)
 
 	return nil
 }
 
 func (b *BitcoinRPC) Shutdown(ctx context.Context) error {
+	if b.healthCheckDone != nil {
+		close(b.healthCheckDone)
+		b.healthCheckDone = nil
+	}
 	if b.mq != nil {
 		if err := b.mq.Shutdown(ctx); err != nil {
 			glog.Error("MQ.Shutdown error: ", err)
@@ -363,6 +529,29 @@ func (b *BitcoinRPC) GetBestBlockHash() (string, error) {
 	return res.Result, nil
 }
 
+// onHealthCheck triggers the synchronization of the index and the mempool if the active endpoint is not the backend at rpc_url,
+// the message queue is bound only to that backend and its notifications may be missing while it is not healthy
+func (b *BitcoinRPC) onHealthCheck(active *bchain.RPCEndpoint) {
+	if active.Index != 0 && b.pushHandler != nil {
+		glog.V(1).Info("rpc: using endpoint ", active.URL, ", synchronizing without the message queue")
+		b.pushHandler(bchain.NotificationNewBlock)
+		b.pushHandler(bchain.NotificationNewTx)
+	}
+}
+
+// getEndpointHeight returns the best height of the backend endpoint, it is used by the health checks
+func (b *BitcoinRPC) getEndpointHeight(ep *bchain.RPCEndpoint) (uint32, error) {
+	res := ResGetBlockCount{}
+	req := CmdGetBlockCount{Method: "getblockcount"}
+	if _, err := b.call(ep.URL, &req, &res); err != nil {
+		return 0, err
+	}
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	return res.Result, nil
+}
+
 // GetBestBlockHeight returns height of the tip of the best-block-chain.
 func (b *BitcoinRPC) GetBestBlockHeight() (uint32, error) {
 	glog.V(1).Info("rpc: getblockcount")
@@ -473,6 +662,8 @@ func (b *BitcoinRPC) GetBlock(hash string, height uint32) (*bchain.Block, error)
 	if err != nil {
 		return nil, errors.Annotatef(err, "hash %v", hash)
 	}
+	// size is not returned by getblockheader
+	header.Size = block.Size
 	block.BlockHeader = *header
 	return block, nil
 }
@@ -480,7 +671,7 @@ func (b *BitcoinRPC) GetBlock(hash string, height uint32) (*bchain.Block, error)
 // getBlockWithoutHeader is an optimization - it does not call GetBlockHeader to get prev, next hashes
 // instead it sets to header only block hash and height passed in parameters
 func (b *BitcoinRPC) GetBlockWithoutHeader(hash string, height uint32) (*bchain.Block, error) {
-	data, err := b.GetBlockRaw(hash)
+	data, err := b.getBlockRawBatch(hash, height)
 	if err != nil {
 		return nil, err
 	}
@@ -493,8 +684,106 @@ func (b *BitcoinRPC) GetBlockWithoutHeader(hash string, height uint32) (*bchain.
 	return block, nil
 }
 
+// getBlockRawBatch returns block with given hash and height as bytes, if block_batch_size is set,
+// the following blocks are requested in the same batch and kept in the raw block cache for the next calls
+func (b *BitcoinRPC) getBlockRawBatch(hash string, height uint32) ([]byte, error) {
+	if b.blockBatchPending == nil {
+		return b.GetBlockRaw(hash)
+	}
+	if data := b.rawBlocks.get(hash); data != nil {
+		return data, nil
+	}
+	b.blockBatchMux.Lock()
+	if done, found := b.blockBatchPending[height]; found {
+		// the block is being fetched by another batch, wait for it and get it from the cache
+		b.blockBatchMux.Unlock()
+		<-done
+		return b.GetBlockRaw(hash)
+	}
+	done := make(chan struct{})
+	heights := make([]uint32, 0, b.ChainConfig.BlockBatchSize)
+	for h := height; len(heights) < b.ChainConfig.BlockBatchSize; h++ {
+		if _, found := b.blockBatchPending[h]; found {
+			break
+		}
+		b.blockBatchPending[h] = done
+		heights = append(heights, h)
+	}
+	b.blockBatchMux.Unlock()
+	defer func() {
+		b.blockBatchMux.Lock()
+		for _, h := range heights {
+			delete(b.blockBatchPending, h)
+		}
+		b.blockBatchMux.Unlock()
+		close(done)
+	}()
+	hashes := []string{hash}
+	if len(heights) > 1 {
+		reqs := make([]interface{}, len(heights)-1)
+		ress := make([]interface{}, len(heights)-1)
+		for i := range reqs {
+			req := CmdGetBlockHash{Method: "getblockhash"}
+			req.Params.Height = heights[i+1]
+			reqs[i] = &req
+			ress[i] = &ResGetBlockHash{}
+		}
+		glog.V(1).Info("rpc: getblockhash batch ", heights[1], "-", heights[len(heights)-1])
+		if err := b.CallBatch("BatchGetBlockHash", reqs, ress); err != nil {
+			glog.Warning("rpc: getblockhash batch ", err)
+		} else {
+			// the batch may reach beyond the best block
+			for _, r := range ress {
+				res := r.(*ResGetBlockHash)
+				if res.Error != nil {
+					break
+				}
+				hashes = append(hashes, res.Result)
+			}
+		}
+	}
+	reqs := make([]interface{}, len(hashes))
+	ress := make([]interface{}, len(hashes))
+	for i := range hashes {
+		req := CmdGetBlock{Method: "getblock"}
+		req.Params.BlockHash = hashes[i]
+		req.Params.Verbosity = 0
+		reqs[i] = &req
+		ress[i] = &ResGetBlockRaw{}
+	}
+	glog.V(1).Info("rpc: getblock (verbosity=0) batch ", height, "-", height+uint32(len(hashes))-1)
+	if err := b.CallBatch("BatchGetBlock", reqs, ress); err != nil {
+		return nil, errors.Annotatef(err, "hash %v", hash)
+	}
+	for i := 1; i < len(hashes); i++ {
+		res := ress[i].(*ResGetBlockRaw)
+		if res.Error != nil {
+			continue
+		}
+		data, err := hex.DecodeString(res.Result)
+		if err != nil {
+			continue
+		}
+		b.rawBlocks.put(hashes[i], data)
+	}
+	res := ress[0].(*ResGetBlockRaw)
+	if res.Error != nil {
+		if isErrBlockNotFound(res.Error) {
+			return nil, bchain.ErrBlockNotFound
+		}
+		return nil, errors.Annotatef(res.Error, "hash %v", hash)
+	}
+	return hex.DecodeString(res.Result)
+}
+
 // GetBlockRaw returns block with given hash as bytes.
 func (b *BitcoinRPC) GetBlockRaw(hash string) ([]byte, error) {
+	if b.rawBlocks != nil {
+		if data := b.rawBlocks.get(hash); data != nil {
+			glog.V(1).Info("mq: raw block ", hash)
+			return data, nil
+		}
+	}
 	glog.V(1).Info("rpc: getblock (verbosity=0) ", hash)
 
 	res := ResGetBlockRaw{}
@@ -537,6 +826,28 @@ func (b *BitcoinRPC) GetBlockFull(hash string) (*bchain.Block, error) {
 	return &res.Result, nil
 }
 
+// GetBlockTxids returns the txids of the transactions of the block with given hash.
+func (b *BitcoinRPC) GetBlockTxids(hash string) ([]string, error) {
+	glog.V(1).Info("rpc: getblock (verbosity=1) ", hash)
+
+	res := ResGetBlockThin{}
+	req := CmdGetBlock{Method: "getblock"}
+	req.Params.BlockHash = hash
+	req.Params.Verbosity = 1
+	err := b.Call(&req, &res)
+
+	if err != nil {
+		return nil, errors.Annotatef(err, "hash %v", hash)
+	}
+	if res.Error != nil {
+		if isErrBlockNotFound(res.Error) {
+			return nil, bchain.ErrBlockNotFound
+		}
+		return nil, errors.Annotatef(res.Error, "hash %v", hash)
+	}
+	return res.Result.Txids, nil
+}
+
 // GetMempool returns transactions in mempool.
 func (b *BitcoinRPC) GetMempool() ([]string, error) {
 	glog.V(1).Info("rpc: getrawmempool")
@@ -554,6 +865,52 @@ func (b *BitcoinRPC) GetMempool() ([]string, error) {
 	return res.Result, nil
 }
 
+// GetTransactionsForMempool returns the transactions by the transaction IDs requested in batches of mempool_batch_size,
+// the transactions which could not be returned are nil
+func (b *BitcoinRPC) GetTransactionsForMempool(txids []string) ([]*bchain.Tx, error) {
+	glog.V(1).Info("rpc: getrawtransaction nonverbose batch of ", len(txids))
+
+	txs := make([]*bchain.Tx, len(txids))
+	for from := 0; from < len(txids); from += b.ChainConfig.MempoolBatchSize {
+		to := from + b.ChainConfig.MempoolBatchSize
+		if to > len(txids) {
+			to = len(txids)
+		}
+		reqs := make([]interface{}, to-from)
+		ress := make([]interface{}, to-from)
+		for i := range reqs {
+			req := CmdGetRawTransaction{Method: "getrawtransaction"}
+			req.Params.Txid = txids[from+i]
+			req.Params.Verbose = false
+			reqs[i] = &req
+			ress[i] = &ResGetRawTransactionNonverbose{}
+		}
+		if err := b.CallBatch("BatchGetRawTransaction", reqs, ress); err != nil {
+			return nil, err
+		}
+		for i, r := range ress {
+			txid := txids[from+i]
+			res := r.(*ResGetRawTransactionNonverbose)
+			if res.Error != nil {
+				glog.Error("cannot get transaction ", txid, ": ", res.Error)
+				continue
+			}
+			data, err := hex.DecodeString(res.Result)
+			if err != nil {
+				glog.Error("cannot get transaction ", txid, ": ", err)
+				continue
+			}
+			tx, err := b.Parser.ParseTx(data)
+			if err != nil {
+				glog.Error("cannot get transaction ", txid, ": ", err)
+				continue
+			}
+			txs[from+i] = tx
+		}
+	}
+	return txs, nil
+}
+
 // GetTransactionForMempool returns a transaction by the transaction ID.
 // It could be optimized for mempool, i.e. without block time and confirmations
 func (b *BitcoinRPC) GetTransactionForMempool(txid string) (*bchain.Tx, error) {
@@ -601,14 +958,33 @@ func (b *BitcoinRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 	if err != nil {
 		return nil, errors.Annotatef(err, "txid %v", txid)
 	}
+	// backend does not return time of mempool transactions, use the time when the transaction was first seen
+	if tx.Confirmations == 0 && tx.Time == 0 && b.Mempool != nil {
+		tx.Time = int64(b.Mempool.GetFirstSeen(txid))
+	}
 	return tx, nil
 }
 
 // ResyncMempool gets mempool transactions and maps output scripts to transactions.
 // ResyncMempool is not reentrant, it should be called from a single thread.
 // It returns number of transactions in mempool
-func (b *BitcoinRPC) ResyncMempool(onNewTxAddr func(txid string, addr string)) (int, error) {
-	return b.Mempool.Resync(onNewTxAddr)
+func (b *BitcoinRPC) ResyncMempool(onNewTxAddr func(txid string, addr string), onReplacedTx func(txid string, replacedBy string)) (int, error) {
+	return b.Mempool.Resync(onNewTxAddr, onReplacedTx)
+}
+
+// GetMempoolReplacedBy returns the transaction which replaced the mempool transaction, empty string if not known
+func (b *BitcoinRPC) GetMempoolReplacedBy(txid string) (string, error) {
+	return b.Mempool.GetReplacedBy(txid), nil
+}
+
+// PackMempoolState returns the mempool state to be persisted across restarts
+func (b *BitcoinRPC) PackMempoolState() ([]byte, error) {
+	return b.Mempool.Pack()
+}
+
+// UnpackMempoolState restores the persisted mempool state, only the difference is then fetched by ResyncMempool
+func (b *BitcoinRPC) UnpackMempoolState(buf []byte) error {
+	return b.Mempool.Unpack(buf)
 }
 
 // GetMempoolTransactions returns slice of mempool transactions for given address.
@@ -616,8 +992,75 @@ func (b *BitcoinRPC) GetMempoolTransactions(address string) ([]string, error) {
 	return b.Mempool.GetTransactions(address)
 }
 
+// GetErc20ContractBalance is not supported by bitcoin type coins
+func (b *BitcoinRPC) GetErc20ContractBalance(address, contract string) (*big.Int, error) {
+	return nil, errors.New("GetErc20ContractBalance: not supported")
+}
+
+// GetAccountState is not supported by bitcoin type coins
+func (b *BitcoinRPC) GetAccountState(address string, height int64) (*bchain.AccountState, error) {
+	return nil, errors.New("GetAccountState: not supported")
+}
+
+// EstimateFeeLevels returns the fee rates in satoshi per kB computed by the blockbook fee estimator
+func (b *BitcoinRPC) EstimateFeeLevels(blocks int) (*bchain.FeeLevels, error) {
+	if b.feeEstimator == nil {
+		return nil, errors.New("EstimateFeeLevels: fee estimator is not enabled")
+	}
+	fl := b.feeEstimator.Estimate(blocks, b.Mempool.GetFeeRateSamples())
+	if fl == nil {
+		return nil, errors.New("EstimateFeeLevels: not enough data")
+	}
+	return fl, nil
+}
+
+// estimateFeeWithEstimator returns the fee per kB in coins from the backend or from the blockbook fee estimator,
+// according to the configured fee_estimator
+func (b *BitcoinRPC) estimateFeeWithEstimator(blocks int, backend func() (float64, error)) (float64, error) {
+	own := func() (float64, bool) {
+		fl, err := b.EstimateFeeLevels(blocks)
+		if err != nil {
+			glog.V(1).Info("rpc: ", err)
+			return 0, false
+		}
+		fee, err := strconv.ParseFloat(b.Parser.AmountToDecimalString(&fl.Standard), 64)
+		if err != nil {
+			return 0, false
+		}
+		return fee, true
+	}
+	if b.ChainConfig.FeeEstimator == FeeEstimatorBlockbook {
+		if fee, ok := own(); ok {
+			return fee, nil
+		}
+	}
+	fee, err := backend()
+	if b.ChainConfig.FeeEstimator == FeeEstimatorFallback && (err != nil || fee <= 0) {
+		if f, ok := own(); ok {
+			return f, nil
+		}
+	}
+	return fee, err
+}
+
+// GetMempoolSpendingTx returns the mempool transaction and its input spending the given output, empty txid if the output is not spent in mempool.
+func (b *BitcoinRPC) GetMempoolSpendingTx(txid string, vout uint32) (string, int, error) {
+	return b.Mempool.GetSpendingTx(txid, vout)
+}
+
+// GetMempoolStats returns the statistics of the mempool
+func (b *BitcoinRPC) GetMempoolStats() (*bchain.MempoolStats, error) {
+	return b.Mempool.GetStats(), nil
+}
+
 // EstimateSmartFee returns fee estimation.
 func (b *BitcoinRPC) EstimateSmartFee(blocks int, conservative bool) (float64, error) {
+	return b.estimateFeeWithEstimator(blocks, func() (float64, error) {
+		return b.estimateSmartFee(blocks, conservative)
+	})
+}
+
+func (b *BitcoinRPC) estimateSmartFee(blocks int, conservative bool) (float64, error) {
 	glog.V(1).Info("rpc: estimatesmartfee ", blocks)
 
 	res := ResEstimateSmartFee{}
@@ -641,6 +1084,12 @@ func (b *BitcoinRPC) EstimateSmartFee(blocks int, conservative bool) (float64, e
 
 // EstimateFee returns fee estimation.
 func (b *BitcoinRPC) EstimateFee(blocks int) (float64, error) {
+	return b.estimateFeeWithEstimator(blocks, func() (float64, error) {
+		return b.estimateFee(blocks)
+	})
+}
+
+func (b *BitcoinRPC) estimateFee(blocks int) (float64, error) {
 	glog.V(1).Info("rpc: estimatefee ", blocks)
 
 	res := ResEstimateFee{}
@@ -661,18 +1110,34 @@ func (b *BitcoinRPC) EstimateFee(blocks int) (float64, error) {
 func (b *BitcoinRPC) SendRawTransaction(tx string) (string, error) {
 	glog.V(1).Info("rpc: sendrawtransaction")
 
-	res := ResSendRawTransaction{}
+	// broadcast the transaction to all backends, it is successful if at least one backend accepts it
 	req := CmdSendRawTransaction{Method: "sendrawtransaction"}
 	req.Params = []string{tx}
-	err := b.Call(&req, &res)
-
-	if err != nil {
-		return "", err
+	var txid string
+	var firstErr error
+	for _, ep := range b.endpoints.All() {
+		res := ResSendRawTransaction{}
+		_, err := b.call(ep.URL, &req, &res)
+		if err == nil && res.Error != nil {
+			err = res.Error
+		}
+		if err != nil {
+			if b.endpoints.Len() > 1 {
+				glog.Warning("rpc: sendrawtransaction to ", ep.URL, ": ", err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if txid == "" {
+			txid = res.Result
+		}
 	}
-	if res.Error != nil {
-		return "", res.Error
+	if txid == "" {
+		return "", firstErr
 	}
-	return res.Result, nil
+	return txid, nil
 }
 
 // GetMempoolEntry returns mempool data for given transaction
@@ -695,6 +1160,41 @@ func (b *BitcoinRPC) GetMempoolEntry(txid string) (*bchain.MempoolEntry, error)
 	return res.Result, nil
 }
 
+// GetMempoolEntries returns the mempool data of the transactions requested in batches of mempool_batch_size,
+// the entries which could not be returned are nil
+func (b *BitcoinRPC) GetMempoolEntries(txids []string) ([]*bchain.MempoolEntry, error) {
+	glog.V(1).Info("rpc: getmempoolentry batch of ", len(txids))
+
+	entries := make([]*bchain.MempoolEntry, len(txids))
+	for from := 0; from < len(txids); from += b.ChainConfig.MempoolBatchSize {
+		to := from + b.ChainConfig.MempoolBatchSize
+		if to > len(txids) {
+			to = len(txids)
+		}
+		reqs := make([]interface{}, to-from)
+		ress := make([]interface{}, to-from)
+		for i := range reqs {
+			reqs[i] = &CmdGetMempoolEntry{
+				Method: "getmempoolentry",
+				Params: []string{txids[from+i]},
+			}
+			ress[i] = &ResGetMempoolEntry{}
+		}
+		if err := b.CallBatch("BatchGetMempoolEntry", reqs, ress); err != nil {
+			return nil, err
+		}
+		for i, r := range ress {
+			res := r.(*ResGetMempoolEntry)
+			if res.Error != nil {
+				glog.V(1).Info("cannot get mempool entry ", txids[from+i], ": ", res.Error)
+				continue
+			}
+			entries[from+i] = res.Result
+		}
+	}
+	return entries, nil
+}
+
 func safeDecodeResponse(body io.ReadCloser, res interface{}) (err error) {
 	var data []byte
 	defer func() {
@@ -714,14 +1214,70 @@ func safeDecodeResponse(body io.ReadCloser, res interface{}) (err error) {
 	return json.Unmarshal(data, &res)
 }
 
+// Call sends the request to the active backend endpoint,
+// if the backend does not respond, the request is repeated at the next healthy backend
 func (b *BitcoinRPC) Call(req interface{}, res interface{}) error {
-	httpData, err := b.RPCMarshaler.Marshal(req)
+	return b.callEndpoints(func(url string) (bool, error) {
+		return b.call(url, req, res)
+	})
+}
+
+// CallBatch sends the requests in one JSON-RPC batch and decodes the responses to ress,
+// the latency of the batch is reported under the name
+func (b *BitcoinRPC) CallBatch(name string, reqs []interface{}, ress []interface{}) (err error) {
+	if b.observeLatency != nil {
+		defer func(s time.Time) { b.observeLatency(name, s, err) }(time.Now())
+	}
+	httpData, err := marshalBatch(b.RPCMarshaler, reqs)
 	if err != nil {
 		return err
 	}
-	httpReq, err := http.NewRequest("POST", b.rpcURL, bytes.NewBuffer(httpData))
+	return b.callEndpoints(func(url string) (bool, error) {
+		var data json.RawMessage
+		responded, err := b.post(url, httpData, &data)
+		if err != nil {
+			return responded, err
+		}
+		return true, unmarshalBatch(data, ress)
+	})
+}
+
+// SetRPCLatencyObserver sets the function reporting the latency of the batches
+func (b *BitcoinRPC) SetRPCLatencyObserver(observe func(method string, start time.Time, err error)) {
+	b.observeLatency = observe
+}
+
+// callEndpoints calls f with the url of the active endpoint, the next endpoint is tried if the backend did not respond
+func (b *BitcoinRPC) callEndpoints(f func(url string) (bool, error)) error {
+	ep := b.endpoints.Get()
+	for tries := 1; ; tries++ {
+		responded, err := f(ep.URL)
+		if responded || tries >= b.endpoints.Len() {
+			b.endpoints.Observe(ep, err)
+			return err
+		}
+		next := b.endpoints.Fail(ep, err)
+		if next == ep {
+			return err
+		}
+		ep = next
+	}
+}
+
+// call sends the request to the backend at url, it returns false if the backend did not respond
+func (b *BitcoinRPC) call(url string, req interface{}, res interface{}) (bool, error) {
+	httpData, err := b.RPCMarshaler.Marshal(req)
+	if err != nil {
+		return true, err
+	}
+	return b.post(url, httpData, res)
+}
+
+// post sends the marshaled request to the backend at url, it returns false if the backend did not respond
+func (b *BitcoinRPC) post(url string, httpData []byte, res interface{}) (bool, error) {
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(httpData))
 	if err != nil {
-		return err
+		return true, err
 	}
 	httpReq.SetBasicAuth(b.user, b.password)
 	httpRes, err := b.client.Do(httpReq)
@@ -731,18 +1287,18 @@ func (b *BitcoinRPC) Call(req interface{}, res interface{}) error {
 		defer httpRes.Body.Close()
 	}
 	if err != nil {
-		return err
+		return false, err
 	}
 	// if server returns HTTP error code it might not return json with response
 	// handle both cases
 	if httpRes.StatusCode != 200 {
 		err = safeDecodeResponse(httpRes.Body, &res)
 		if err != nil {
-			return errors.Errorf("%v %v", httpRes.Status, err)
+			return true, errors.Errorf("%v %v", httpRes.Status, err)
 		}
-		return nil
+		return true, nil
 	}
-	return safeDecodeResponse(httpRes.Body, &res)
+	return true, safeDecodeResponse(httpRes.Body, &res)
 }
 
 // GetChainParser returns BlockChainParser
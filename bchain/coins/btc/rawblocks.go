@@ -0,0 +1,64 @@
+package btc
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// rawBlocksToKeep is the number of the last blocks received from the message queue kept in rawBlockCache
+const rawBlocksToKeep = 8
+
+// blockHeaderLen is the length of the bitcoin block header, the block hash is the double sha256 hash of the header
+const blockHeaderLen = 80
+
+// rawBlockCache keeps the last serialized blocks received from the message queue or prefetched in a batch
+// so that the blocks can be connected without getting them from the backend
+type rawBlockCache struct {
+	mux    sync.Mutex
+	size   int
+	hashes []string
+	blocks map[string][]byte
+}
+
+// newRawBlockCache creates the cache keeping at most size blocks
+func newRawBlockCache(size int) *rawBlockCache {
+	return &rawBlockCache{size: size, blocks: make(map[string][]byte)}
+}
+
+// rawBlockHash returns the hash of the serialized block, it works only for the coins with the bitcoin block header
+func rawBlockHash(data []byte) (string, bool) {
+	if len(data) < blockHeaderLen {
+		return "", false
+	}
+	return chainhash.DoubleHashH(data[:blockHeaderLen]).String(), true
+}
+
+// add stores the serialized block under its hash computed from the block header
+func (c *rawBlockCache) add(data []byte) {
+	if hash, ok := rawBlockHash(data); ok {
+		c.put(hash, data)
+	}
+}
+
+// put stores the serialized block with given hash and removes the oldest block if there are more than size blocks
+func (c *rawBlockCache) put(hash string, data []byte) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, found := c.blocks[hash]; found {
+		return
+	}
+	c.blocks[hash] = data
+	c.hashes = append(c.hashes, hash)
+	if len(c.hashes) > c.size {
+		delete(c.blocks, c.hashes[0])
+		c.hashes = c.hashes[1:]
+	}
+}
+
+// get returns the serialized block with given hash or nil if the block is not in the cache
+func (c *rawBlockCache) get(hash string) []byte {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.blocks[hash]
+}
@@ -0,0 +1,51 @@
+// +build unittest
+
+package btc
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func Test_rawBlockCache(t *testing.T) {
+	var buf bytes.Buffer
+	if err := chaincfg.MainNetParams.GenesisBlock.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	genesis := buf.Bytes()
+	hash, ok := rawBlockHash(genesis)
+	if !ok || hash != chaincfg.MainNetParams.GenesisHash.String() {
+		t.Errorf("rawBlockHash() = %v, want %v", hash, chaincfg.MainNetParams.GenesisHash.String())
+	}
+	if _, ok = rawBlockHash(genesis[:blockHeaderLen-1]); ok {
+		t.Error("rawBlockHash() of short data succeeded")
+	}
+	c := newRawBlockCache(rawBlocksToKeep)
+	c.add(genesis)
+	if d := c.get(hash); !bytes.Equal(d, genesis) {
+		t.Errorf("get() = %x, want %x", d, genesis)
+	}
+	// add other blocks to push the genesis block out of the cache
+	for i := 0; i < rawBlocksToKeep; i++ {
+		b := make([]byte, blockHeaderLen)
+		copy(b, strconv.Itoa(i))
+		c.add(b)
+	}
+	if d := c.get(hash); d != nil {
+		t.Errorf("get() = %x, want nil", d)
+	}
+	if len(c.blocks) != rawBlocksToKeep || len(c.hashes) != rawBlocksToKeep {
+		t.Errorf("cache has %d blocks and %d hashes, want %d", len(c.blocks), len(c.hashes), rawBlocksToKeep)
+	}
+	// put stores the block under the given hash
+	c.put("prefetched", genesis)
+	if d := c.get("prefetched"); !bytes.Equal(d, genesis) {
+		t.Errorf("get() = %x, want %x", d, genesis)
+	}
+	if len(c.blocks) != rawBlocksToKeep {
+		t.Errorf("cache has %d blocks, want %d", len(c.blocks), rawBlocksToKeep)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"blockbook/bchain/coins/btc"
 	"blockbook/bchain/coins/utils"
 	"bytes"
+	"encoding/binary"
 	"io"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -76,6 +77,9 @@ func GetChainParams(chain string) *chaincfg.Params {
 // see https://github.com/BTCGPU/BTCGPU/wiki/Technical-Spec#block-header
 const headerFixedLength = 44 + (chainhash.HashSize * 3)
 
+// headerTimeOffset is the offset of nTime, it follows nVersion, hashPrevBlock, hashMerkleRoot, nHeight and nReserved
+const headerTimeOffset = 4 + (chainhash.HashSize * 2) + 4 + 28
+
 // ParseBlock parses raw block to our Block struct
 func (p *BGoldParser) ParseBlock(b []byte) (*bchain.Block, error) {
 	r := bytes.NewReader(b)
@@ -95,7 +99,13 @@ func (p *BGoldParser) ParseBlock(b []byte) (*bchain.Block, error) {
 		txs[ti] = p.TxFromMsgTx(t, false)
 	}
 
-	return &bchain.Block{Txs: txs}, nil
+	return &bchain.Block{
+		BlockHeader: bchain.BlockHeader{
+			Size: len(b),
+			Time: int64(binary.LittleEndian.Uint32(b[headerTimeOffset:])),
+		},
+		Txs: txs,
+	}, nil
 }
 
 func skipHeader(r io.ReadSeeker, pver uint32) error {
@@ -12,6 +12,11 @@ import (
 	"testing"
 )
 
+var testParseBlockTimes = map[int]int64{
+	104000: 1295705889,
+	532144: 1528372417,
+}
+
 var testParseBlockTxs = map[int][]string{
 	104000: []string{
 		"331d4ef64118e9e5be75f0f51f1a4c5057550c3320e22ff7206f3e1101f113d0",
@@ -112,6 +117,10 @@ func TestParseBlock(t *testing.T) {
 			t.Fatal(err)
 		}
 
+		if blk.Time != testParseBlockTimes[height] {
+			t.Errorf("ParseBlock() time: got %d, want %d", blk.Time, testParseBlockTimes[height])
+		}
+
 		if len(blk.Txs) != len(txs) {
 			t.Errorf("ParseBlock() number of transactions: got %d, want %d", len(blk.Txs), len(txs))
 		}
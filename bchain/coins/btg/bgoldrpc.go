@@ -48,6 +48,10 @@ func (b *BGoldRPC) Initialize() error {
 		b.Network = "testnet"
 	}
 
+	if err = b.InitializeMQ(); err != nil {
+		return err
+	}
+
 	glog.Info("rpc: block chain ", params.Name)
 
 	return nil
@@ -49,6 +49,10 @@ func (b *DashRPC) Initialize() error {
 		b.Network = "testnet"
 	}
 
+	if err = b.InitializeMQ(); err != nil {
+		return err
+	}
+
 	glog.Info("rpc: block chain ", params.Name)
 
 	return nil
@@ -50,6 +50,10 @@ func (b *DogecoinRPC) Initialize() error {
 		b.Network = "testnet"
 	}
 
+	if err = b.InitializeMQ(); err != nil {
+		return err
+	}
+
 	glog.Info("rpc: block chain ", params.Name)
 
 	return nil
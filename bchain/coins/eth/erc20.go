@@ -0,0 +1,105 @@
+package eth
+
+import (
+	"blockbook/bchain"
+	"context"
+	"math/big"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/juju/errors"
+)
+
+// erc20TransferEventSignature is keccak256 of the event signature Transfer(address,address,uint256)
+const erc20TransferEventSignature = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// erc20BalanceOfSignature is the selector of the method balanceOf(address)
+const erc20BalanceOfSignature = "0x70a08231"
+
+type rpcLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+type rpcReceipt struct {
+	GasUsed           string    `json:"gasUsed"`
+	CumulativeGasUsed string    `json:"cumulativeGasUsed"`
+	Status            string    `json:"status"` // missing in receipts of transactions before Byzantium fork
+	ContractAddress   string    `json:"contractAddress"`
+	Logs              []*rpcLog `json:"logs"`
+}
+
+// addressFromTopic returns the address stored in the last 20 bytes of the 32 bytes topic
+func addressFromTopic(topic string) (string, error) {
+	if !has0xPrefix(topic) || len(topic) != 66 {
+		return "", errors.Errorf("Invalid address topic %v", topic)
+	}
+	return "0x" + strings.ToLower(topic[26:]), nil
+}
+
+// erc20GetTransfersFromLog decodes ERC20 Transfer events from the logs of a transaction
+// ERC721 tokens use the same event signature but have the token id as the third indexed topic, they are skipped
+func erc20GetTransfersFromLog(logs []*rpcLog) ([]bchain.Erc20Transfer, error) {
+	var r []bchain.Erc20Transfer
+	for _, l := range logs {
+		if len(l.Topics) != 3 || l.Topics[0] != erc20TransferEventSignature {
+			continue
+		}
+		from, err := addressFromTopic(l.Topics[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := addressFromTopic(l.Topics[2])
+		if err != nil {
+			return nil, err
+		}
+		var t big.Int
+		if has0xPrefix(l.Data) && len(l.Data) > 2 {
+			if _, ok := t.SetString(l.Data[2:], 16); !ok {
+				return nil, errors.Errorf("Invalid ERC20 transfer value %v", l.Data)
+			}
+		}
+		r = append(r, bchain.Erc20Transfer{
+			Contract: strings.ToLower(l.Address),
+			From:     from,
+			To:       to,
+			Tokens:   t,
+		})
+	}
+	return r, nil
+}
+
+// GetErc20TransfersFromTx returns ERC20 token transfers decoded from the receipt of the transaction
+// mempool transactions do not have receipt, they return no transfers
+func (p *EthereumParser) GetErc20TransfersFromTx(tx *bchain.Tx) ([]bchain.Erc20Transfer, error) {
+	sd, ok := tx.CoinSpecificData.(*ethTxSpecificData)
+	if !ok || sd == nil || sd.Receipt == nil {
+		return nil, nil
+	}
+	return erc20GetTransfersFromLog(sd.Receipt.Logs)
+}
+
+// GetErc20ContractBalance returns the balance of the address in ERC20 tokens of the contract at the tip of the chain
+func (b *EthereumRPC) GetErc20ContractBalance(address, contract string) (*big.Int, error) {
+	if !has0xPrefix(address) || len(address) != 42 {
+		return nil, errors.Errorf("Invalid address %v", address)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	var data string
+	err := b.callContext(ctx, &data, "eth_call", map[string]interface{}{
+		"to":   ethcommon.HexToAddress(contract),
+		"data": erc20BalanceOfSignature + "000000000000000000000000" + address[2:],
+	}, "latest")
+	if err != nil {
+		return nil, errors.Annotatef(err, "address %v, contract %v", address, contract)
+	}
+	var r big.Int
+	if len(data) > 2 {
+		if _, ok := r.SetString(data[2:], 16); !ok {
+			return nil, errors.Errorf("Invalid balance %v of address %v, contract %v", data, address, contract)
+		}
+	}
+	return &r, nil
+}
@@ -0,0 +1,143 @@
+// +build unittest
+
+package eth
+
+import (
+	"blockbook/bchain"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestErc20_erc20GetTransfersFromLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []*rpcLog
+		want    []bchain.Erc20Transfer
+		wantErr bool
+	}{
+		{
+			name: "1",
+			args: []*rpcLog{
+				{
+					Address: "0x76a45e8976499ab9ae223cc584019341d5a84e96",
+					Topics: []string{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x0000000000000000000000002aacf811ac1a60081ea39f7783c0d26c500871a8",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+					},
+					Data: "0x0000000000000000000000000000000000000000000000000000000000000123",
+				},
+			},
+			want: []bchain.Erc20Transfer{
+				{
+					Contract: "0x76a45e8976499ab9ae223cc584019341d5a84e96",
+					From:     "0x2aacf811ac1a60081ea39f7783c0d26c500871a8",
+					To:       "0xe9a5216ff992cfa01594d43501a56e12769eb9d2",
+					Tokens:   *big.NewInt(0x123),
+				},
+			},
+		},
+		{
+			name: "other events and ERC721 transfer",
+			args: []*rpcLog{
+				{
+					Address: "0x0d0f936ee4c93e25944694d6c121de94d9760f11",
+					Topics: []string{
+						"0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925",
+						"0x0000000000000000000000006f44cceb49b4a5812d54b6f494fc2febf25511ed",
+						"0x0000000000000000000000004bda106325c335df99eab7fe363cac8a0ba2a24d",
+					},
+					Data: "0x0000000000000000000000000000000000000000000000000000000000000001",
+				},
+				{
+					Address: "0x06012c8cf97bead5deae237070f9587f8e7a266d",
+					Topics: []string{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x0000000000000000000000006f44cceb49b4a5812d54b6f494fc2febf25511ed",
+						"0x0000000000000000000000004bda106325c335df99eab7fe363cac8a0ba2a24d",
+						"0x00000000000000000000000000000000000000000000000000000000000f4240",
+					},
+					Data: "0x",
+				},
+			},
+		},
+		{
+			name: "invalid address topic",
+			args: []*rpcLog{
+				{
+					Address: "0x76a45e8976499ab9ae223cc584019341d5a84e96",
+					Topics: []string{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x2aacf811ac1a60081ea39f7783c0d26c500871a8",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+					},
+					Data: "0x0000000000000000000000000000000000000000000000000000000000000123",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := erc20GetTransfersFromLog(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("erc20GetTransfersFromLog() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("erc20GetTransfersFromLog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErc20_PackUnpackReceipt(t *testing.T) {
+	p := NewEthereumParser()
+	tx := &bchain.Tx{
+		Blocktime: 1521533434,
+		Hex:       "7b226e6f6e6365223a22307862323663222c226761735072696365223a223078343330653233343030222c22676173223a22307835323038222c22746f223a22307835353565653131666264646330653439613962616233353861383934316164393566666462343866222c2276616c7565223a22307831626330313539643533306536303030222c22696e707574223a223078222c2268617368223a22307863643634373135313535326235313332623261656637633962653030646336663733616663353930316464653135376161623133313333356261616138353362222c22626c6f636b4e756d626572223a223078326263663038222c2266726f6d223a22307833653361336436396463363662613130373337663533316564303838393534613965633839643937222c227472616e73616374696f6e496e646578223a22307861222c2276223a2230783239222c2272223a22307866373136316331373064343335373361643963386437303163646166373134666632613534386135363262306463363339323330643137383839666364343035222c2273223a22307833633439373766633930333835613237656661303033326531376234396664353735623238323663623536653364316563663231353234663261393466393135227d",
+		Txid:      "0xcd647151552b5132b2aef7c9be00dc6f73afc5901dde157aab131335baaa853b",
+		CoinSpecificData: &ethTxSpecificData{Receipt: &rpcReceipt{
+			GasUsed:           "0x8ccd",
+			CumulativeGasUsed: "0x1a6b3f",
+			Status:            "0x1",
+			Logs: []*rpcLog{
+				{
+					Address: "0x76a45e8976499ab9ae223cc584019341d5a84e96",
+					Topics: []string{
+						"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+						"0x0000000000000000000000002aacf811ac1a60081ea39f7783c0d26c500871a8",
+						"0x000000000000000000000000e9a5216ff992cfa01594d43501a56e12769eb9d2",
+					},
+					Data: "0x0000000000000000000000000000000000000000000000000000000000000123",
+				},
+			},
+		}},
+	}
+	want, err := p.GetErc20TransfersFromTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 1 {
+		t.Fatalf("GetErc20TransfersFromTx() returned %d transfers, want 1", len(want))
+	}
+	b, err := p.PackTx(tx, 2871048, 1521533434)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utx, _, err := p.UnpackTx(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(utx.CoinSpecificData, tx.CoinSpecificData) {
+		t.Errorf("UnpackTx() receipt = %+v, want %+v", utx.CoinSpecificData, tx.CoinSpecificData)
+	}
+	got, err := p.GetErc20TransfersFromTx(utx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetErc20TransfersFromTx() = %+v, want %+v", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 
@@ -15,14 +16,22 @@ import (
 	ethcommon "github.com/ethereum/go-ethereum/common"
 )
 
+// EtherAmountDecimalPoint defines number of decimal points in Ether amounts (amounts are stored in Wei)
+const EtherAmountDecimalPoint = 18
+
 // EthereumParser handle
 type EthereumParser struct {
 	*bchain.BaseParser
+	// IndexLogs enables indexing of the event logs of contracts
+	IndexLogs bool
 }
 
 // NewEthereumParser returns new EthereumParser instance
 func NewEthereumParser() *EthereumParser {
-	return &EthereumParser{&bchain.BaseParser{AddressFactory: bchain.NewBaseAddress}}
+	return &EthereumParser{BaseParser: &bchain.BaseParser{
+		AddressFactory:     bchain.NewBaseAddress,
+		AmountDecimalPoint: EtherAmountDecimalPoint,
+	}}
 }
 
 type rpcTransaction struct {
@@ -45,6 +54,7 @@ type rpcTransaction struct {
 
 type rpcBlock struct {
 	Hash         ethcommon.Hash   `json:"hash"`
+	Size         string           `json:"size"`
 	Transactions []rpcTransaction `json:"transactions"`
 	UncleHashes  []ethcommon.Hash `json:"uncles"`
 }
@@ -60,7 +70,16 @@ func ethNumber(n string) (int64, error) {
 	return 0, errors.Errorf("Not a number: '%v'", n)
 }
 
-func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, blocktime int64, confirmations uint32) (*bchain.Tx, error) {
+// ethTxSpecificData are the data of a confirmed transaction, which are not part of rpcTransaction
+// they are stored in bchain.Tx.CoinSpecificData
+type ethTxSpecificData struct {
+	Receipt *rpcReceipt
+	// InternalTransfers are set only if the processing of internal transactions is enabled
+	InternalTransfers []bchain.InternalTransfer
+}
+
+// ethTxToTx converts rpcTransaction to bchain.Tx, the specific data (nil for mempool transactions) are stored in CoinSpecificData
+func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, sd *ethTxSpecificData, blocktime int64, confirmations uint32) (*bchain.Tx, error) {
 	txid := ethHashToHash(tx.Hash)
 	var (
 		fa, ta []string
@@ -70,13 +89,26 @@ func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, blocktime int64, confirma
 	if len(tx.From) > 2 {
 		fa = []string{tx.From}
 	}
-	if len(tx.To) > 2 {
-		ta = []string{tx.To}
-		addr, err = p.AddressFactory(tx.To)
+	to := tx.To
+	// the contract creation transaction sends the value to the created contract
+	if len(to) <= 2 && sd != nil && sd.Receipt != nil && len(sd.Receipt.ContractAddress) > 2 {
+		to = sd.Receipt.ContractAddress
+	}
+	if len(to) > 2 {
+		ta = []string{to}
+		addr, err = p.AddressFactory(to)
 		if err != nil {
 			return nil, err
 		}
 	}
+	var vs big.Int
+	if len(tx.Value) > 2 {
+		v, err := hexutil.DecodeBig(tx.Value)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Value %v", tx.Value)
+		}
+		vs.Set(v)
+	}
 	// temporarily, the complete rpcTransaction without BlockHash is marshalled and hex encoded to bchain.Tx.Hex
 	bh := tx.BlockHash
 	tx.BlockHash = nil
@@ -86,7 +118,7 @@ func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, blocktime int64, confirma
 	}
 	tx.BlockHash = bh
 	h := hex.EncodeToString(b)
-	return &bchain.Tx{
+	btx := &bchain.Tx{
 		Blocktime:     blocktime,
 		Confirmations: confirmations,
 		Hex:           h,
@@ -105,8 +137,8 @@ func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, blocktime int64, confirma
 		},
 		Vout: []bchain.Vout{
 			{
-				N: 0, // there is always up to one To address
-				// Value - cannot be set, it does not fit precisely to float64
+				ValueSat: vs,
+				N:        0, // there is always up to one To address
 				ScriptPubKey: bchain.ScriptPubKey{
 					// Hex
 					Addresses: ta,
@@ -114,7 +146,11 @@ func (p *EthereumParser) ethTxToTx(tx *rpcTransaction, blocktime int64, confirma
 				Address: addr,
 			},
 		},
-	}, nil
+	}
+	if sd != nil {
+		btx.CoinSpecificData = sd
+	}
+	return btx, nil
 }
 
 // GetAddrIDFromVout returns internal address representation of given transaction output
@@ -219,9 +255,212 @@ func (p *EthereumParser) PackTx(tx *bchain.Tx, height uint32, blockTime int64) (
 	if pt.Value, err = hexDecodeBig(r.Value); err != nil {
 		return nil, errors.Annotatef(err, "Value %v", r.Value)
 	}
+	if sd, ok := tx.CoinSpecificData.(*ethTxSpecificData); ok && sd != nil {
+		if sd.Receipt != nil {
+			if pt.Receipt, err = packReceipt(sd.Receipt); err != nil {
+				return nil, err
+			}
+		}
+		if pt.InternalTransfers, err = packInternalTransfers(sd.InternalTransfers); err != nil {
+			return nil, err
+		}
+	}
 	return proto.Marshal(pt)
 }
 
+func packReceipt(receipt *rpcReceipt) (*ProtoTransaction_ReceiptType, error) {
+	var err error
+	pr := &ProtoTransaction_ReceiptType{
+		Log: make([]*ProtoTransaction_ReceiptType_LogType, len(receipt.Logs)),
+	}
+	if receipt.GasUsed != "" {
+		if pr.GasUsed, err = hexDecodeBig(receipt.GasUsed); err != nil {
+			return nil, errors.Annotatef(err, "GasUsed %v", receipt.GasUsed)
+		}
+	}
+	if receipt.CumulativeGasUsed != "" {
+		if pr.CumulativeGasUsed, err = hexDecodeBig(receipt.CumulativeGasUsed); err != nil {
+			return nil, errors.Annotatef(err, "CumulativeGasUsed %v", receipt.CumulativeGasUsed)
+		}
+	}
+	// missing status is stored as empty bytes, to distinguish it from the failure status 0
+	if receipt.Status != "" {
+		s, err := hexutil.DecodeUint64(receipt.Status)
+		if err != nil {
+			return nil, errors.Annotatef(err, "Status %v", receipt.Status)
+		}
+		pr.Status = []byte{byte(s)}
+	}
+	if pr.ContractAddress, err = hexDecode(receipt.ContractAddress); err != nil {
+		return nil, errors.Annotatef(err, "ContractAddress %v", receipt.ContractAddress)
+	}
+	for i, l := range receipt.Logs {
+		pl := &ProtoTransaction_ReceiptType_LogType{
+			Topics: make([][]byte, len(l.Topics)),
+		}
+		if pl.Address, err = hexDecode(l.Address); err != nil {
+			return nil, errors.Annotatef(err, "Log address %v", l.Address)
+		}
+		if pl.Data, err = hexDecode(l.Data); err != nil {
+			return nil, errors.Annotatef(err, "Log data %v", l.Data)
+		}
+		for j, t := range l.Topics {
+			if pl.Topics[j], err = hexDecode(t); err != nil {
+				return nil, errors.Annotatef(err, "Log topic %v", t)
+			}
+		}
+		pr.Log[i] = pl
+	}
+	return pr, nil
+}
+
+func unpackReceipt(pr *ProtoTransaction_ReceiptType) *rpcReceipt {
+	receipt := &rpcReceipt{
+		GasUsed:           hexEncodeBig(pr.GasUsed),
+		CumulativeGasUsed: hexEncodeBig(pr.CumulativeGasUsed),
+		Logs:              make([]*rpcLog, len(pr.Log)),
+	}
+	if len(pr.Status) > 0 {
+		receipt.Status = hexutil.EncodeUint64(uint64(pr.Status[0]))
+	}
+	if len(pr.ContractAddress) > 0 {
+		receipt.ContractAddress = hexutil.Encode(pr.ContractAddress)
+	}
+	for i, pl := range pr.Log {
+		l := &rpcLog{
+			Address: hexutil.Encode(pl.Address),
+			Data:    hexutil.Encode(pl.Data),
+			Topics:  make([]string, len(pl.Topics)),
+		}
+		for j, t := range pl.Topics {
+			l.Topics[j] = hexutil.Encode(t)
+		}
+		receipt.Logs[i] = l
+	}
+	return receipt
+}
+
+func packInternalTransfers(transfers []bchain.InternalTransfer) ([]*ProtoTransaction_InternalTransferType, error) {
+	if len(transfers) == 0 {
+		return nil, nil
+	}
+	var err error
+	pi := make([]*ProtoTransaction_InternalTransferType, len(transfers))
+	for i := range transfers {
+		t := &transfers[i]
+		p := &ProtoTransaction_InternalTransferType{
+			Value: t.Value.Bytes(),
+		}
+		if p.From, err = hexDecode(t.From); err != nil {
+			return nil, errors.Annotatef(err, "Internal transfer from %v", t.From)
+		}
+		if p.To, err = hexDecode(t.To); err != nil {
+			return nil, errors.Annotatef(err, "Internal transfer to %v", t.To)
+		}
+		pi[i] = p
+	}
+	return pi, nil
+}
+
+func unpackInternalTransfers(pi []*ProtoTransaction_InternalTransferType) []bchain.InternalTransfer {
+	if len(pi) == 0 {
+		return nil
+	}
+	transfers := make([]bchain.InternalTransfer, len(pi))
+	for i, p := range pi {
+		t := &transfers[i]
+		t.From = hexutil.Encode(p.From)
+		t.To = hexutil.Encode(p.To)
+		t.Value.SetBytes(p.Value)
+	}
+	return transfers
+}
+
+// IndexContractLogs returns true if the event logs of contracts are to be indexed
+func (p *EthereumParser) IndexContractLogs() bool {
+	return p.IndexLogs
+}
+
+// GetContractLogsFromTx returns the event logs of the transaction from its receipt, mempool transactions have no logs
+func (p *EthereumParser) GetContractLogsFromTx(tx *bchain.Tx) ([]bchain.ContractLog, error) {
+	sd, ok := tx.CoinSpecificData.(*ethTxSpecificData)
+	if !ok || sd == nil || sd.Receipt == nil || len(sd.Receipt.Logs) == 0 {
+		return nil, nil
+	}
+	logs := make([]bchain.ContractLog, len(sd.Receipt.Logs))
+	for i, l := range sd.Receipt.Logs {
+		logs[i] = bchain.ContractLog{
+			Contract: strings.ToLower(l.Address),
+			Topics:   l.Topics,
+			Data:     l.Data,
+		}
+	}
+	return logs, nil
+}
+
+// GetTxFeeRate returns the gas price of the transaction
+func (p *EthereumParser) GetTxFeeRate(tx *bchain.Tx) (*big.Int, error) {
+	b, err := hex.DecodeString(tx.Hex)
+	if err != nil {
+		return nil, err
+	}
+	var r rpcTransaction
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	n, err := hexutil.DecodeBig(r.Price)
+	if err != nil {
+		return nil, errors.Annotatef(err, "GasPrice %v", r.Price)
+	}
+	return n, nil
+}
+
+// GetTxReceipt returns the result of the execution of the transaction, nil for mempool transactions
+func (p *EthereumParser) GetTxReceipt(tx *bchain.Tx) (*bchain.TxReceipt, error) {
+	sd, ok := tx.CoinSpecificData.(*ethTxSpecificData)
+	if !ok || sd == nil || sd.Receipt == nil {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(tx.Hex)
+	if err != nil {
+		return nil, err
+	}
+	var r rpcTransaction
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	tr := &bchain.TxReceipt{
+		Status:          bchain.TxStatusUnknown,
+		ContractAddress: sd.Receipt.ContractAddress,
+	}
+	for _, v := range []struct {
+		name  string
+		value string
+		to    *big.Int
+	}{
+		{"GasLimit", r.GasLimit, &tr.GasLimit},
+		{"GasPrice", r.Price, &tr.GasPrice},
+		{"GasUsed", sd.Receipt.GasUsed, &tr.GasUsed},
+		{"CumulativeGasUsed", sd.Receipt.CumulativeGasUsed, &tr.CumulativeGasUsed},
+	} {
+		if v.value == "" {
+			continue
+		}
+		n, err := hexutil.DecodeBig(v.value)
+		if err != nil {
+			return nil, errors.Annotatef(err, "%v %v", v.name, v.value)
+		}
+		v.to.Set(n)
+	}
+	switch sd.Receipt.Status {
+	case "0x1":
+		tr.Status = bchain.TxStatusOK
+	case "0x0":
+		tr.Status = bchain.TxStatusFailure
+	}
+	return tr, nil
+}
+
 // UnpackTx unpacks transaction from byte array
 func (p *EthereumParser) UnpackTx(buf []byte) (*bchain.Tx, uint32, error) {
 	var pt ProtoTransaction
@@ -244,7 +483,14 @@ func (p *EthereumParser) UnpackTx(buf []byte) (*bchain.Tx, uint32, error) {
 		TransactionIndex: hexutil.EncodeUint64(uint64(pt.TransactionIndex)),
 		Value:            hexEncodeBig(pt.Value),
 	}
-	tx, err := p.ethTxToTx(&r, int64(pt.BlockTime), 0)
+	var sd *ethTxSpecificData
+	if pt.Receipt != nil || len(pt.InternalTransfers) > 0 {
+		sd = &ethTxSpecificData{InternalTransfers: unpackInternalTransfers(pt.InternalTransfers)}
+		if pt.Receipt != nil {
+			sd.Receipt = unpackReceipt(pt.Receipt)
+		}
+	}
+	tx, err := p.ethTxToTx(&r, sd, int64(pt.BlockTime), 0)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -5,6 +5,8 @@ package eth
 import (
 	"blockbook/bchain"
 	"encoding/hex"
+	"encoding/json"
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -208,6 +210,7 @@ func TestEthereumParser_UnpackTx(t *testing.T) {
 				},
 				Vout: []bchain.Vout{
 					{
+						ValueSat: *big.NewInt(1999622000000000000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Addresses: []string{"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f"},
 						},
@@ -239,3 +242,103 @@ func TestEthereumParser_UnpackTx(t *testing.T) {
 		})
 	}
 }
+
+func TestEthereumParser_GetTxReceipt(t *testing.T) {
+	// contract creation transaction without the to address
+	hexTx := "7b226e6f6e6365223a22307832222c226761735072696365223a2230783361396163613030222c22676173223a223078333061643637222c22746f223a22222c2276616c7565223a22307831303030222c22696e707574223a2230783630363036303430222c2268617368223a22307830303030303030303030303030303030303030303030303030303030303030303030303030303030303030303030303030303030303030303030303030303031222c22626c6f636b4e756d626572223a223078326263663038222c2266726f6d223a22307833653361336436396463363662613130373337663533316564303838393534613965633839643937222c227472616e73616374696f6e496e646578223a22307830222c2276223a2230783162222c2272223a22307831222c2273223a22307831227d"
+	tests := []struct {
+		name     string
+		receipt  *rpcReceipt
+		want     *bchain.TxReceipt
+		wantAddr []string
+	}{
+		{
+			name: "failed contract creation",
+			receipt: &rpcReceipt{
+				GasUsed:           "0x30ad67",
+				CumulativeGasUsed: "0x30ad67",
+				Status:            "0x0",
+				ContractAddress:   "0x1234567890123456789012345678901234567890",
+			},
+			want: &bchain.TxReceipt{
+				Status:            bchain.TxStatusFailure,
+				GasLimit:          *big.NewInt(0x30ad67),
+				GasPrice:          *big.NewInt(0x3a9aca00),
+				GasUsed:           *big.NewInt(0x30ad67),
+				CumulativeGasUsed: *big.NewInt(0x30ad67),
+				ContractAddress:   "0x1234567890123456789012345678901234567890",
+			},
+			wantAddr: []string{"0x1234567890123456789012345678901234567890"},
+		},
+		{
+			name: "successful contract creation",
+			receipt: &rpcReceipt{
+				GasUsed:           "0x2a5e4",
+				CumulativeGasUsed: "0x4bc37",
+				Status:            "0x1",
+				ContractAddress:   "0x1234567890123456789012345678901234567890",
+			},
+			want: &bchain.TxReceipt{
+				Status:            bchain.TxStatusOK,
+				GasLimit:          *big.NewInt(0x30ad67),
+				GasPrice:          *big.NewInt(0x3a9aca00),
+				GasUsed:           *big.NewInt(0x2a5e4),
+				CumulativeGasUsed: *big.NewInt(0x4bc37),
+				ContractAddress:   "0x1234567890123456789012345678901234567890",
+			},
+			wantAddr: []string{"0x1234567890123456789012345678901234567890"},
+		},
+		{
+			name: "receipt before Byzantium without status",
+			receipt: &rpcReceipt{
+				GasUsed:           "0x2a5e4",
+				CumulativeGasUsed: "0x4bc37",
+			},
+			want: &bchain.TxReceipt{
+				Status:            bchain.TxStatusUnknown,
+				GasLimit:          *big.NewInt(0x30ad67),
+				GasPrice:          *big.NewInt(0x3a9aca00),
+				GasUsed:           *big.NewInt(0x2a5e4),
+				CumulativeGasUsed: *big.NewInt(0x4bc37),
+			},
+		},
+	}
+	p := NewEthereumParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := hex.DecodeString(hexTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var r rpcTransaction
+			if err = json.Unmarshal(b, &r); err != nil {
+				t.Fatal(err)
+			}
+			tx, err := p.ethTxToTx(&r, &ethTxSpecificData{Receipt: tt.receipt}, 1521533434, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the receipt must survive packing to db
+			packed, err := p.PackTx(tx, 2871048, 1521533434)
+			if err != nil {
+				t.Fatal(err)
+			}
+			utx, _, err := p.UnpackTx(packed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tx := range []*bchain.Tx{tx, utx} {
+				got, err := p.GetTxReceipt(tx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GetTxReceipt() = %+v, want %+v", got, tt.want)
+				}
+				if !reflect.DeepEqual(tx.Vout[0].ScriptPubKey.Addresses, tt.wantAddr) {
+					t.Errorf("Vout addresses = %v, want %v", tx.Vout[0].ScriptPubKey.Addresses, tt.wantAddr)
+				}
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	ethereum "github.com/ethereum/go-ethereum"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -34,12 +35,36 @@ type Configuration struct {
 	CoinShortcut string `json:"coin_shortcut"`
 	RPCURL       string `json:"rpc_url"`
 	RPCTimeout   int    `json:"rpc_timeout"`
+	// ProcessInternalTransactions enables indexing of value transfers made by contracts, the backend must support tracing
+	ProcessInternalTransactions bool `json:"process_internal_transactions"`
+	// InternalTransactionsTracer is TracerGeth (default) or TracerParity
+	InternalTransactionsTracer string `json:"internal_transactions_tracer"`
+	// IndexContractLogs enables the index of the event logs of contracts, the logs are indexed only in the blocks synchronized with the option
+	IndexContractLogs bool `json:"index_contract_logs"`
+	// GasPriceOracleBlocks is the number of the last blocks used by the gas price oracle, default 20, negative disables the oracle
+	GasPriceOracleBlocks int `json:"gas_price_oracle_blocks"`
+	// RPCURLs are the urls of additional backends used when the backend at rpc_url is not healthy
+	RPCURLs []string `json:"rpc_urls"`
+	// RPCMaxHeightLag is the number of blocks which a healthy backend can lag behind the best backend, default 5
+	RPCMaxHeightLag int `json:"rpc_max_height_lag"`
+	// RPCMaxErrorRate is the maximal rate of failed requests of a healthy backend, default 0.1
+	RPCMaxErrorRate float64 `json:"rpc_max_error_rate"`
+	// RPCHealthCheckPeriod is the period of the health checks of the backends in seconds, default 10
+	RPCHealthCheckPeriod int `json:"rpc_health_check_period"`
 }
 
+const defaultGasPriceOracleBlocks = 20
+
+const defaultRPCMaxHeightLag = 5
+
 // EthereumRPC is an interface to JSON-RPC eth service.
 type EthereumRPC struct {
-	client               *ethclient.Client
-	rpc                  *rpc.Client
+	// rpcs are the rpc clients of the endpoints, nil if the backend was not dialed yet, guarded by rpcsMu
+	rpcs                 []*rpc.Client
+	rpcsMu               sync.Mutex
+	endpoints            *bchain.RPCEndpoints
+	pushHandler          func(bchain.NotificationType)
+	healthCheckDone      chan struct{}
 	timeout              time.Duration
 	rpcURL               string
 	Parser               *EthereumParser
@@ -50,10 +75,12 @@ type EthereumRPC struct {
 	bestHeaderMu         sync.Mutex
 	bestHeader           *ethtypes.Header
 	chanNewBlock         chan *ethtypes.Header
+	subscriptionMu       sync.Mutex
 	newBlockSubscription *rpc.ClientSubscription
 	chanNewTx            chan ethcommon.Hash
 	newTxSubscription    *rpc.ClientSubscription
 	ChainConfig          *Configuration
+	gasOracle            *bchain.FeeRateEstimator
 }
 
 // NewEthereumRPC returns new EthRPC instance.
@@ -64,20 +91,57 @@ func NewEthereumRPC(config json.RawMessage, pushHandler func(bchain.Notification
 	if err != nil {
 		return nil, errors.Annotatef(err, "Invalid configuration file")
 	}
+	if c.ProcessInternalTransactions {
+		switch c.InternalTransactionsTracer {
+		case "":
+			c.InternalTransactionsTracer = TracerGeth
+		case TracerGeth, TracerParity:
+		default:
+			return nil, errors.Errorf("Invalid internal_transactions_tracer %v", c.InternalTransactionsTracer)
+		}
+		glog.Info("rpc: processing of internal transactions enabled, tracer ", c.InternalTransactionsTracer)
+	}
+	if c.RPCMaxHeightLag < 1 {
+		c.RPCMaxHeightLag = defaultRPCMaxHeightLag
+	}
+	if c.RPCMaxErrorRate <= 0 {
+		c.RPCMaxErrorRate = bchain.DefaultRPCMaxErrorRate
+	}
+	if c.RPCHealthCheckPeriod < 1 {
+		c.RPCHealthCheckPeriod = bchain.DefaultRPCHealthCheckPeriod
+	}
 	rc, err := rpc.Dial(c.RPCURL)
 	if err != nil {
 		return nil, err
 	}
-	ec := ethclient.NewClient(rc)
 
 	s := &EthereumRPC{
-		client:      ec,
-		rpc:         rc,
+		rpcs:        []*rpc.Client{rc},
+		pushHandler: pushHandler,
 		ChainConfig: &c,
 	}
+	urls := append([]string{c.RPCURL}, c.RPCURLs...)
+	s.endpoints = bchain.NewRPCEndpoints(urls, uint32(c.RPCMaxHeightLag), c.RPCMaxErrorRate)
+	// the additional backends, which are not available at startup, are unhealthy until they are dialed by the health check
+	for _, ep := range s.endpoints.All()[1:] {
+		rc, err := rpc.Dial(ep.URL)
+		if err != nil {
+			s.rpcs = append(s.rpcs, nil)
+			s.endpoints.Fail(ep, err)
+			continue
+		}
+		s.rpcs = append(s.rpcs, rc)
+	}
 
 	// always create parser
 	s.Parser = NewEthereumParser()
+	s.Parser.IndexLogs = c.IndexContractLogs
+	if c.GasPriceOracleBlocks == 0 {
+		c.GasPriceOracleBlocks = defaultGasPriceOracleBlocks
+	}
+	if c.GasPriceOracleBlocks > 0 {
+		s.gasOracle = bchain.NewFeeRateEstimator(c.GasPriceOracleBlocks)
+	}
 	s.timeout = time.Duration(c.RPCTimeout) * time.Second
 
 	// new blocks notifications handling
@@ -123,7 +187,11 @@ func (b *EthereumRPC) Initialize() error {
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
 
-	id, err := b.client.NetworkID(ctx)
+	var id *big.Int
+	err := b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+		id, err = ethclient.NewClient(rc).NetworkID(ctx)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -146,14 +214,18 @@ func (b *EthereumRPC) Initialize() error {
 	// subscriptions
 	if err = b.subscribe(func() (*rpc.ClientSubscription, error) {
 		// invalidate the previous subscription - it is either the first one or there was an error
-		b.newBlockSubscription = nil
+		b.setSubscription(&b.newBlockSubscription, nil)
+		rc, err := b.getEndpointRPC(b.endpoints.Get())
+		if err != nil {
+			return nil, errors.Annotatef(err, "EthSubscribe newHeads")
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 		defer cancel()
-		sub, err := b.rpc.EthSubscribe(ctx, b.chanNewBlock, "newHeads")
+		sub, err := rc.EthSubscribe(ctx, b.chanNewBlock, "newHeads")
 		if err != nil {
 			return nil, errors.Annotatef(err, "EthSubscribe newHeads")
 		}
-		b.newBlockSubscription = sub
+		b.setSubscription(&b.newBlockSubscription, sub)
 		glog.Info("Subscribed to newHeads")
 		return sub, nil
 	}); err != nil {
@@ -161,26 +233,128 @@ func (b *EthereumRPC) Initialize() error {
 	}
 	if err = b.subscribe(func() (*rpc.ClientSubscription, error) {
 		// invalidate the previous subscription - it is either the first one or there was an error
-		b.newTxSubscription = nil
+		b.setSubscription(&b.newTxSubscription, nil)
+		rc, err := b.getEndpointRPC(b.endpoints.Get())
+		if err != nil {
+			return nil, errors.Annotatef(err, "EthSubscribe newPendingTransactions")
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 		defer cancel()
-		sub, err := b.rpc.EthSubscribe(ctx, b.chanNewTx, "newPendingTransactions")
+		sub, err := rc.EthSubscribe(ctx, b.chanNewTx, "newPendingTransactions")
 		if err != nil {
 			return nil, errors.Annotatef(err, "EthSubscribe newPendingTransactions")
 		}
-		b.newTxSubscription = sub
+		b.setSubscription(&b.newTxSubscription, sub)
 		glog.Info("Subscribed to newPendingTransactions")
 		return sub, nil
 	}); err != nil {
 		return err
 	}
 
+	if b.endpoints.Len() > 1 {
+		b.healthCheckDone = make(chan struct{})
+		go b.endpoints.RunHealthChecks(time.Duration(b.ChainConfig.RPCHealthCheckPeriod)*time.Second, b.getEndpointHeight, b.onHealthCheck, b.healthCheckDone)
+	}
+
 	// create mempool
 	b.Mempool = bchain.NewNonUTXOMempool(b)
 
 	return nil
 }
 
+// getEndpointRPC returns the rpc client of the backend endpoint, the backend is dialed if it was not available before
+func (b *EthereumRPC) getEndpointRPC(ep *bchain.RPCEndpoint) (*rpc.Client, error) {
+	b.rpcsMu.Lock()
+	defer b.rpcsMu.Unlock()
+	if b.rpcs[ep.Index] == nil {
+		rc, err := rpc.Dial(ep.URL)
+		if err != nil {
+			return nil, err
+		}
+		glog.Info("rpc: connected to ", ep.URL)
+		b.rpcs[ep.Index] = rc
+	}
+	return b.rpcs[ep.Index], nil
+}
+
+// isBackendResponse returns true if err is nil or is returned by the backend, other errors mean that the backend did not respond
+func isBackendResponse(err error) bool {
+	if err == nil || err == ethereum.NotFound {
+		return true
+	}
+	_, ok := err.(rpc.Error)
+	return ok
+}
+
+// callEndpoints calls f with the rpc client of the active backend endpoint,
+// if the backend does not respond, the call is repeated at the next healthy backend
+func (b *EthereumRPC) callEndpoints(ctx context.Context, f func(rc *rpc.Client) error) error {
+	ep := b.endpoints.Get()
+	for tries := 1; ; tries++ {
+		rc, err := b.getEndpointRPC(ep)
+		if err == nil {
+			err = f(rc)
+		}
+		responded := isBackendResponse(err)
+		if responded || ctx.Err() != nil || tries >= b.endpoints.Len() {
+			if responded {
+				b.endpoints.Observe(ep, nil)
+			} else {
+				b.endpoints.Observe(ep, err)
+			}
+			return err
+		}
+		next := b.endpoints.Fail(ep, err)
+		if next == ep {
+			return err
+		}
+		ep = next
+	}
+}
+
+// callContext calls the method at the active backend endpoint using callEndpoints
+func (b *EthereumRPC) callContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return b.callEndpoints(ctx, func(rc *rpc.Client) error {
+		return rc.CallContext(ctx, result, method, args...)
+	})
+}
+
+// getEndpointHeight returns the best height of the backend endpoint, it is used by the health checks
+// the backends not available before are dialed again by it
+func (b *EthereumRPC) getEndpointHeight(ep *bchain.RPCEndpoint) (uint32, error) {
+	rc, err := b.getEndpointRPC(ep)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	h, err := ethclient.NewClient(rc).HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(h.Number.Uint64()), nil
+}
+
+// setSubscription sets the subscription s to sub, the subscriptions are read also by the health checks
+func (b *EthereumRPC) setSubscription(s **rpc.ClientSubscription, sub *rpc.ClientSubscription) {
+	b.subscriptionMu.Lock()
+	defer b.subscriptionMu.Unlock()
+	*s = sub
+}
+
+// onHealthCheck triggers the synchronization of the index and the mempool if the subscriptions are down
+// and the active endpoint is not the backend at rpc_url, the notifications of new blocks and transactions are missing then
+func (b *EthereumRPC) onHealthCheck(active *bchain.RPCEndpoint) {
+	b.subscriptionMu.Lock()
+	down := b.newBlockSubscription == nil || b.newTxSubscription == nil
+	b.subscriptionMu.Unlock()
+	if down && active.Index != 0 && b.pushHandler != nil {
+		glog.V(1).Info("rpc: using endpoint ", active.URL, ", synchronizing without the subscriptions")
+		b.pushHandler(bchain.NotificationNewBlock)
+		b.pushHandler(bchain.NotificationNewTx)
+	}
+}
+
 // subscribeNewBlocks subscribes to new blocks notification
 func (b *EthereumRPC) subscribe(f func() (*rpc.ClientSubscription, error)) error {
 	s, err := f()
@@ -222,15 +396,25 @@ func (b *EthereumRPC) subscribe(f func() (*rpc.ClientSubscription, error)) error
 
 // Shutdown cleans up rpc interface to ethereum
 func (b *EthereumRPC) Shutdown(ctx context.Context) error {
+	b.subscriptionMu.Lock()
 	if b.newBlockSubscription != nil {
 		b.newBlockSubscription.Unsubscribe()
 	}
 	if b.newTxSubscription != nil {
 		b.newTxSubscription.Unsubscribe()
 	}
-	if b.rpc != nil {
-		b.rpc.Close()
+	b.subscriptionMu.Unlock()
+	if b.healthCheckDone != nil {
+		close(b.healthCheckDone)
+		b.healthCheckDone = nil
 	}
+	b.rpcsMu.Lock()
+	for _, rc := range b.rpcs {
+		if rc != nil {
+			rc.Close()
+		}
+	}
+	b.rpcsMu.Unlock()
 	close(b.chanNewBlock)
 	glog.Info("rpc: shutdown")
 	return nil
@@ -257,7 +441,11 @@ func (b *EthereumRPC) GetBlockChainInfo() (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
 
-	id, err := b.client.NetworkID(ctx)
+	var id *big.Int
+	err := b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+		id, err = ethclient.NewClient(rc).NetworkID(ctx)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -271,7 +459,10 @@ func (b *EthereumRPC) getBestHeader() (*ethtypes.Header, error) {
 		var err error
 		ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 		defer cancel()
-		b.bestHeader, err = b.client.HeaderByNumber(ctx, nil)
+		err = b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+			b.bestHeader, err = ethclient.NewClient(rc).HeaderByNumber(ctx, nil)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -301,7 +492,11 @@ func (b *EthereumRPC) GetBlockHash(height uint32) (string, error) {
 	n.SetUint64(uint64(height))
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
-	h, err := b.client.HeaderByNumber(ctx, &n)
+	var h *ethtypes.Header
+	err := b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+		h, err = ethclient.NewClient(rc).HeaderByNumber(ctx, &n)
+		return err
+	})
 	if err != nil {
 		if err == ethereum.NotFound {
 			return "", bchain.ErrBlockNotFound
@@ -321,6 +516,7 @@ func (b *EthereumRPC) ethHeaderToBlockHeader(h *ethtypes.Header) (*bchain.BlockH
 		Hash:          ethHashToHash(h.Hash()),
 		Height:        uint32(hn),
 		Confirmations: int(c),
+		Time:          h.Time.Int64(),
 		// Next
 		// Prev
 
@@ -330,7 +526,11 @@ func (b *EthereumRPC) ethHeaderToBlockHeader(h *ethtypes.Header) (*bchain.BlockH
 func (b *EthereumRPC) GetBlockHeader(hash string) (*bchain.BlockHeader, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
-	h, err := b.client.HeaderByHash(ctx, ethcommon.HexToHash(hash))
+	var h *ethtypes.Header
+	err := b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+		h, err = ethclient.NewClient(rc).HeaderByHash(ctx, ethcommon.HexToHash(hash))
+		return err
+	})
 	if err != nil {
 		if err == ethereum.NotFound {
 			return nil, bchain.ErrBlockNotFound
@@ -357,10 +557,10 @@ func (b *EthereumRPC) GetBlock(hash string, height uint32) (*bchain.Block, error
 	var raw json.RawMessage
 	var err error
 	if hash != "" {
-		err = b.rpc.CallContext(ctx, &raw, "eth_getBlockByHash", ethcommon.HexToHash(hash), true)
+		err = b.callContext(ctx, &raw, "eth_getBlockByHash", ethcommon.HexToHash(hash), true)
 	} else {
 
-		err = b.rpc.CallContext(ctx, &raw, "eth_getBlockByNumber", fmt.Sprintf("%#x", height), true)
+		err = b.callContext(ctx, &raw, "eth_getBlockByNumber", fmt.Sprintf("%#x", height), true)
 	}
 	if err != nil {
 		return nil, errors.Annotatef(err, "hash %v, height %v", hash, height)
@@ -393,9 +593,39 @@ func (b *EthereumRPC) GetBlock(hash string, height uint32) (*bchain.Block, error
 		return nil, errors.Annotatef(fmt.Errorf("server returned empty transaction list but block header indicates transactions"), "hash %v, height %v", hash, height)
 	}
 	bbh, err := b.ethHeaderToBlockHeader(head)
+	if err != nil {
+		return nil, errors.Annotatef(err, "hash %v, height %v", hash, height)
+	}
+	if s, err := ethNumber(body.Size); err == nil {
+		bbh.Size = int(s)
+	}
+	receipts, err := b.getReceipts(ctx, body.Transactions)
+	if err != nil {
+		return nil, errors.Annotatef(err, "hash %v, height %v", hash, height)
+	}
+	var internal [][]bchain.InternalTransfer
+	if b.ChainConfig.ProcessInternalTransactions {
+		internal, err = b.getInternalTransfers(ctx, bbh.Hash, bbh.Height, body.Transactions)
+		if err != nil {
+			return nil, errors.Annotatef(err, "hash %v, height %v", hash, height)
+		}
+	}
+	if b.gasOracle != nil {
+		prices := make([]bchain.FeeRateSample, 0, len(body.Transactions))
+		for i := range body.Transactions {
+			if p, err := hexutil.DecodeBig(body.Transactions[i].Price); err == nil {
+				prices = append(prices, bchain.FeeRateSample{FeeRate: p, Size: 1})
+			}
+		}
+		b.gasOracle.AddBlock(bbh.Height, bbh.Hash, prices)
+	}
 	btxs := make([]bchain.Tx, len(body.Transactions))
 	for i, tx := range body.Transactions {
-		btx, err := b.Parser.ethTxToTx(&tx, int64(head.Time.Uint64()), uint32(bbh.Confirmations))
+		sd := &ethTxSpecificData{Receipt: receipts[i]}
+		if internal != nil {
+			sd.InternalTransfers = internal[i]
+		}
+		btx, err := b.Parser.ethTxToTx(&tx, sd, int64(head.Time.Uint64()), uint32(bbh.Confirmations))
 		if err != nil {
 			return nil, errors.Annotatef(err, "hash %v, height %v, txid %v", hash, height, tx.Hash.String())
 		}
@@ -408,6 +638,59 @@ func (b *EthereumRPC) GetBlock(hash string, height uint32) (*bchain.Block, error
 	return &bbk, nil
 }
 
+// GetBlockTxids returns the txids of the transactions of the block with given hash
+func (b *EthereumRPC) GetBlockTxids(hash string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	var raw json.RawMessage
+	if err := b.callContext(ctx, &raw, "eth_getBlockByHash", ethcommon.HexToHash(hash), false); err != nil {
+		return nil, errors.Annotatef(err, "hash %v", hash)
+	} else if len(raw) == 0 || string(raw) == "null" {
+		return nil, bchain.ErrBlockNotFound
+	}
+	var body struct {
+		Transactions []ethcommon.Hash `json:"transactions"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, errors.Annotatef(err, "hash %v", hash)
+	}
+	txids := make([]string, len(body.Transactions))
+	for i := range body.Transactions {
+		txids[i] = ethHashToHash(body.Transactions[i])
+	}
+	return txids, nil
+}
+
+// getReceipts gets the receipts of the transactions in one batch request
+func (b *EthereumRPC) getReceipts(ctx context.Context, txs []rpcTransaction) ([]*rpcReceipt, error) {
+	receipts := make([]*rpcReceipt, len(txs))
+	if len(txs) == 0 {
+		return receipts, nil
+	}
+	batch := make([]rpc.BatchElem, len(txs))
+	for i := range txs {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []interface{}{txs[i].Hash},
+			Result: &receipts[i],
+		}
+	}
+	if err := b.callEndpoints(ctx, func(rc *rpc.Client) error {
+		return rc.BatchCallContext(ctx, batch)
+	}); err != nil {
+		return nil, err
+	}
+	for i := range batch {
+		if batch[i].Error != nil {
+			return nil, errors.Annotatef(batch[i].Error, "receipt of txid %v", txs[i].Hash.Hex())
+		}
+		if receipts[i] == nil {
+			return nil, errors.Errorf("Missing receipt of txid %v", txs[i].Hash.Hex())
+		}
+	}
+	return receipts, nil
+}
+
 // GetTransactionForMempool returns a transaction by the transaction ID.
 // It could be optimized for mempool, i.e. without block time and confirmations
 func (b *EthereumRPC) GetTransactionForMempool(txid string) (*bchain.Tx, error) {
@@ -419,7 +702,7 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
 	var tx *rpcTransaction
-	err := b.rpc.CallContext(ctx, &tx, "eth_getTransactionByHash", ethcommon.HexToHash(txid))
+	err := b.callContext(ctx, &tx, "eth_getTransactionByHash", ethcommon.HexToHash(txid))
 	if err != nil {
 		return nil, err
 	} else if tx == nil {
@@ -430,7 +713,7 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 	var btx *bchain.Tx
 	if tx.BlockNumber == "" {
 		// mempool tx
-		btx, err = b.Parser.ethTxToTx(tx, 0, 0)
+		btx, err = b.Parser.ethTxToTx(tx, nil, 0, 0)
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
@@ -440,7 +723,11 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
-		h, err := b.client.HeaderByHash(ctx, *tx.BlockHash)
+		var h *ethtypes.Header
+		err = b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+			h, err = ethclient.NewClient(rc).HeaderByHash(ctx, *tx.BlockHash)
+			return err
+		})
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
@@ -448,7 +735,21 @@ func (b *EthereumRPC) GetTransaction(txid string) (*bchain.Tx, error) {
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
-		btx, err = b.Parser.ethTxToTx(tx, h.Time.Int64(), confirmations)
+		var receipt *rpcReceipt
+		err = b.callContext(ctx, &receipt, "eth_getTransactionReceipt", tx.Hash)
+		if err != nil {
+			return nil, errors.Annotatef(err, "txid %v", txid)
+		}
+		if receipt == nil {
+			return nil, errors.Errorf("Missing receipt of txid %v", txid)
+		}
+		sd := &ethTxSpecificData{Receipt: receipt}
+		if b.ChainConfig.ProcessInternalTransactions {
+			if sd.InternalTransfers, err = b.getTxInternalTransfers(ctx, txid); err != nil {
+				return nil, errors.Annotatef(err, "txid %v", txid)
+			}
+		}
+		btx, err = b.Parser.ethTxToTx(tx, sd, h.Time.Int64(), confirmations)
 		if err != nil {
 			return nil, errors.Annotatef(err, "txid %v", txid)
 		}
@@ -465,7 +766,7 @@ func (b *EthereumRPC) GetMempool() ([]string, error) {
 	defer cancel()
 	var raw json.RawMessage
 	var err error
-	err = b.rpc.CallContext(ctx, &raw, "eth_getBlockByNumber", "pending", false)
+	err = b.callContext(ctx, &raw, "eth_getBlockByNumber", "pending", false)
 	if err != nil {
 		return nil, err
 	} else if len(raw) == 0 {
@@ -478,40 +779,171 @@ func (b *EthereumRPC) GetMempool() ([]string, error) {
 	return body.Transactions, nil
 }
 
-// EstimateFee returns fee estimation.
+// blockNumberParam returns the block parameter of the state queries, negative height means the tip of the chain
+func blockNumberParam(height int64) string {
+	if height < 0 {
+		return "latest"
+	}
+	return hexutil.EncodeUint64(uint64(height))
+}
+
+// GetBalance returns the balance of the address at the block height, negative height means the tip of the chain
+// the balance at a historical height is available only if the backend keeps the historical state (archive node)
+func (b *EthereumRPC) GetBalance(address string, height int64) (*big.Int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	var r string
+	if err := b.callContext(ctx, &r, "eth_getBalance", ethcommon.HexToAddress(address), blockNumberParam(height)); err != nil {
+		return nil, errors.Annotatef(err, "address %v, height %v", address, height)
+	}
+	v, err := hexutil.DecodeBig(r)
+	if err != nil {
+		return nil, errors.Annotatef(err, "address %v, height %v, balance %v", address, height, r)
+	}
+	return v, nil
+}
+
+// GetNonce returns the number of transactions sent from the address at the block height, negative height means the tip of the chain
+func (b *EthereumRPC) GetNonce(address string, height int64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	var r string
+	if err := b.callContext(ctx, &r, "eth_getTransactionCount", ethcommon.HexToAddress(address), blockNumberParam(height)); err != nil {
+		return 0, errors.Annotatef(err, "address %v, height %v", address, height)
+	}
+	n, err := hexutil.DecodeUint64(r)
+	if err != nil {
+		return 0, errors.Annotatef(err, "address %v, height %v, nonce %v", address, height, r)
+	}
+	return n, nil
+}
+
+// GetAccountState returns the balance and the nonce of the address at the block height, negative height means the tip of the chain
+func (b *EthereumRPC) GetAccountState(address string, height int64) (*bchain.AccountState, error) {
+	if !has0xPrefix(address) || len(address) != 42 {
+		return nil, errors.Errorf("Invalid address %v", address)
+	}
+	balance, err := b.GetBalance(address, height)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := b.GetNonce(address, height)
+	if err != nil {
+		return nil, err
+	}
+	s := &bchain.AccountState{Nonce: nonce}
+	s.Balance.Set(balance)
+	return s, nil
+}
+
+// EstimateFee returns the standard gas price in wei for the confirmation within the number of blocks
 func (b *EthereumRPC) EstimateFee(blocks int) (float64, error) {
 	return b.EstimateSmartFee(blocks, true)
 }
 
-// EstimateSmartFee returns fee estimation.
+// EstimateSmartFee returns the standard gas price in wei for the confirmation within the number of blocks
 func (b *EthereumRPC) EstimateSmartFee(blocks int, conservative bool) (float64, error) {
+	fl, err := b.EstimateFeeLevels(blocks)
+	if err != nil {
+		return 0, err
+	}
+	r, _ := new(big.Float).SetInt(&fl.Standard).Float64()
+	return r, nil
+}
+
+// EstimateFeeLevels returns the gas prices in wei for the confirmation within the number of blocks
+// the prices are computed by the gas price oracle, if the oracle is disabled or has no data yet, all levels are eth_gasPrice
+func (b *EthereumRPC) EstimateFeeLevels(blocks int) (*bchain.FeeLevels, error) {
+	if b.gasOracle != nil {
+		if fl := b.gasOracle.Estimate(blocks, b.Mempool.GetFeeRateSamples()); fl != nil {
+			return fl, nil
+		}
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
 	defer cancel()
-	// TODO - what parameters of msg to use to get better estimate, maybe more data from the wallet are needed
-	a := ethcommon.HexToAddress("0x1234567890123456789012345678901234567890")
-	msg := ethereum.CallMsg{
-		To: &a,
-	}
-	g, err := b.client.EstimateGas(ctx, msg)
+	var p *big.Int
+	err := b.callEndpoints(ctx, func(rc *rpc.Client) (err error) {
+		p, err = ethclient.NewClient(rc).SuggestGasPrice(ctx)
+		return err
+	})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return float64(g), nil
+	fl := &bchain.FeeLevels{Blocks: blocks}
+	fl.Slow.Set(p)
+	fl.Standard.Set(p)
+	fl.Fast.Set(p)
+	return fl, nil
 }
 
-// SendRawTransaction sends raw transaction.
+// SendRawTransaction broadcasts the raw transaction to all backends, it is successful if at least one backend accepts it
 func (b *EthereumRPC) SendRawTransaction(tx string) (string, error) {
-	return "", errors.New("SendRawTransaction: not implemented")
+	if !has0xPrefix(tx) {
+		tx = "0x" + tx
+	}
+	var txid string
+	var firstErr error
+	for _, ep := range b.endpoints.All() {
+		var hash string
+		rc, err := b.getEndpointRPC(ep)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+			err = rc.CallContext(ctx, &hash, "eth_sendRawTransaction", tx)
+			cancel()
+		}
+		if err != nil {
+			if b.endpoints.Len() > 1 {
+				glog.Warning("rpc: eth_sendRawTransaction to ", ep.URL, ": ", err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if txid == "" {
+			txid = hash
+		}
+	}
+	if txid == "" {
+		return "", firstErr
+	}
+	return txid, nil
 }
 
-func (b *EthereumRPC) ResyncMempool(onNewTxAddr func(txid string, addr string)) (int, error) {
+// ResyncMempool resyncs the mempool, the replacements of transactions are not detected
+func (b *EthereumRPC) ResyncMempool(onNewTxAddr func(txid string, addr string), onReplacedTx func(txid string, replacedBy string)) (int, error) {
 	return b.Mempool.Resync(onNewTxAddr)
 }
 
+// GetMempoolReplacedBy is not supported by ethereum, it returns always empty txid
+func (b *EthereumRPC) GetMempoolReplacedBy(txid string) (string, error) {
+	return "", nil
+}
+
+// PackMempoolState returns nil, the ethereum mempool state is not persisted
+func (b *EthereumRPC) PackMempoolState() ([]byte, error) {
+	return nil, nil
+}
+
+// UnpackMempoolState does nothing, the ethereum mempool state is not persisted
+func (b *EthereumRPC) UnpackMempoolState(buf []byte) error {
+	return nil
+}
+
 func (b *EthereumRPC) GetMempoolTransactions(address string) ([]string, error) {
 	return b.Mempool.GetTransactions(address)
 }
 
+// GetMempoolSpendingTx is not applicable to non UTXO chain, it returns always empty txid
+func (b *EthereumRPC) GetMempoolSpendingTx(txid string, vout uint32) (string, int, error) {
+	return "", 0, nil
+}
+
+// GetMempoolStats is not supported by ethereum
+func (b *EthereumRPC) GetMempoolStats() (*bchain.MempoolStats, error) {
+	return nil, errors.New("GetMempoolStats: not supported")
+}
+
 func (b *EthereumRPC) GetMempoolEntry(txid string) (*bchain.MempoolEntry, error) {
 	return nil, errors.New("GetMempoolEntry: not implemented")
 }
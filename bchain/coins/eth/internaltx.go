@@ -0,0 +1,259 @@
+package eth
+
+import (
+	"blockbook/bchain"
+	"context"
+	"fmt"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/juju/errors"
+)
+
+const (
+	// TracerGeth uses debug_traceBlockByHash and debug_traceTransaction with the built in callTracer of geth
+	TracerGeth = "geth"
+	// TracerParity uses trace_block and trace_transaction of parity
+	TracerParity = "parity"
+)
+
+// rpcCallTrace is a call frame returned by the callTracer of geth
+type rpcCallTrace struct {
+	Type  string         `json:"type"`
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Value string         `json:"value"`
+	Error string         `json:"error"`
+	Calls []rpcCallTrace `json:"calls"`
+}
+
+type rpcTraceResult struct {
+	Result rpcCallTrace `json:"result"`
+	Error  string       `json:"error"`
+}
+
+// rpcParityTrace is a trace returned by the trace module of parity
+type rpcParityTrace struct {
+	Action struct {
+		CallType      string `json:"callType"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Value         string `json:"value"`
+		Address       string `json:"address"`
+		RefundAddress string `json:"refundAddress"`
+		Balance       string `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address string `json:"address"`
+	} `json:"result"`
+	BlockHash           string `json:"blockHash"`
+	Error               string `json:"error"`
+	TraceAddress        []int  `json:"traceAddress"`
+	TransactionPosition *int   `json:"transactionPosition"`
+	Type                string `json:"type"`
+}
+
+func appendInternalTransfer(transfers []bchain.InternalTransfer, from, to, value string) ([]bchain.InternalTransfer, error) {
+	if len(value) <= 2 {
+		return transfers, nil
+	}
+	v, err := hexutil.DecodeBig(value)
+	if err != nil {
+		return nil, errors.Annotatef(err, "Value %v", value)
+	}
+	if v.Sign() == 0 {
+		return transfers, nil
+	}
+	t := bchain.InternalTransfer{
+		From: strings.ToLower(from),
+		To:   strings.ToLower(to),
+	}
+	t.Value.Set(v)
+	return append(transfers, t), nil
+}
+
+// gethTraceToInternalTransfers returns the value transfers of the calls nested in the top level call of a transaction
+// the top level call is the transaction itself, failed calls and calls which do not transfer value are skipped
+func gethTraceToInternalTransfers(trace *rpcCallTrace) ([]bchain.InternalTransfer, error) {
+	if trace.Error != "" {
+		return nil, nil
+	}
+	var transfers []bchain.InternalTransfer
+	var walk func(calls []rpcCallTrace) error
+	walk = func(calls []rpcCallTrace) error {
+		var err error
+		for i := range calls {
+			c := &calls[i]
+			if c.Error != "" {
+				continue
+			}
+			switch c.Type {
+			case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT":
+				if transfers, err = appendInternalTransfer(transfers, c.From, c.To, c.Value); err != nil {
+					return err
+				}
+			}
+			if err = walk(c.Calls); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(trace.Calls); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// isParitySubtrace returns true if the trace address a is in the subtree of the trace address parent
+func isParitySubtrace(a, parent []int) bool {
+	if len(a) < len(parent) {
+		return false
+	}
+	for i := range parent {
+		if a[i] != parent[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkParityTracesBlockHash returns error if any of the traces does not belong to the block with the hash
+// trace_block is called by the height, the block at the height may be different from the indexed one after a reorg
+func checkParityTracesBlockHash(traces []rpcParityTrace, hash string) error {
+	h := ethcommon.HexToHash(hash)
+	for i := range traces {
+		if ethcommon.HexToHash(traces[i].BlockHash) != h {
+			return errors.Errorf("Trace of block %v, expected block %v", traces[i].BlockHash, hash)
+		}
+	}
+	return nil
+}
+
+// parityTracesToInternalTransfers returns the value transfers of the traces of a block with ntxs transactions
+// the top level traces (transactions themselves), the rewards and the traces reverted by a failure are skipped
+func parityTracesToInternalTransfers(traces []rpcParityTrace, ntxs int) ([][]bchain.InternalTransfer, error) {
+	r := make([][]bchain.InternalTransfer, ntxs)
+	failed := make(map[int][][]int)
+	var err error
+	for i := range traces {
+		t := &traces[i]
+		if t.TransactionPosition == nil {
+			continue
+		}
+		p := *t.TransactionPosition
+		if p < 0 || p >= ntxs {
+			return nil, errors.Errorf("Invalid transaction position %d", p)
+		}
+		if t.Error != "" {
+			failed[p] = append(failed[p], t.TraceAddress)
+			continue
+		}
+		if len(t.TraceAddress) == 0 {
+			continue
+		}
+		reverted := false
+		for _, f := range failed[p] {
+			if isParitySubtrace(t.TraceAddress, f) {
+				reverted = true
+				break
+			}
+		}
+		if reverted {
+			continue
+		}
+		switch t.Type {
+		case "call":
+			if t.Action.CallType == "delegatecall" || t.Action.CallType == "staticcall" || t.Action.CallType == "callcode" {
+				continue
+			}
+			r[p], err = appendInternalTransfer(r[p], t.Action.From, t.Action.To, t.Action.Value)
+		case "create":
+			if t.Result != nil {
+				r[p], err = appendInternalTransfer(r[p], t.Action.From, t.Result.Address, t.Action.Value)
+			}
+		case "suicide":
+			r[p], err = appendInternalTransfer(r[p], t.Action.Address, t.Action.RefundAddress, t.Action.Balance)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// getInternalTransfers returns the internal transfers of the transactions of the block, using the configured tracer
+func (b *EthereumRPC) getInternalTransfers(ctx context.Context, hash string, height uint32, txs []rpcTransaction) ([][]bchain.InternalTransfer, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	switch b.ChainConfig.InternalTransactionsTracer {
+	case TracerParity:
+		var traces []rpcParityTrace
+		if err := b.callContext(ctx, &traces, "trace_block", fmt.Sprintf("%#x", height)); err != nil {
+			return nil, err
+		}
+		if err := checkParityTracesBlockHash(traces, hash); err != nil {
+			return nil, err
+		}
+		return parityTracesToInternalTransfers(traces, len(txs))
+	default:
+		var results []rpcTraceResult
+		if err := b.callContext(ctx, &results, "debug_traceBlockByHash", ethcommon.HexToHash(hash), map[string]string{"tracer": "callTracer"}); err != nil {
+			return nil, err
+		}
+		if len(results) != len(txs) {
+			return nil, errors.Errorf("Block has %d transactions but %d traces", len(txs), len(results))
+		}
+		r := make([][]bchain.InternalTransfer, len(txs))
+		for i := range results {
+			if results[i].Error != "" {
+				return nil, errors.Errorf("Trace of txid %v: %v", txs[i].Hash.Hex(), results[i].Error)
+			}
+			t, err := gethTraceToInternalTransfers(&results[i].Result)
+			if err != nil {
+				return nil, errors.Annotatef(err, "txid %v", txs[i].Hash.Hex())
+			}
+			r[i] = t
+		}
+		return r, nil
+	}
+}
+
+// getTxInternalTransfers returns the internal transfers of a confirmed transaction, using the configured tracer
+func (b *EthereumRPC) getTxInternalTransfers(ctx context.Context, txid string) ([]bchain.InternalTransfer, error) {
+	switch b.ChainConfig.InternalTransactionsTracer {
+	case TracerParity:
+		var traces []rpcParityTrace
+		if err := b.callContext(ctx, &traces, "trace_transaction", ethcommon.HexToHash(txid)); err != nil {
+			return nil, err
+		}
+		// trace_transaction returns the position of the transaction in the block, treat the traces as a block with one transaction
+		zero := 0
+		for i := range traces {
+			traces[i].TransactionPosition = &zero
+		}
+		r, err := parityTracesToInternalTransfers(traces, 1)
+		if err != nil {
+			return nil, err
+		}
+		return r[0], nil
+	default:
+		var trace rpcCallTrace
+		if err := b.callContext(ctx, &trace, "debug_traceTransaction", ethcommon.HexToHash(txid), map[string]string{"tracer": "callTracer"}); err != nil {
+			return nil, err
+		}
+		return gethTraceToInternalTransfers(&trace)
+	}
+}
+
+// GetInternalTransfersFromTx returns the internal transfers of the transaction
+// they are available only for confirmed transactions if the processing of internal transactions is enabled
+func (p *EthereumParser) GetInternalTransfersFromTx(tx *bchain.Tx) ([]bchain.InternalTransfer, error) {
+	sd, ok := tx.CoinSpecificData.(*ethTxSpecificData)
+	if !ok || sd == nil {
+		return nil, nil
+	}
+	return sd.InternalTransfers, nil
+}
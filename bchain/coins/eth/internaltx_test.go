@@ -0,0 +1,159 @@
+// +build unittest
+
+package eth
+
+import (
+	"blockbook/bchain"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newInternalTransfer(from, to string, value int64) bchain.InternalTransfer {
+	t := bchain.InternalTransfer{From: from, To: to}
+	t.Value.SetInt64(value)
+	return t
+}
+
+func TestInternalTx_gethTraceToInternalTransfers(t *testing.T) {
+	tests := []struct {
+		name  string
+		trace string
+		want  []bchain.InternalTransfer
+	}{
+		{
+			name:  "no calls",
+			trace: `{"type":"CALL","from":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","to":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","value":"0x1bc0159d530e6000"}`,
+		},
+		{
+			name: "nested calls",
+			trace: `{"type":"CALL","from":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","to":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","value":"0x0","calls":[
+				{"type":"CALL","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682B7903A11098CF770C7AEF4AA02A85B3F3601A","value":"0x10","calls":[
+					{"type":"CALL","from":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","to":"0xdacc9c61754a0c4616fc5323dc946e89eb272302","value":"0x5"}
+				]},
+				{"type":"DELEGATECALL","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x10"},
+				{"type":"STATICCALL","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a"},
+				{"type":"CALL","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x0"},
+				{"type":"CALL","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x20","error":"out of gas","calls":[
+					{"type":"CALL","from":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","to":"0xdacc9c61754a0c4616fc5323dc946e89eb272302","value":"0x5"}
+				]},
+				{"type":"CREATE","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x1234567890123456789012345678901234567890","value":"0x30"}
+			]}`,
+			want: []bchain.InternalTransfer{
+				newInternalTransfer("0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "0x682b7903a11098cf770c7aef4aa02a85b3f3601a", 0x10),
+				newInternalTransfer("0x682b7903a11098cf770c7aef4aa02a85b3f3601a", "0xdacc9c61754a0c4616fc5323dc946e89eb272302", 0x5),
+				newInternalTransfer("0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "0x1234567890123456789012345678901234567890", 0x30),
+			},
+		},
+		{
+			name: "failed transaction",
+			trace: `{"type":"CALL","from":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","to":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","value":"0x0","error":"execution reverted","calls":[
+				{"type":"CALL","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x10"}
+			]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace rpcCallTrace
+			if err := json.Unmarshal([]byte(tt.trace), &trace); err != nil {
+				t.Fatal(err)
+			}
+			got, err := gethTraceToInternalTransfers(&trace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gethTraceToInternalTransfers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTx_parityTracesToInternalTransfers(t *testing.T) {
+	traces := `[
+		{"action":{"callType":"call","from":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","to":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","value":"0x0"},"traceAddress":[],"transactionPosition":0,"type":"call"},
+		{"action":{"callType":"call","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x10"},"traceAddress":[0],"transactionPosition":0,"type":"call"},
+		{"action":{"callType":"delegatecall","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x10"},"traceAddress":[1],"transactionPosition":0,"type":"call"},
+		{"action":{"callType":"call","from":"0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f","to":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","value":"0x20"},"error":"Reverted","traceAddress":[2],"transactionPosition":0,"type":"call"},
+		{"action":{"callType":"call","from":"0x682b7903a11098cf770c7aef4aa02a85b3f3601a","to":"0xdacc9c61754a0c4616fc5323dc946e89eb272302","value":"0x5"},"traceAddress":[2,0],"transactionPosition":0,"type":"call"},
+		{"action":{"from":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","value":"0x0"},"result":{"address":"0x1234567890123456789012345678901234567890"},"traceAddress":[],"transactionPosition":1,"type":"create"},
+		{"action":{"from":"0x1234567890123456789012345678901234567890","value":"0x30"},"result":{"address":"0xabcdefabcdefabcdefabcdefabcdefabcdefabcd"},"traceAddress":[0],"transactionPosition":1,"type":"create"},
+		{"action":{"address":"0x1234567890123456789012345678901234567890","refundAddress":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","balance":"0x40"},"traceAddress":[1],"transactionPosition":1,"type":"suicide"},
+		{"action":{"author":"0x3e3a3d69dc66ba10737f531ed088954a9ec89d97","value":"0x29a2241af62c0000","rewardType":"block"},"traceAddress":[],"transactionPosition":null,"type":"reward"}
+	]`
+	var pt []rpcParityTrace
+	if err := json.Unmarshal([]byte(traces), &pt); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parityTracesToInternalTransfers(pt, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bchain.InternalTransfer{
+		{
+			newInternalTransfer("0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "0x682b7903a11098cf770c7aef4aa02a85b3f3601a", 0x10),
+		},
+		{
+			newInternalTransfer("0x1234567890123456789012345678901234567890", "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd", 0x30),
+			newInternalTransfer("0x1234567890123456789012345678901234567890", "0x3e3a3d69dc66ba10737f531ed088954a9ec89d97", 0x40),
+		},
+		nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parityTracesToInternalTransfers() = %+v, want %+v", got, want)
+	}
+	if _, err = parityTracesToInternalTransfers(pt, 1); err == nil {
+		t.Error("parityTracesToInternalTransfers() expected error for invalid transaction position")
+	}
+}
+
+func TestInternalTx_checkParityTracesBlockHash(t *testing.T) {
+	hash := "0x9ff0a2b3c6e4f0bba5f5a3b8c8e1e4ce7ac0e2d0b9d2e1a4c6d7e8f9a0b1c2d3"
+	traces := []rpcParityTrace{
+		{BlockHash: hash},
+		{BlockHash: strings.ToUpper(hash[2:])},
+	}
+	if err := checkParityTracesBlockHash(traces, hash); err != nil {
+		t.Errorf("checkParityTracesBlockHash() unexpected error %v", err)
+	}
+	traces = append(traces, rpcParityTrace{BlockHash: "0x1ff0a2b3c6e4f0bba5f5a3b8c8e1e4ce7ac0e2d0b9d2e1a4c6d7e8f9a0b1c2d3"})
+	if err := checkParityTracesBlockHash(traces, hash); err == nil {
+		t.Error("checkParityTracesBlockHash() expected error for trace of other block")
+	}
+	if err := checkParityTracesBlockHash([]rpcParityTrace{{}}, hash); err == nil {
+		t.Error("checkParityTracesBlockHash() expected error for trace without block hash")
+	}
+}
+
+func TestInternalTx_PackUnpackInternalTransfers(t *testing.T) {
+	p := NewEthereumParser()
+	var v big.Int
+	v.SetString("1999622000000000000", 10)
+	transfers := []bchain.InternalTransfer{
+		newInternalTransfer("0x555ee11fbddc0e49a9bab358a8941ad95ffdb48f", "0x682b7903a11098cf770c7aef4aa02a85b3f3601a", 0x10),
+		{From: "0x682b7903a11098cf770c7aef4aa02a85b3f3601a", To: "0xdacc9c61754a0c4616fc5323dc946e89eb272302", Value: v},
+	}
+	tx := &bchain.Tx{
+		Blocktime:        1521533434,
+		Hex:              "7b226e6f6e6365223a22307862323663222c226761735072696365223a223078343330653233343030222c22676173223a22307835323038222c22746f223a22307835353565653131666264646330653439613962616233353861383934316164393566666462343866222c2276616c7565223a22307831626330313539643533306536303030222c22696e707574223a223078222c2268617368223a22307863643634373135313535326235313332623261656637633962653030646336663733616663353930316464653135376161623133313333356261616138353362222c22626c6f636b4e756d626572223a223078326263663038222c2266726f6d223a22307833653361336436396463363662613130373337663533316564303838393534613965633839643937222c227472616e73616374696f6e496e646578223a22307861222c2276223a2230783239222c2272223a22307866373136316331373064343335373361643963386437303163646166373134666632613534386135363262306463363339323330643137383839666364343035222c2273223a22307833633439373766633930333835613237656661303033326531376234396664353735623238323663623536653364316563663231353234663261393466393135227d",
+		Txid:             "0xcd647151552b5132b2aef7c9be00dc6f73afc5901dde157aab131335baaa853b",
+		CoinSpecificData: &ethTxSpecificData{InternalTransfers: transfers},
+	}
+	b, err := p.PackTx(tx, 2871048, 1521533434)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utx, _, err := p.UnpackTx(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.GetInternalTransfersFromTx(utx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, transfers) {
+		t.Errorf("GetInternalTransfersFromTx() = %+v, want %+v", got, transfers)
+	}
+}
@@ -28,20 +28,22 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type ProtoTransaction struct {
-	AccountNonce     uint64 `protobuf:"varint,1,opt,name=AccountNonce" json:"AccountNonce,omitempty"`
-	Price            []byte `protobuf:"bytes,2,opt,name=Price,proto3" json:"Price,omitempty"`
-	GasLimit         uint64 `protobuf:"varint,3,opt,name=GasLimit" json:"GasLimit,omitempty"`
-	Value            []byte `protobuf:"bytes,4,opt,name=Value,proto3" json:"Value,omitempty"`
-	Payload          []byte `protobuf:"bytes,5,opt,name=Payload,proto3" json:"Payload,omitempty"`
-	Hash             []byte `protobuf:"bytes,6,opt,name=Hash,proto3" json:"Hash,omitempty"`
-	BlockNumber      uint32 `protobuf:"varint,7,opt,name=BlockNumber" json:"BlockNumber,omitempty"`
-	BlockTime        uint64 `protobuf:"varint,8,opt,name=BlockTime" json:"BlockTime,omitempty"`
-	To               []byte `protobuf:"bytes,9,opt,name=To,proto3" json:"To,omitempty"`
-	From             []byte `protobuf:"bytes,10,opt,name=From,proto3" json:"From,omitempty"`
-	TransactionIndex uint32 `protobuf:"varint,11,opt,name=TransactionIndex" json:"TransactionIndex,omitempty"`
-	V                []byte `protobuf:"bytes,12,opt,name=V,proto3" json:"V,omitempty"`
-	R                []byte `protobuf:"bytes,13,opt,name=R,proto3" json:"R,omitempty"`
-	S                []byte `protobuf:"bytes,14,opt,name=S,proto3" json:"S,omitempty"`
+	AccountNonce      uint64                                   `protobuf:"varint,1,opt,name=AccountNonce" json:"AccountNonce,omitempty"`
+	Price             []byte                                   `protobuf:"bytes,2,opt,name=Price,proto3" json:"Price,omitempty"`
+	GasLimit          uint64                                   `protobuf:"varint,3,opt,name=GasLimit" json:"GasLimit,omitempty"`
+	Value             []byte                                   `protobuf:"bytes,4,opt,name=Value,proto3" json:"Value,omitempty"`
+	Payload           []byte                                   `protobuf:"bytes,5,opt,name=Payload,proto3" json:"Payload,omitempty"`
+	Hash              []byte                                   `protobuf:"bytes,6,opt,name=Hash,proto3" json:"Hash,omitempty"`
+	BlockNumber       uint32                                   `protobuf:"varint,7,opt,name=BlockNumber" json:"BlockNumber,omitempty"`
+	BlockTime         uint64                                   `protobuf:"varint,8,opt,name=BlockTime" json:"BlockTime,omitempty"`
+	To                []byte                                   `protobuf:"bytes,9,opt,name=To,proto3" json:"To,omitempty"`
+	From              []byte                                   `protobuf:"bytes,10,opt,name=From,proto3" json:"From,omitempty"`
+	TransactionIndex  uint32                                   `protobuf:"varint,11,opt,name=TransactionIndex" json:"TransactionIndex,omitempty"`
+	V                 []byte                                   `protobuf:"bytes,12,opt,name=V,proto3" json:"V,omitempty"`
+	R                 []byte                                   `protobuf:"bytes,13,opt,name=R,proto3" json:"R,omitempty"`
+	S                 []byte                                   `protobuf:"bytes,14,opt,name=S,proto3" json:"S,omitempty"`
+	Receipt           *ProtoTransaction_ReceiptType            `protobuf:"bytes,15,opt,name=Receipt" json:"Receipt,omitempty"`
+	InternalTransfers []*ProtoTransaction_InternalTransferType `protobuf:"bytes,16,rep,name=InternalTransfers" json:"InternalTransfers,omitempty"`
 }
 
 func (m *ProtoTransaction) Reset()                    { *m = ProtoTransaction{} }
@@ -147,29 +149,177 @@ func (m *ProtoTransaction) GetS() []byte {
 	return nil
 }
 
+func (m *ProtoTransaction) GetReceipt() *ProtoTransaction_ReceiptType {
+	if m != nil {
+		return m.Receipt
+	}
+	return nil
+}
+
+func (m *ProtoTransaction) GetInternalTransfers() []*ProtoTransaction_InternalTransferType {
+	if m != nil {
+		return m.InternalTransfers
+	}
+	return nil
+}
+
+type ProtoTransaction_ReceiptType struct {
+	Log               []*ProtoTransaction_ReceiptType_LogType `protobuf:"bytes,1,rep,name=Log" json:"Log,omitempty"`
+	GasUsed           []byte                                  `protobuf:"bytes,2,opt,name=GasUsed,proto3" json:"GasUsed,omitempty"`
+	Status            []byte                                  `protobuf:"bytes,3,opt,name=Status,proto3" json:"Status,omitempty"`
+	ContractAddress   []byte                                  `protobuf:"bytes,4,opt,name=ContractAddress,proto3" json:"ContractAddress,omitempty"`
+	CumulativeGasUsed []byte                                  `protobuf:"bytes,5,opt,name=CumulativeGasUsed,proto3" json:"CumulativeGasUsed,omitempty"`
+}
+
+func (m *ProtoTransaction_ReceiptType) Reset()         { *m = ProtoTransaction_ReceiptType{} }
+func (m *ProtoTransaction_ReceiptType) String() string { return proto.CompactTextString(m) }
+func (*ProtoTransaction_ReceiptType) ProtoMessage()    {}
+func (*ProtoTransaction_ReceiptType) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{0, 0}
+}
+
+func (m *ProtoTransaction_ReceiptType) GetLog() []*ProtoTransaction_ReceiptType_LogType {
+	if m != nil {
+		return m.Log
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_ReceiptType) GetGasUsed() []byte {
+	if m != nil {
+		return m.GasUsed
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_ReceiptType) GetStatus() []byte {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_ReceiptType) GetContractAddress() []byte {
+	if m != nil {
+		return m.ContractAddress
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_ReceiptType) GetCumulativeGasUsed() []byte {
+	if m != nil {
+		return m.CumulativeGasUsed
+	}
+	return nil
+}
+
+type ProtoTransaction_ReceiptType_LogType struct {
+	Address []byte   `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	Data    []byte   `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
+	Topics  [][]byte `protobuf:"bytes,3,rep,name=Topics,proto3" json:"Topics,omitempty"`
+}
+
+func (m *ProtoTransaction_ReceiptType_LogType) Reset()         { *m = ProtoTransaction_ReceiptType_LogType{} }
+func (m *ProtoTransaction_ReceiptType_LogType) String() string { return proto.CompactTextString(m) }
+func (*ProtoTransaction_ReceiptType_LogType) ProtoMessage()    {}
+func (*ProtoTransaction_ReceiptType_LogType) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{0, 0, 0}
+}
+
+func (m *ProtoTransaction_ReceiptType_LogType) GetAddress() []byte {
+	if m != nil {
+		return m.Address
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_ReceiptType_LogType) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_ReceiptType_LogType) GetTopics() [][]byte {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+type ProtoTransaction_InternalTransferType struct {
+	From  []byte `protobuf:"bytes,1,opt,name=From,proto3" json:"From,omitempty"`
+	To    []byte `protobuf:"bytes,2,opt,name=To,proto3" json:"To,omitempty"`
+	Value []byte `protobuf:"bytes,3,opt,name=Value,proto3" json:"Value,omitempty"`
+}
+
+func (m *ProtoTransaction_InternalTransferType) Reset()         { *m = ProtoTransaction_InternalTransferType{} }
+func (m *ProtoTransaction_InternalTransferType) String() string { return proto.CompactTextString(m) }
+func (*ProtoTransaction_InternalTransferType) ProtoMessage()    {}
+func (*ProtoTransaction_InternalTransferType) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{0, 1}
+}
+
+func (m *ProtoTransaction_InternalTransferType) GetFrom() []byte {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_InternalTransferType) GetTo() []byte {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+func (m *ProtoTransaction_InternalTransferType) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*ProtoTransaction)(nil), "eth.ProtoTransaction")
+	proto.RegisterType((*ProtoTransaction_ReceiptType)(nil), "eth.ProtoTransaction.ReceiptType")
+	proto.RegisterType((*ProtoTransaction_ReceiptType_LogType)(nil), "eth.ProtoTransaction.ReceiptType.LogType")
+	proto.RegisterType((*ProtoTransaction_InternalTransferType)(nil), "eth.ProtoTransaction.InternalTransferType")
 }
 
 func init() { proto.RegisterFile("tx.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 262 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x90, 0xbd, 0x6a, 0xeb, 0x40,
-	0x10, 0x85, 0x59, 0x59, 0xb6, 0xe5, 0xb1, 0x6c, 0xcc, 0x70, 0x8b, 0xe1, 0x92, 0x42, 0xb8, 0x12,
-	0x29, 0xd2, 0xe4, 0x09, 0x92, 0x22, 0x3f, 0x10, 0x8c, 0x90, 0x85, 0xfa, 0xf5, 0x7a, 0xc1, 0x22,
-	0x92, 0x26, 0x48, 0x2b, 0x70, 0x5e, 0x38, 0xcf, 0x11, 0x76, 0x44, 0x12, 0x87, 0x74, 0xf3, 0x7d,
-	0x70, 0xf6, 0x2c, 0x07, 0x22, 0x77, 0xbe, 0x79, 0xeb, 0xd8, 0x31, 0x4e, 0xac, 0x3b, 0x6d, 0x3f,
-	0x02, 0xd8, 0x64, 0x1e, 0x8b, 0x4e, 0xb7, 0xbd, 0x36, 0xae, 0xe2, 0x16, 0xb7, 0x10, 0xdf, 0x19,
-	0xc3, 0x43, 0xeb, 0x76, 0xdc, 0x1a, 0x4b, 0x2a, 0x51, 0x69, 0x98, 0xff, 0x72, 0xf8, 0x0f, 0xa6,
-	0x59, 0x57, 0x19, 0x4b, 0x41, 0xa2, 0xd2, 0x38, 0x1f, 0x01, 0xff, 0x43, 0xf4, 0xa8, 0xfb, 0x97,
-	0xaa, 0xa9, 0x1c, 0x4d, 0x24, 0xf5, 0xcd, 0x3e, 0x51, 0xea, 0x7a, 0xb0, 0x14, 0x8e, 0x09, 0x01,
-	0x24, 0x98, 0x67, 0xfa, 0xbd, 0x66, 0x7d, 0xa4, 0xa9, 0xf8, 0x2f, 0x44, 0x84, 0xf0, 0x49, 0xf7,
-	0x27, 0x9a, 0x89, 0x96, 0x1b, 0x13, 0x58, 0xde, 0xd7, 0x6c, 0x5e, 0x77, 0x43, 0x73, 0xb0, 0x1d,
-	0xcd, 0x13, 0x95, 0xae, 0xf2, 0x4b, 0x85, 0x57, 0xb0, 0x10, 0x2c, 0xaa, 0xc6, 0x52, 0x24, 0x5f,
-	0xf8, 0x11, 0xb8, 0x86, 0xa0, 0x60, 0x5a, 0xc8, 0x8b, 0x41, 0xc1, 0xbe, 0xe3, 0xa1, 0xe3, 0x86,
-	0x60, 0xec, 0xf0, 0x37, 0x5e, 0xc3, 0xe6, 0x62, 0x8c, 0xe7, 0xf6, 0x68, 0xcf, 0xb4, 0x94, 0xa2,
-	0x3f, 0x1e, 0x63, 0x50, 0x25, 0xc5, 0x12, 0x56, 0xa5, 0xa7, 0x9c, 0x56, 0x23, 0xe5, 0x9e, 0xf6,
-	0xb4, 0x1e, 0x69, 0x7f, 0x98, 0xc9, 0xe8, 0xb7, 0x9f, 0x01, 0x00, 0x00, 0xff, 0xff, 0x15, 0xc8,
-	0xe4, 0x30, 0x80, 0x01, 0x00, 0x00,
+	// 467 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x93, 0x41, 0x6f, 0xd3, 0x40,
+	0x10, 0x85, 0xb5, 0x71, 0x9a, 0xa4, 0x63, 0xb7, 0x4d, 0x47, 0x15, 0x1a, 0x45, 0x1c, 0x4c, 0x4f,
+	0xa6, 0x42, 0x3e, 0x94, 0x63, 0x4f, 0xa5, 0x88, 0x52, 0x29, 0x2a, 0x91, 0x63, 0x22, 0xae, 0x5b,
+	0x7b, 0x69, 0x2c, 0x6c, 0x6f, 0x64, 0xaf, 0x51, 0xfb, 0x83, 0xf8, 0x8d, 0x5c, 0xd1, 0xee, 0xda,
+	0xc1, 0x24, 0x95, 0xb8, 0xed, 0xf7, 0xb2, 0xf3, 0xe6, 0x69, 0xf3, 0x0c, 0x13, 0xf5, 0x14, 0x6e,
+	0x2a, 0xa9, 0x24, 0x3a, 0x42, 0xad, 0xcf, 0x7f, 0x8f, 0x60, 0xba, 0xd0, 0x18, 0x57, 0xbc, 0xac,
+	0x79, 0xa2, 0x32, 0x59, 0xe2, 0x39, 0x78, 0xd7, 0x49, 0x22, 0x9b, 0x52, 0xdd, 0xcb, 0x32, 0x11,
+	0xc4, 0x7c, 0x16, 0x0c, 0xa3, 0x7f, 0x34, 0x3c, 0x83, 0x83, 0x45, 0x95, 0x25, 0x82, 0x06, 0x3e,
+	0x0b, 0xbc, 0xc8, 0x02, 0xce, 0x60, 0x72, 0xcb, 0xeb, 0x79, 0x56, 0x64, 0x8a, 0x1c, 0x33, 0xb5,
+	0x65, 0x3d, 0xb1, 0xe2, 0x79, 0x23, 0x68, 0x68, 0x27, 0x0c, 0x20, 0xc1, 0x78, 0xc1, 0x9f, 0x73,
+	0xc9, 0x53, 0x3a, 0x30, 0x7a, 0x87, 0x88, 0x30, 0xfc, 0xcc, 0xeb, 0x35, 0x8d, 0x8c, 0x6c, 0xce,
+	0xe8, 0x83, 0xfb, 0x21, 0x97, 0xc9, 0x8f, 0xfb, 0xa6, 0x78, 0x10, 0x15, 0x8d, 0x7d, 0x16, 0x1c,
+	0x45, 0x7d, 0x09, 0x5f, 0xc3, 0xa1, 0xc1, 0x38, 0x2b, 0x04, 0x4d, 0x4c, 0x84, 0xbf, 0x02, 0x1e,
+	0xc3, 0x20, 0x96, 0x74, 0x68, 0x1c, 0x07, 0xb1, 0xd4, 0x3b, 0x3e, 0x55, 0xb2, 0x20, 0xb0, 0x3b,
+	0xf4, 0x19, 0x2f, 0x60, 0xda, 0x7b, 0x8c, 0xbb, 0x32, 0x15, 0x4f, 0xe4, 0x9a, 0x45, 0x7b, 0x3a,
+	0x7a, 0xc0, 0x56, 0xe4, 0x99, 0x61, 0xb6, 0xd2, 0x14, 0xd1, 0x91, 0xa5, 0x48, 0xd3, 0x92, 0x8e,
+	0x2d, 0x2d, 0xf1, 0x0a, 0xc6, 0x91, 0x48, 0x44, 0xb6, 0x51, 0x74, 0xe2, 0xb3, 0xc0, 0xbd, 0x7c,
+	0x13, 0x0a, 0xb5, 0x0e, 0x77, 0xdf, 0x3e, 0x6c, 0x2f, 0xc5, 0xcf, 0x1b, 0x11, 0x75, 0x13, 0xf8,
+	0x0d, 0x4e, 0xef, 0x4a, 0x25, 0xaa, 0x92, 0xe7, 0xe6, 0xee, 0x77, 0x51, 0xd5, 0x34, 0xf5, 0x9d,
+	0xc0, 0xbd, 0xbc, 0x78, 0xd9, 0x66, 0xf7, 0xba, 0xf1, 0xdb, 0x37, 0x99, 0xfd, 0x1a, 0x80, 0xdb,
+	0x5b, 0x89, 0x57, 0xe0, 0xcc, 0xe5, 0x23, 0x31, 0xe3, 0xfd, 0xf6, 0xbf, 0x11, 0xc3, 0xb9, 0x7c,
+	0x34, 0xd6, 0x7a, 0x4a, 0xff, 0x97, 0xb7, 0xbc, 0xfe, 0x5a, 0x8b, 0xb4, 0x6d, 0x45, 0x87, 0xf8,
+	0x0a, 0x46, 0x4b, 0xc5, 0x55, 0x53, 0x9b, 0x56, 0x78, 0x51, 0x4b, 0x18, 0xc0, 0xc9, 0x8d, 0x2c,
+	0x55, 0xc5, 0x13, 0x75, 0x9d, 0xa6, 0x95, 0xa8, 0xeb, 0xb6, 0x1d, 0xbb, 0x32, 0xbe, 0x83, 0xd3,
+	0x9b, 0xa6, 0x68, 0x72, 0xae, 0xb2, 0x9f, 0xa2, 0xdb, 0x62, 0x1b, 0xb3, 0xff, 0xc3, 0xec, 0x0b,
+	0x8c, 0xdb, 0x64, 0x3a, 0x54, 0x67, 0xcd, 0x6c, 0xa8, 0xce, 0x12, 0x61, 0xf8, 0x91, 0x2b, 0xde,
+	0x66, 0x35, 0x67, 0x1d, 0x34, 0x96, 0x9b, 0x2c, 0xd1, 0x41, 0x1d, 0x1d, 0xd4, 0xd2, 0x6c, 0x01,
+	0x67, 0x2f, 0x3d, 0xe9, 0xb6, 0x40, 0xac, 0x57, 0x20, 0x5b, 0xb2, 0xc1, 0xb6, 0x64, 0xdb, 0xe2,
+	0x3b, 0xbd, 0xe2, 0x3f, 0x8c, 0xcc, 0x57, 0xf8, 0xfe, 0xcf, 0x00, 0xaf, 0x4e, 0x14, 0x2a, 0x91,
+	0x03, 0x00, 0x00,
 }
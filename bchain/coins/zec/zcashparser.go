@@ -26,6 +26,7 @@ func NewZCashParser(c *btc.Configuration) *ZCashParser {
 		&bchain.BaseParser{
 			AddressFactory:       bchain.NewBaseAddress,
 			BlockAddressesToKeep: c.BlockAddressesToKeep,
+			AmountDecimalPoint:   8,
 		},
 	}
 }
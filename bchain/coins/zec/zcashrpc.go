@@ -22,6 +22,8 @@ func NewZCashRPC(config json.RawMessage, pushHandler func(bchain.NotificationTyp
 		BitcoinRPC: b.(*btc.BitcoinRPC),
 	}
 	z.RPCMarshaler = btc.JSONMarshalerV1{}
+	// the serialized transactions returned by GetTransactionsForMempool of BitcoinRPC cannot be parsed, the mempool must not use batches
+	z.ChainConfig.MempoolBatchSize = 1
 	return z, nil
 }
 
@@ -45,6 +47,10 @@ func (z *ZCashRPC) Initialize() error {
 		z.Network = "testnet"
 	}
 
+	if err = z.InitializeMQ(); err != nil {
+		return err
+	}
+
 	glog.Info("rpc: block chain ", params.Name)
 
 	return nil
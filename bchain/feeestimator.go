@@ -0,0 +1,137 @@
+package bchain
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// the probabilities of the confirmation within the target number of blocks of the fee levels
+const (
+	feeEstimatorSlowProbability     = 0.5
+	feeEstimatorStandardProbability = 0.8
+	feeEstimatorFastProbability     = 0.95
+)
+
+// feeEstimatorBlockPercentile is the percentile of the fee rates of a block taken as the lowest fee rate accepted by the block,
+// the cheapest transactions of the block are ignored as they are often included by the miner itself
+const feeEstimatorBlockPercentile = 10
+
+// FeeRateSample is the fee rate of a transaction and the size of the transaction in the units of the fee rate
+type FeeRateSample struct {
+	FeeRate *big.Int
+	Size    int
+}
+
+type feeEstimatorBlock struct {
+	height     uint32
+	hash       string
+	size       int
+	minFeeRate *big.Int
+}
+
+// FeeRateEstimator estimates the fee rate from the fee rates of the transactions in the last blocks and in the mempool
+// it is shared by the UTXO coins (fee rates in satoshi per kB, sizes in bytes) and by the gas price oracle of Ethereum,
+// which replaced GasPriceOracle and feeds the gas prices of the transactions, each with size 1
+type FeeRateEstimator struct {
+	mux       sync.Mutex
+	maxBlocks int
+	blocks    []feeEstimatorBlock
+}
+
+// NewFeeRateEstimator returns new estimator using maxBlocks last blocks
+func NewFeeRateEstimator(maxBlocks int) *FeeRateEstimator {
+	return &FeeRateEstimator{maxBlocks: maxBlocks}
+}
+
+// AddBlock adds the fee rates of the transactions of the block
+// blocks older than maxBlocks from the newest block are ignored, a block with the same height
+// and a different hash replaces the block and all blocks above it (reorg)
+func (e *FeeRateEstimator) AddBlock(height uint32, hash string, samples []FeeRateSample) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	l := len(e.blocks)
+	if l > 0 && int64(height) <= int64(e.blocks[l-1].height)-int64(e.maxBlocks) {
+		return
+	}
+	i := sort.Search(l, func(i int) bool { return e.blocks[i].height >= height })
+	if i < l && e.blocks[i].height == height {
+		if e.blocks[i].hash == hash {
+			return
+		}
+		e.blocks = e.blocks[:i]
+	}
+	// blocks without transactions do not tell anything about the fee rate
+	if len(samples) == 0 {
+		return
+	}
+	s := make([]FeeRateSample, len(samples))
+	copy(s, samples)
+	sort.Slice(s, func(i, j int) bool { return s[i].FeeRate.Cmp(s[j].FeeRate) < 0 })
+	b := feeEstimatorBlock{
+		height:     height,
+		hash:       hash,
+		minFeeRate: s[len(s)*feeEstimatorBlockPercentile/100].FeeRate,
+	}
+	for j := range s {
+		b.size += s[j].Size
+	}
+	e.blocks = append(e.blocks, feeEstimatorBlock{})
+	copy(e.blocks[i+1:], e.blocks[i:])
+	e.blocks[i] = b
+	for len(e.blocks) > 0 && int64(e.blocks[0].height) <= int64(e.blocks[len(e.blocks)-1].height)-int64(e.maxBlocks) {
+		e.blocks = e.blocks[1:]
+	}
+}
+
+// Estimate returns the fee levels for the confirmation within the number of blocks, using the fee rates of the pending transactions
+// the fee rate accepted by the fraction f of the last blocks is included within n blocks with the probability 1-(1-f)^n,
+// the estimate is raised if there are more pending transactions with a higher fee rate than fit into n blocks
+// returns nil if there are no blocks in the estimator
+func (e *FeeRateEstimator) Estimate(blocks int, pending []FeeRateSample) *FeeLevels {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if len(e.blocks) == 0 {
+		return nil
+	}
+	if blocks < 1 {
+		blocks = 1
+	}
+	mins := make([]*big.Int, len(e.blocks))
+	size := 0
+	for i := range e.blocks {
+		mins[i] = e.blocks[i].minFeeRate
+		size += e.blocks[i].size
+	}
+	sort.Slice(mins, func(i, j int) bool { return mins[i].Cmp(mins[j]) < 0 })
+	// the lowest fee rate of the pending transactions which fit into the blocks, from the most expensive
+	p := make([]FeeRateSample, len(pending))
+	copy(p, pending)
+	sort.Slice(p, func(i, j int) bool { return p[i].FeeRate.Cmp(p[j].FeeRate) > 0 })
+	var pendingFeeRate *big.Int
+	capacity := blocks * size / len(e.blocks)
+	for i := range p {
+		capacity -= p[i].Size
+		if capacity < 0 {
+			pendingFeeRate = p[i].FeeRate
+			break
+		}
+	}
+	estimate := func(probability float64, to *big.Int) {
+		f := 1 - math.Pow(1-probability, 1/float64(blocks))
+		i := int(math.Ceil(f*float64(len(mins)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		to.Set(mins[i])
+		if pendingFeeRate != nil && pendingFeeRate.Cmp(to) > 0 {
+			to.Set(pendingFeeRate)
+		}
+	}
+	r := &FeeLevels{Blocks: blocks}
+	estimate(feeEstimatorSlowProbability, &r.Slow)
+	estimate(feeEstimatorStandardProbability, &r.Standard)
+	estimate(feeEstimatorFastProbability, &r.Fast)
+	return r
+}
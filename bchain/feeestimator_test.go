@@ -0,0 +1,152 @@
+// +build unittest
+
+package bchain
+
+import (
+	"math/big"
+	"testing"
+)
+
+func feeRateSamples(size int, feeRates ...int64) []FeeRateSample {
+	r := make([]FeeRateSample, len(feeRates))
+	for i, f := range feeRates {
+		r[i] = FeeRateSample{FeeRate: big.NewInt(f), Size: size}
+	}
+	return r
+}
+
+func TestFeeRateEstimator_AddBlock(t *testing.T) {
+	e := NewFeeRateEstimator(3)
+	e.AddBlock(10, "a10", feeRateSamples(1, 5, 1, 3))
+	e.AddBlock(11, "a11", feeRateSamples(1, 2))
+	e.AddBlock(12, "a12", nil)
+	e.AddBlock(13, "a13", feeRateSamples(1, 7, 8))
+	e.AddBlock(14, "a14", feeRateSamples(1, 9))
+	// older than the window
+	e.AddBlock(11, "a11", feeRateSamples(1, 100))
+	// already known
+	e.AddBlock(13, "a13", feeRateSamples(1, 100))
+	checkBlocks := func(want []uint32, minFeeRates []int64) {
+		t.Helper()
+		if len(e.blocks) != len(want) {
+			t.Fatalf("blocks = %+v, want heights %v", e.blocks, want)
+		}
+		for i := range want {
+			if e.blocks[i].height != want[i] || e.blocks[i].minFeeRate.Int64() != minFeeRates[i] {
+				t.Errorf("block %d = %+v, want height %d, minFeeRate %d", i, e.blocks[i], want[i], minFeeRates[i])
+			}
+		}
+	}
+	checkBlocks([]uint32{13, 14}, []int64{7, 9})
+	// reorg
+	e.AddBlock(13, "b13", feeRateSamples(1, 4, 6))
+	checkBlocks([]uint32{13}, []int64{4})
+	e.AddBlock(15, "b15", feeRateSamples(1, 3))
+	e.AddBlock(14, "b14", feeRateSamples(1, 5))
+	checkBlocks([]uint32{13, 14, 15}, []int64{4, 5, 3})
+}
+
+func TestFeeRateEstimator_Estimate(t *testing.T) {
+	e := NewFeeRateEstimator(10)
+	if fl := e.Estimate(1, nil); fl != nil {
+		t.Errorf("Estimate() = %+v, want nil for empty estimator", fl)
+	}
+	for i := 1; i <= 10; i++ {
+		e.AddBlock(uint32(i), "", feeRateSamples(100, int64(i*10), int64(i*10+1)))
+	}
+	tests := []struct {
+		name    string
+		blocks  int
+		pending []FeeRateSample
+		want    []int64
+	}{
+		{
+			name:   "next block",
+			blocks: 1,
+			want:   []int64{50, 80, 100},
+		},
+		{
+			name:   "3 blocks",
+			blocks: 3,
+			want:   []int64{30, 50, 70},
+		},
+		{
+			name:   "invalid number of blocks",
+			blocks: 0,
+			want:   []int64{50, 80, 100},
+		},
+		{
+			name:    "full mempool",
+			blocks:  1,
+			pending: append(feeRateSamples(100, 200, 90, 10), feeRateSamples(50, 95, 93)...),
+			want:    []int64{90, 90, 100},
+		},
+		{
+			name:    "mempool fits into blocks",
+			blocks:  2,
+			pending: feeRateSamples(100, 200, 90, 95, 10),
+			want:    []int64{30, 60, 80},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl := e.Estimate(tt.blocks, tt.pending)
+			got := []int64{fl.Slow.Int64(), fl.Standard.Int64(), fl.Fast.Int64()}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Estimate() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+// the gas price oracle of Ethereum uses the estimator with the gas prices of the transactions, each transaction has size 1
+func TestFeeRateEstimator_EstimateGasPrices(t *testing.T) {
+	e := NewFeeRateEstimator(10)
+	for i := 1; i <= 10; i++ {
+		e.AddBlock(uint32(i), "", feeRateSamples(1, int64(i*10), int64(i*10+1)))
+	}
+	tests := []struct {
+		name    string
+		blocks  int
+		pending []FeeRateSample
+		want    []int64
+	}{
+		{
+			name:   "next block",
+			blocks: 1,
+			want:   []int64{50, 80, 100},
+		},
+		{
+			name:   "3 blocks",
+			blocks: 3,
+			want:   []int64{30, 50, 70},
+		},
+		{
+			name:    "full mempool",
+			blocks:  1,
+			pending: feeRateSamples(1, 200, 90, 95, 10),
+			want:    []int64{90, 90, 100},
+		},
+		{
+			name:    "mempool fits into blocks",
+			blocks:  2,
+			pending: feeRateSamples(1, 200, 90, 95, 10),
+			want:    []int64{30, 60, 80},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fl := e.Estimate(tt.blocks, tt.pending)
+			got := []int64{fl.Slow.Int64(), fl.Standard.Int64(), fl.Fast.Int64()}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Estimate() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package bchain
 
 import (
+	"math/big"
 	"sync"
 	"time"
 
@@ -13,6 +14,7 @@ type NonUTXOMempool struct {
 	mux             sync.Mutex
 	txToInputOutput map[string][]addrIndex
 	addrIDToTx      map[string][]outpoint
+	txToFeeRate     map[string]*big.Int
 }
 
 // NewNonUTXOMempool creates new mempool handler.
@@ -37,11 +39,24 @@ func (m *NonUTXOMempool) GetTransactions(address string) ([]string, error) {
 	return txs, nil
 }
 
-func (m *NonUTXOMempool) updateMappings(newTxToInputOutput map[string][]addrIndex, newAddrIDToTx map[string][]outpoint) {
+// GetFeeRateSamples returns the fee rates of the mempool transactions, which have the fee rate given by the transaction itself
+// the fee rate is per unit of the resources used by the transaction (gas), the size of each transaction is 1
+func (m *NonUTXOMempool) GetFeeRateSamples() []FeeRateSample {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	r := make([]FeeRateSample, 0, len(m.txToFeeRate))
+	for _, f := range m.txToFeeRate {
+		r = append(r, FeeRateSample{FeeRate: f, Size: 1})
+	}
+	return r
+}
+
+func (m *NonUTXOMempool) updateMappings(newTxToInputOutput map[string][]addrIndex, newAddrIDToTx map[string][]outpoint, newTxToFeeRate map[string]*big.Int) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	m.txToInputOutput = newTxToInputOutput
 	m.addrIDToTx = newAddrIDToTx
+	m.txToFeeRate = newTxToFeeRate
 }
 
 // Resync gets mempool transactions and maps outputs to transactions.
@@ -58,14 +73,19 @@ func (m *NonUTXOMempool) Resync(onNewTxAddr func(txid string, addr string)) (int
 	// allocate slightly larger capacity of the maps
 	newTxToInputOutput := make(map[string][]addrIndex, len(m.txToInputOutput)+5)
 	newAddrIDToTx := make(map[string][]outpoint, len(m.addrIDToTx)+5)
+	newTxToFeeRate := make(map[string]*big.Int, len(m.txToFeeRate)+5)
 	for _, txid := range txs {
 		io, exists := m.txToInputOutput[txid]
+		feeRate := m.txToFeeRate[txid]
 		if !exists {
 			tx, err := m.chain.GetTransactionForMempool(txid)
 			if err != nil {
 				glog.Error("cannot get transaction ", txid, ": ", err)
 				continue
 			}
+			if feeRate, err = parser.GetTxFeeRate(tx); err != nil {
+				glog.Error("cannot get fee rate of transaction ", txid, ": ", err)
+			}
 			io = make([]addrIndex, 0, len(tx.Vout)+len(tx.Vin))
 			for _, output := range tx.Vout {
 				addrID, err := parser.GetAddrIDFromVout(&output)
@@ -96,11 +116,14 @@ func (m *NonUTXOMempool) Resync(onNewTxAddr func(txid string, addr string)) (int
 			}
 		}
 		newTxToInputOutput[txid] = io
+		if feeRate != nil {
+			newTxToFeeRate[txid] = feeRate
+		}
 		for _, si := range io {
 			newAddrIDToTx[si.addrID] = append(newAddrIDToTx[si.addrID], outpoint{txid, si.n})
 		}
 	}
-	m.updateMappings(newTxToInputOutput, newAddrIDToTx)
+	m.updateMappings(newTxToInputOutput, newAddrIDToTx, newTxToFeeRate)
 	glog.Info("Mempool: resync finished in ", time.Since(start), ", ", len(m.txToInputOutput), " transactions in mempool")
 	return len(m.txToInputOutput), nil
 }
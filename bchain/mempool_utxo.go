@@ -1,6 +1,10 @@
 package bchain
 
 import (
+	"encoding/json"
+	"math/big"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -18,20 +22,75 @@ type outpoint struct {
 	vout int32
 }
 
+// spendingInput is the input of a mempool transaction which spends an outpoint
+type spendingInput struct {
+	txid string
+	vin  int
+}
+
 type txidio struct {
+	txid   string
+	io     []addrIndex
+	inputs []outpoint
+	entry  *mempoolTxEntry
+}
+
+// mempoolTxEntry is the fee in satoshi, the virtual size and the time of the first appearance of a mempool transaction
+type mempoolTxEntry struct {
+	fee   big.Int
+	vsize uint32
+	time  uint32
+}
+
+// feeRate returns the fee rate in satoshi per kB
+func (e *mempoolTxEntry) feeRate() FeeRateSample {
+	var r big.Int
+	r.Mul(&e.fee, big.NewInt(1000))
+	r.Div(&r, big.NewInt(int64(e.vsize)))
+	return FeeRateSample{FeeRate: &r, Size: int(e.vsize)}
+}
+
+// replacedTxsTTL is the time for which the replaced mempool transactions are remembered
+const replacedTxsTTL = 24 * time.Hour
+
+// mempoolReplacement is the transaction, which replaced a mempool transaction by spending the same outpoint
+type mempoolReplacement struct {
 	txid string
-	io   []addrIndex
+	time time.Time
 }
 
+// mempoolFeeRateBuckets are the lower bounds of the buckets of the fee rate histogram in satoshi per vbyte
+var mempoolFeeRateBuckets = []int64{0, 1, 2, 3, 4, 5, 6, 8, 10, 12, 15, 20, 30, 40, 50, 60, 70, 80, 90, 100,
+	125, 150, 175, 200, 250, 300, 350, 400, 500, 600, 700, 800, 900, 1000, 1200, 1400, 1700, 2000}
+
 // UTXOMempool is mempool handle.
 type UTXOMempool struct {
 	chain           BlockChain
 	mux             sync.Mutex
+	syncMux         sync.Mutex
 	txToInputOutput map[string][]addrIndex
 	addrIDToTx      map[string][]outpoint
+	txToInputs      map[string][]outpoint
+	spentOutpoints  map[outpoint]spendingInput
 	chanTxid        chan string
 	chanAddrIndex   chan txidio
 	onNewTxAddr     func(txid string, addr string)
+	txToFirstSeen   map[string]uint32
+	replacedBy      map[string]mempoolReplacement
+	feeEstimator    *FeeRateEstimator
+	txToFee         map[string]mempoolTxEntry
+	// blocksHeight is the best height at the last resync, the blocks above it are processed by the next resync
+	blocksHeight uint32
+	batchSize    int
+	batchGetter  mempoolBatchGetter
+}
+
+// mempoolBatchGetter is implemented by the chains which can get the transactions and the mempool entries in batches
+type mempoolBatchGetter interface {
+	// GetTransactionsForMempool returns the transactions by the transaction IDs, the transactions which could not be returned are nil
+	GetTransactionsForMempool(txids []string) ([]*Tx, error)
+	// GetMempoolEntries returns the mempool entries of the transactions, the entries which could not be returned are nil
+	GetMempoolEntries(txids []string) ([]*MempoolEntry, error)
 }
 
 // NewUTXOMempool creates new mempool handler.
@@ -44,22 +103,26 @@ func NewUTXOMempool(chain BlockChain, workers int, subworkers int) *UTXOMempool
 	}
 	for i := 0; i < workers; i++ {
 		go func(i int) {
-			chanInput := make(chan outpoint, 1)
-			chanResult := make(chan *addrIndex, 1)
+			chanInput := make(chan []outpoint, 1)
+			chanResult := make(chan []addrIndex, 1)
 			for j := 0; j < subworkers; j++ {
 				go func(j int) {
-					for input := range chanInput {
-						ai := m.getInputAddress(input)
-						chanResult <- ai
+					for inputs := range chanInput {
+						chanResult <- m.getInputAddresses(inputs)
 					}
 				}(j)
 			}
 			for txid := range m.chanTxid {
-				io, ok := m.getTxAddrs(txid, chanInput, chanResult)
+				io, inputs, ok := m.getTxAddrs(txid, chanInput, chanResult)
 				if !ok {
 					io = []addrIndex{}
 				}
-				m.chanAddrIndex <- txidio{txid, io}
+				// with batching the mempool entries of the new transactions are requested together at the end of the resync
+				var entry *mempoolTxEntry
+				if m.batchSize <= 1 {
+					entry = m.getMempoolTxEntry(txid)
+				}
+				m.chanAddrIndex <- txidio{txid, io, inputs, entry}
 			}
 		}(i)
 	}
@@ -84,11 +147,281 @@ func (m *UTXOMempool) GetTransactions(address string) ([]string, error) {
 	return txs, nil
 }
 
-func (m *UTXOMempool) updateMappings(newTxToInputOutput map[string][]addrIndex, newAddrIDToTx map[string][]outpoint) {
+// GetSpendingTx returns the mempool transaction and its input which spends the output vout of the transaction txid
+// if the output is not spent by any mempool transaction, the returned txid is empty
+func (m *UTXOMempool) GetSpendingTx(txid string, vout uint32) (string, int, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	si, found := m.spentOutpoints[outpoint{txid, int32(vout)}]
+	if !found {
+		return "", 0, nil
+	}
+	return si.txid, si.vin, nil
+}
+
+// GetFirstSeen returns the unix time when the transaction was first seen in mempool, 0 if the transaction is not in mempool
+func (m *UTXOMempool) GetFirstSeen(txid string) uint32 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.txToFirstSeen[txid]
+}
+
+// GetReplacedBy returns the transaction, which replaced the mempool transaction txid (RBF or double spend),
+// empty string if the replacement of the transaction is not known
+func (m *UTXOMempool) GetReplacedBy(txid string) string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.replacedBy[txid].txid
+}
+
+// findReplacedTxs returns the transactions, which left the mempool and whose input spends an outpoint spent now by another mempool transaction
+func (m *UTXOMempool) findReplacedTxs(newTxToInputs map[string][]outpoint, newSpentOutpoints map[outpoint]spendingInput) map[string]string {
+	replaced := make(map[string]string)
+	for txid, inputs := range m.txToInputs {
+		if _, found := newTxToInputs[txid]; found {
+			continue
+		}
+		for _, o := range inputs {
+			if o.txid == "" {
+				continue
+			}
+			if si, found := newSpentOutpoints[o]; found && si.txid != txid {
+				replaced[txid] = si.txid
+				break
+			}
+		}
+	}
+	return replaced
+}
+
+// updateReplacedBy adds the new replacements and removes the replacements older than replacedTxsTTL
+func (m *UTXOMempool) updateReplacedBy(replaced map[string]string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	now := time.Now()
+	if m.replacedBy == nil {
+		m.replacedBy = make(map[string]mempoolReplacement)
+	}
+	for txid, r := range m.replacedBy {
+		if now.Sub(r.time) > replacedTxsTTL {
+			delete(m.replacedBy, txid)
+		}
+	}
+	for txid, by := range replaced {
+		m.replacedBy[txid] = mempoolReplacement{txid: by, time: now}
+	}
+}
+
+// EnableFeeEstimation makes the mempool feed the estimator with the fee rates of the transactions confirmed in blocks
+// it must be called before the first Resync
+func (m *UTXOMempool) EnableFeeEstimation(e *FeeRateEstimator) {
+	m.feeEstimator = e
+}
+
+// EnableBatching makes the mempool resolve the addresses of the inputs in batches of size transactions
+// and get the mempool entries of the new transactions in batches
+// if the chain implements GetTransactionsForMempool, it must be called before the first Resync
+func (m *UTXOMempool) EnableBatching(size int) {
+	g, ok := m.chain.(mempoolBatchGetter)
+	if !ok {
+		glog.Warning("mempool: the chain does not support batches, batching disabled")
+		return
+	}
+	m.batchSize = size
+	m.batchGetter = g
+}
+
+// GetFeeRateSamples returns the fee rates in satoshi per kB and the sizes in bytes of the mempool transactions
+func (m *UTXOMempool) GetFeeRateSamples() []FeeRateSample {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	r := make([]FeeRateSample, 0, len(m.txToFee))
+	for _, e := range m.txToFee {
+		r = append(r, e.feeRate())
+	}
+	return r
+}
+
+// GetStats returns the number of transactions, their total virtual size and fees and the fee rate histogram of the mempool
+// the size and fees are known only for the transactions with the mempool entry
+func (m *UTXOMempool) GetStats() *MempoolStats {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	s := &MempoolStats{
+		Txs:              len(m.txToInputs),
+		FeeRateHistogram: make([]MempoolFeeRateBucket, len(mempoolFeeRateBuckets)),
+	}
+	for i, f := range mempoolFeeRateBuckets {
+		s.FeeRateHistogram[i].FeeRate = f
+	}
+	var feeRate big.Int
+	vsize := new(big.Int)
+	for _, e := range m.txToFee {
+		s.Vsize += int64(e.vsize)
+		s.TotalFee.Add(&s.TotalFee, &e.fee)
+		feeRate.Div(&e.fee, vsize.SetUint64(uint64(e.vsize)))
+		i := sort.Search(len(mempoolFeeRateBuckets), func(i int) bool { return mempoolFeeRateBuckets[i] > feeRate.Int64() }) - 1
+		if i < 0 {
+			i = 0
+		}
+		s.FeeRateHistogram[i].Txs++
+		s.FeeRateHistogram[i].Vsize += int64(e.vsize)
+	}
+	return s
+}
+
+func (m *UTXOMempool) updateMappings(newTxToInputOutput map[string][]addrIndex, newAddrIDToTx map[string][]outpoint,
+	newTxToInputs map[string][]outpoint, newSpentOutpoints map[outpoint]spendingInput, newTxToFee map[string]mempoolTxEntry,
+	newTxToFirstSeen map[string]uint32) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	m.txToInputOutput = newTxToInputOutput
 	m.addrIDToTx = newAddrIDToTx
+	m.txToInputs = newTxToInputs
+	m.spentOutpoints = newSpentOutpoints
+	m.txToFee = newTxToFee
+	m.txToFirstSeen = newTxToFirstSeen
+}
+
+// getMempoolTxEntry returns the fee, the virtual size and the time of the mempool transaction from the backend
+// some backends do not support mempool entries, the failures are therefore logged only with the verbose logging
+func (m *UTXOMempool) getMempoolTxEntry(txid string) *mempoolTxEntry {
+	e, err := m.chain.GetMempoolEntry(txid)
+	if err != nil {
+		glog.V(1).Info("cannot get mempool entry ", txid, ": ", err)
+		return nil
+	}
+	return m.newMempoolTxEntry(txid, e)
+}
+
+// newMempoolTxEntry converts the mempool entry of the backend, it returns nil if the entry does not contain the size
+func (m *UTXOMempool) newMempoolTxEntry(txid string, e *MempoolEntry) *mempoolTxEntry {
+	if e == nil || e.Size == 0 {
+		return nil
+	}
+	fee, err := m.chain.GetChainParser().AmountToBigInt(json.Number(strconv.FormatFloat(e.Fee, 'f', -1, 64)))
+	if err != nil {
+		glog.Error("invalid fee of mempool entry ", txid, ": ", err)
+		return nil
+	}
+	return &mempoolTxEntry{fee: fee, vsize: e.Size, time: uint32(e.Time)}
+}
+
+// addMempoolTxEntries gets the mempool entries of the new transactions in batches and adds them to txToFee,
+// the time of the first appearance is taken from the entry if it is known
+func (m *UTXOMempool) addMempoolTxEntries(txids []string, txToFee map[string]mempoolTxEntry, txToFirstSeen map[string]uint32) {
+	if len(txids) == 0 {
+		return
+	}
+	es, err := m.batchGetter.GetMempoolEntries(txids)
+	if err != nil {
+		glog.Error("cannot get mempool entries of ", len(txids), " transactions: ", err)
+		return
+	}
+	for i, txid := range txids {
+		entry := m.newMempoolTxEntry(txid, es[i])
+		if entry == nil {
+			continue
+		}
+		txToFee[txid] = *entry
+		if entry.time != 0 {
+			txToFirstSeen[txid] = entry.time
+		}
+	}
+}
+
+// maxConnectedBlocks is the maximal number of the blocks connected since the last resync, which are checked by processConnectedBlocks
+const maxConnectedBlocks = 25
+
+// processConnectedBlocks goes through the blocks connected since the last resync up to height, it feeds the fee estimator
+// with the mempool transactions confirmed in the blocks and adds to replaced the transactions, which left the mempool
+// because a conflicting transaction was mined (double spend), newTxToInputs are the inputs of the transactions in the new mempool
+func (m *UTXOMempool) processConnectedBlocks(height uint32, newTxToInputs map[string][]outpoint, replaced map[string]string) {
+	// the transactions in the mempool before the first resync are not known
+	if m.blocksHeight == 0 || height <= m.blocksHeight {
+		m.blocksHeight = height
+		return
+	}
+	from := m.blocksHeight + 1
+	if height-m.blocksHeight > maxConnectedBlocks {
+		from = height - maxConnectedBlocks + 1
+	}
+	vanished := make(map[string]struct{})
+	for txid := range m.txToInputs {
+		if _, found := newTxToInputs[txid]; found {
+			continue
+		}
+		if _, found := replaced[txid]; !found {
+			vanished[txid] = struct{}{}
+		}
+	}
+	hashes := make([]string, 0, height-from+1)
+	for h := from; h <= height; h++ {
+		hash, err := m.chain.GetBlockHash(h)
+		if err != nil {
+			glog.Error("cannot get block hash ", h, ": ", err)
+			break
+		}
+		txids, err := m.chain.GetBlockTxids(hash)
+		if err != nil {
+			glog.Error("cannot get txids of block ", h, ": ", err)
+			break
+		}
+		if m.feeEstimator != nil {
+			m.addConfirmedFeeRates(h, hash, txids, replaced)
+		}
+		for _, txid := range txids {
+			delete(vanished, txid)
+		}
+		hashes = append(hashes, hash)
+	}
+	m.blocksHeight = from + uint32(len(hashes)) - 1
+	if len(vanished) == 0 {
+		return
+	}
+	// some transactions left the mempool without being confirmed, find the mined transactions spending the same outpoints
+	spent := make(map[outpoint]string)
+	for i, hash := range hashes {
+		block, err := m.chain.GetBlock(hash, from+uint32(i))
+		if err != nil {
+			glog.Error("cannot get block ", hash, ": ", err)
+			return
+		}
+		for j := range block.Txs {
+			tx := &block.Txs[j]
+			for _, vin := range tx.Vin {
+				if vin.Txid != "" {
+					spent[outpoint{vin.Txid, int32(vin.Vout)}] = tx.Txid
+				}
+			}
+		}
+	}
+	for txid := range vanished {
+		for _, o := range m.txToInputs[txid] {
+			if by, found := spent[o]; found && o.txid != "" && by != txid {
+				replaced[txid] = by
+				break
+			}
+		}
+	}
+}
+
+// addConfirmedFeeRates feeds the fee estimator with the mempool transactions confirmed in the block,
+// the transactions reported as replaced are excluded
+func (m *UTXOMempool) addConfirmedFeeRates(height uint32, hash string, txids []string, replaced map[string]string) {
+	confirmed := make([]FeeRateSample, 0, len(txids))
+	for _, txid := range txids {
+		if _, found := replaced[txid]; found {
+			continue
+		}
+		if _, found := m.replacedBy[txid]; found {
+			continue
+		}
+		if e, found := m.txToFee[txid]; found {
+			confirmed = append(confirmed, e.feeRate())
+		}
+	}
+	m.feeEstimator.AddBlock(height, hash, confirmed)
 }
 
 func (m *UTXOMempool) getInputAddress(input outpoint) *addrIndex {
@@ -98,6 +431,11 @@ func (m *UTXOMempool) getInputAddress(input outpoint) *addrIndex {
 		glog.Error("cannot get transaction ", input.txid, ": ", err)
 		return nil
 	}
+	return m.getOutputAddress(itx, input)
+}
+
+// getOutputAddress returns the address of the output of itx spent by the input
+func (m *UTXOMempool) getOutputAddress(itx *Tx, input outpoint) *addrIndex {
 	if int(input.vout) >= len(itx.Vout) {
 		glog.Error("Vout len in transaction ", input.txid, " ", len(itx.Vout), " input.Vout=", input.vout)
 		return nil
@@ -108,16 +446,74 @@ func (m *UTXOMempool) getInputAddress(input outpoint) *addrIndex {
 		return nil
 	}
 	return &addrIndex{string(addrID), ^input.vout}
+}
 
+// getInputAddresses returns the addresses of the inputs, the input transactions are requested in one batch if batching is enabled
+func (m *UTXOMempool) getInputAddresses(inputs []outpoint) []addrIndex {
+	io := make([]addrIndex, 0, len(inputs))
+	if m.batchSize <= 1 || len(inputs) == 1 {
+		for _, input := range inputs {
+			if ai := m.getInputAddress(input); ai != nil {
+				io = append(io, *ai)
+			}
+		}
+		return io
+	}
+	txids := make([]string, 0, len(inputs))
+	txidIndex := make(map[string]int, len(inputs))
+	for _, input := range inputs {
+		if _, found := txidIndex[input.txid]; !found {
+			txidIndex[input.txid] = len(txids)
+			txids = append(txids, input.txid)
+		}
+	}
+	itxs, err := m.batchGetter.GetTransactionsForMempool(txids)
+	if err != nil {
+		glog.Error("cannot get transactions ", txids, ": ", err)
+		return io
+	}
+	for _, input := range inputs {
+		itx := itxs[txidIndex[input.txid]]
+		if itx == nil {
+			continue
+		}
+		if ai := m.getOutputAddress(itx, input); ai != nil {
+			io = append(io, *ai)
+		}
+	}
+	return io
 }
 
-func (m *UTXOMempool) getTxAddrs(txid string, chanInput chan outpoint, chanResult chan *addrIndex) ([]addrIndex, bool) {
+// getTxAddrs returns the addresses of the outputs and inputs of the transaction
+// and the outpoints spent by the inputs of the transaction in the order of inputs, coinbase input has an empty outpoint
+func (m *UTXOMempool) getTxAddrs(txid string, chanInput chan []outpoint, chanResult chan []addrIndex) ([]addrIndex, []outpoint, bool) {
 	tx, err := m.chain.GetTransactionForMempool(txid)
 	if err != nil {
 		glog.Error("cannot get transaction ", txid, ": ", err)
-		return nil, false
+		return nil, nil, false
 	}
 	glog.V(2).Info("mempool: gettxaddrs ", txid, ", ", len(tx.Vin), " inputs")
+	io, inputs := m.getAddrsFromTx(tx, chanInput, chanResult)
+	m.notifyTxAddrs(tx)
+	return io, inputs, true
+}
+
+// notifyTxAddrs notifies the addresses of the outputs of the transaction to the callback of the last Resync
+func (m *UTXOMempool) notifyTxAddrs(tx *Tx) {
+	if m.onNewTxAddr == nil {
+		return
+	}
+	for _, output := range tx.Vout {
+		if len(output.ScriptPubKey.Addresses) == 1 {
+			m.onNewTxAddr(tx.Txid, output.ScriptPubKey.Addresses[0])
+		}
+	}
+}
+
+// getAddrsFromTx returns the addresses and the spent outpoints of the transaction,
+// the addresses of the inputs are resolved by the subworkers, or sequentially if chanInput is nil
+func (m *UTXOMempool) getAddrsFromTx(tx *Tx, chanInput chan []outpoint, chanResult chan []addrIndex) ([]addrIndex, []outpoint) {
+	txid := tx.Txid
 	io := make([]addrIndex, 0, len(tx.Vout)+len(tx.Vin))
 	for _, output := range tx.Vout {
 		addrID, err := m.chain.GetChainParser().GetAddrIDFromVout(&output)
@@ -128,48 +524,142 @@ func (m *UTXOMempool) getTxAddrs(txid string, chanInput chan outpoint, chanResul
 		if len(addrID) > 0 {
 			io = append(io, addrIndex{string(addrID), int32(output.N)})
 		}
-		if m.onNewTxAddr != nil && len(output.ScriptPubKey.Addresses) == 1 {
-			m.onNewTxAddr(tx.Txid, output.ScriptPubKey.Addresses[0])
-		}
 	}
-	dispatched := 0
-	for _, input := range tx.Vin {
+	// the inputs are resolved in chunks, a chunk is one batch if batching is enabled
+	chunkSize := m.batchSize
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	var chunks [][]outpoint
+	inputs := make([]outpoint, len(tx.Vin))
+	for i, input := range tx.Vin {
 		if input.Coinbase != "" {
 			continue
 		}
 		o := outpoint{input.Txid, int32(input.Vout)}
+		inputs[i] = o
+		if len(chunks) == 0 || len(chunks[len(chunks)-1]) == chunkSize {
+			chunks = append(chunks, make([]outpoint, 0, chunkSize))
+		}
+		chunks[len(chunks)-1] = append(chunks[len(chunks)-1], o)
+	}
+	dispatched := 0
+	for _, chunk := range chunks {
+		if chanInput == nil {
+			io = append(io, m.getInputAddresses(chunk)...)
+			continue
+		}
 	loop:
 		for {
 			select {
 			// store as many processed results as possible
-			case ai := <-chanResult:
-				if ai != nil {
-					io = append(io, *ai)
-				}
+			case ais := <-chanResult:
+				io = append(io, ais...)
 				dispatched--
 			// send input to be processed
-			case chanInput <- o:
+			case chanInput <- chunk:
 				dispatched++
 				break loop
 			}
 		}
 	}
 	for i := 0; i < dispatched; i++ {
-		ai := <-chanResult
-		if ai != nil {
-			io = append(io, *ai)
+		io = append(io, <-chanResult...)
+	}
+	return io, inputs
+}
+
+// findConflictingTx returns a mempool transaction other than txid, which spends any of the outpoints, empty string if there is none
+// the caller must hold the lock mux
+func (m *UTXOMempool) findConflictingTx(txid string, inputs []outpoint) string {
+	for _, o := range inputs {
+		if o.txid == "" {
+			continue
+		}
+		if si, found := m.spentOutpoints[o]; found && si.txid != txid {
+			return si.txid
+		}
+	}
+	return ""
+}
+
+// AddTransaction adds a transaction received from the message queue to the mempool without the resync of the whole mempool,
+// the new addresses are notified to the callback of the last Resync
+// it returns false if the transaction was not added because it is not in the backend mempool (e.g. it is a transaction of a new block),
+// its mempool entry is not available or it replaces another mempool transaction, the mempool must be then resynchronized,
+// the resync removes the replaced transactions and reports them by onReplacedTx
+func (m *UTXOMempool) AddTransaction(tx *Tx) bool {
+	m.syncMux.Lock()
+	defer m.syncMux.Unlock()
+	if _, found := m.txToInputOutput[tx.Txid]; found {
+		return true
+	}
+	if _, found := m.txToInputs[tx.Txid]; found {
+		return true
+	}
+	// the entry confirms that the transaction is in the backend mempool, it is therefore requested even with batching
+	entry := m.getMempoolTxEntry(tx.Txid)
+	if entry == nil {
+		return false
+	}
+	io, inputs := m.getAddrsFromTx(tx, nil, nil)
+	m.mux.Lock()
+	if c := m.findConflictingTx(tx.Txid, inputs); c != "" {
+		m.mux.Unlock()
+		glog.V(1).Info("mempool: transaction ", tx.Txid, " conflicts with ", c)
+		return false
+	}
+	if m.txToInputOutput == nil {
+		m.txToInputOutput = make(map[string][]addrIndex)
+		m.addrIDToTx = make(map[string][]outpoint)
+		m.txToInputs = make(map[string][]outpoint)
+		m.spentOutpoints = make(map[outpoint]spendingInput)
+		m.txToFee = make(map[string]mempoolTxEntry)
+		m.txToFirstSeen = make(map[string]uint32)
+	}
+	if len(io) > 0 {
+		m.txToInputOutput[tx.Txid] = io
+		for _, si := range io {
+			m.addrIDToTx[si.addrID] = append(m.addrIDToTx[si.addrID], outpoint{tx.Txid, si.n})
+		}
+	}
+	if len(inputs) > 0 {
+		m.txToInputs[tx.Txid] = inputs
+		for i, o := range inputs {
+			if o.txid != "" {
+				m.spentOutpoints[o] = spendingInput{tx.Txid, i}
+			}
 		}
 	}
-	return io, true
+	m.txToFee[tx.Txid] = *entry
+	if entry.time != 0 {
+		m.txToFirstSeen[tx.Txid] = entry.time
+	} else {
+		m.txToFirstSeen[tx.Txid] = uint32(time.Now().Unix())
+	}
+	m.mux.Unlock()
+	// the addresses are notified only after the transaction is added, the callback can read the mempool
+	m.notifyTxAddrs(tx)
+	glog.V(2).Info("mempool: added transaction ", tx.Txid)
+	return true
 }
 
 // Resync gets mempool transactions and maps outputs to transactions.
+// The transactions replaced by other transactions spending the same outpoints are reported by onReplacedTx.
 // Resync is not reentrant, it should be called from a single thread.
 // Read operations (GetTransactions) are safe.
-func (m *UTXOMempool) Resync(onNewTxAddr func(txid string, addr string)) (int, error) {
+func (m *UTXOMempool) Resync(onNewTxAddr func(txid string, addr string), onReplacedTx func(txid string, replacedBy string)) (int, error) {
+	m.syncMux.Lock()
+	defer m.syncMux.Unlock()
 	start := time.Now()
 	glog.V(1).Info("mempool: resync")
+	// the callback is kept also for the transactions added by AddTransaction until the next Resync
 	m.onNewTxAddr = onNewTxAddr
+	// the height must be read before the mempool so that the transactions confirmed in a new block are not missed
+	height, err := m.chain.GetBestBlockHeight()
+	if err != nil {
+		return 0, err
+	}
 	txs, err := m.chain.GetMempool()
 	if err != nil {
 		return 0, err
@@ -178,42 +668,87 @@ func (m *UTXOMempool) Resync(onNewTxAddr func(txid string, addr string)) (int, e
 	// allocate slightly larger capacity of the maps
 	newTxToInputOutput := make(map[string][]addrIndex, len(m.txToInputOutput)+5)
 	newAddrIDToTx := make(map[string][]outpoint, len(m.addrIDToTx)+5)
+	newTxToInputs := make(map[string][]outpoint, len(m.txToInputs)+5)
+	newSpentOutpoints := make(map[outpoint]spendingInput, len(m.spentOutpoints)+5)
+	newTxToFee := make(map[string]mempoolTxEntry, len(m.txToFee)+5)
+	newTxToFirstSeen := make(map[string]uint32, len(m.txToFirstSeen)+5)
+	now := uint32(time.Now().Unix())
 	dispatched := 0
-	onNewData := func(txid string, io []addrIndex) {
+	var newTxids []string
+	onNewData := func(txid string, io []addrIndex, inputs []outpoint, entry *mempoolTxEntry) {
+		if entry != nil {
+			newTxToFee[txid] = *entry
+		}
+		// prefer the time of the first appearance reported by the backend, it survives also the restarts of blockbook
+		if fs, found := m.txToFirstSeen[txid]; found {
+			newTxToFirstSeen[txid] = fs
+		} else if entry != nil && entry.time != 0 {
+			newTxToFirstSeen[txid] = entry.time
+		} else {
+			newTxToFirstSeen[txid] = now
+		}
 		if len(io) > 0 {
 			newTxToInputOutput[txid] = io
 			for _, si := range io {
 				newAddrIDToTx[si.addrID] = append(newAddrIDToTx[si.addrID], outpoint{txid, si.n})
 			}
 		}
+		if len(inputs) > 0 {
+			newTxToInputs[txid] = inputs
+			for i, o := range inputs {
+				if o.txid != "" {
+					newSpentOutpoints[o] = spendingInput{txid, i}
+				}
+			}
+		}
 	}
 	// get transaction in parallel using goroutines created in NewUTXOMempool
 	for _, txid := range txs {
 		io, exists := m.txToInputOutput[txid]
+		if !exists {
+			// transactions without addresses are not in txToInputOutput but their inputs are known
+			_, exists = m.txToInputs[txid]
+		}
 		if !exists {
 		loop:
 			for {
 				select {
 				// store as many processed transactions as possible
 				case tio := <-m.chanAddrIndex:
-					onNewData(tio.txid, tio.io)
+					onNewData(tio.txid, tio.io, tio.inputs, tio.entry)
 					dispatched--
 				// send transaction to be processed
 				case m.chanTxid <- txid:
 					dispatched++
+					newTxids = append(newTxids, txid)
 					break loop
 				}
 			}
 		} else {
-			onNewData(txid, io)
+			var entry *mempoolTxEntry
+			if e, found := m.txToFee[txid]; found {
+				entry = &e
+			}
+			onNewData(txid, io, m.txToInputs[txid], entry)
 		}
 	}
 	for i := 0; i < dispatched; i++ {
 		tio := <-m.chanAddrIndex
-		onNewData(tio.txid, tio.io)
+		onNewData(tio.txid, tio.io, tio.inputs, tio.entry)
+	}
+	if m.batchSize > 1 {
+		m.addMempoolTxEntries(newTxids, newTxToFee, newTxToFirstSeen)
+	}
+	replaced := m.findReplacedTxs(newTxToInputs, newSpentOutpoints)
+	m.processConnectedBlocks(height, newTxToInputs, replaced)
+	m.updateMappings(newTxToInputOutput, newAddrIDToTx, newTxToInputs, newSpentOutpoints, newTxToFee, newTxToFirstSeen)
+	m.updateReplacedBy(replaced)
+	for txid, by := range replaced {
+		glog.Info("mempool: transaction ", txid, " replaced by ", by)
+		if onReplacedTx != nil {
+			onReplacedTx(txid, by)
+		}
 	}
-	m.updateMappings(newTxToInputOutput, newAddrIDToTx)
-	m.onNewTxAddr = nil
 	glog.Info("mempool: resync finished in ", time.Since(start), ", ", len(m.txToInputOutput), " transactions in mempool")
 	return len(m.txToInputOutput), nil
 }
@@ -0,0 +1,121 @@
+package bchain
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/juju/errors"
+)
+
+// mempoolStateVersion must be increased when the format of the persisted mempool state changes,
+// the state in a different version is ignored and the mempool is synchronized from scratch
+const mempoolStateVersion = 1
+
+// mempoolState is the persisted state of UTXOMempool
+type mempoolState struct {
+	Version int              `json:"version"`
+	Txs     []mempoolStateTx `json:"txs"`
+}
+
+// mempoolStateTx is a persisted mempool transaction, the mempool entry is present only if Fee is set
+type mempoolStateTx struct {
+	Txid      string                 `json:"txid"`
+	Addrs     []mempoolStateAddr     `json:"addrs,omitempty"`
+	Inputs    []mempoolStateOutpoint `json:"inputs,omitempty"`
+	Fee       *big.Int               `json:"fee,omitempty"`
+	Vsize     uint32                 `json:"vsize,omitempty"`
+	Time      uint32                 `json:"time,omitempty"`
+	FirstSeen uint32                 `json:"firstSeen,omitempty"`
+}
+
+// mempoolStateAddr is addrIndex, the addrID is binary and therefore stored as base64 encoded []byte
+type mempoolStateAddr struct {
+	AddrID []byte `json:"a"`
+	N      int32  `json:"n"`
+}
+
+type mempoolStateOutpoint struct {
+	Txid string `json:"t"`
+	Vout int32  `json:"v"`
+}
+
+// Pack returns the serialized state of the mempool, which can be restored by Unpack after restart
+// the transactions which could not be fetched from the backend are not stored
+func (m *UTXOMempool) Pack() ([]byte, error) {
+	m.mux.Lock()
+	s := mempoolState{Version: mempoolStateVersion, Txs: make([]mempoolStateTx, 0, len(m.txToInputs))}
+	add := func(txid string) {
+		st := mempoolStateTx{Txid: txid, FirstSeen: m.txToFirstSeen[txid]}
+		for _, ai := range m.txToInputOutput[txid] {
+			st.Addrs = append(st.Addrs, mempoolStateAddr{AddrID: []byte(ai.addrID), N: ai.n})
+		}
+		for _, o := range m.txToInputs[txid] {
+			st.Inputs = append(st.Inputs, mempoolStateOutpoint{Txid: o.txid, Vout: o.vout})
+		}
+		if e, found := m.txToFee[txid]; found {
+			st.Fee = new(big.Int).Set(&e.fee)
+			st.Vsize = e.vsize
+			st.Time = e.time
+		}
+		s.Txs = append(s.Txs, st)
+	}
+	for txid := range m.txToInputs {
+		add(txid)
+	}
+	// transactions without inputs with known outpoints
+	for txid := range m.txToInputOutput {
+		if _, found := m.txToInputs[txid]; !found {
+			add(txid)
+		}
+	}
+	m.mux.Unlock()
+	return json.Marshal(&s)
+}
+
+// Unpack restores the state of the mempool serialized by Pack, it must be called before the first Resync
+// the next Resync then fetches from the backend only the transactions which are not in the restored state
+func (m *UTXOMempool) Unpack(buf []byte) error {
+	var s mempoolState
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return errors.Annotatef(err, "mempool state")
+	}
+	if s.Version != mempoolStateVersion {
+		return errors.Errorf("mempool state version %v, expected %v", s.Version, mempoolStateVersion)
+	}
+	txToInputOutput := make(map[string][]addrIndex, len(s.Txs))
+	addrIDToTx := make(map[string][]outpoint, len(s.Txs))
+	txToInputs := make(map[string][]outpoint, len(s.Txs))
+	spentOutpoints := make(map[outpoint]spendingInput, len(s.Txs))
+	txToFee := make(map[string]mempoolTxEntry, len(s.Txs))
+	txToFirstSeen := make(map[string]uint32, len(s.Txs))
+	for _, st := range s.Txs {
+		if len(st.Addrs) > 0 {
+			io := make([]addrIndex, len(st.Addrs))
+			for i, a := range st.Addrs {
+				io[i] = addrIndex{string(a.AddrID), a.N}
+				addrIDToTx[io[i].addrID] = append(addrIDToTx[io[i].addrID], outpoint{st.Txid, a.N})
+			}
+			txToInputOutput[st.Txid] = io
+		}
+		if len(st.Inputs) > 0 {
+			inputs := make([]outpoint, len(st.Inputs))
+			for i, o := range st.Inputs {
+				inputs[i] = outpoint{o.Txid, o.Vout}
+				if o.Txid != "" {
+					spentOutpoints[inputs[i]] = spendingInput{st.Txid, i}
+				}
+			}
+			txToInputs[st.Txid] = inputs
+		}
+		if st.Fee != nil && st.Vsize > 0 {
+			txToFee[st.Txid] = mempoolTxEntry{fee: *st.Fee, vsize: st.Vsize, time: st.Time}
+		}
+		if st.FirstSeen != 0 {
+			txToFirstSeen[st.Txid] = st.FirstSeen
+		}
+	}
+	m.syncMux.Lock()
+	m.updateMappings(txToInputOutput, addrIDToTx, txToInputs, spentOutpoints, txToFee, txToFirstSeen)
+	m.syncMux.Unlock()
+	return nil
+}
@@ -0,0 +1,509 @@
+// +build unittest
+
+package bchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+func TestUTXOMempool_GetStats(t *testing.T) {
+	entry := func(fee int64, vsize uint32) mempoolTxEntry {
+		e := mempoolTxEntry{vsize: vsize, time: 1534858021}
+		e.fee.SetInt64(fee)
+		return e
+	}
+	m := &UTXOMempool{
+		txToInputs: map[string][]outpoint{"a": nil, "b": nil, "c": nil, "d": nil, "e": nil},
+		txToFee: map[string]mempoolTxEntry{
+			"a": entry(100, 200),
+			"b": entry(226, 226),
+			"c": entry(2000, 141),
+			"d": entry(11999, 1000),
+		},
+	}
+	got := m.GetStats()
+	if got.Txs != 5 || got.Vsize != 1567 || got.TotalFee.Int64() != 14325 {
+		t.Errorf("GetStats() = %+v, want 5 txs, vsize 1567, total fee 14325", got)
+	}
+	want := map[int64]MempoolFeeRateBucket{
+		0:  {FeeRate: 0, Txs: 1, Vsize: 200},
+		1:  {FeeRate: 1, Txs: 1, Vsize: 226},
+		10: {FeeRate: 10, Txs: 1, Vsize: 1000},
+		12: {FeeRate: 12, Txs: 1, Vsize: 141},
+	}
+	if len(got.FeeRateHistogram) != len(mempoolFeeRateBuckets) {
+		t.Fatalf("GetStats() histogram has %d buckets, want %d", len(got.FeeRateHistogram), len(mempoolFeeRateBuckets))
+	}
+	for i, b := range got.FeeRateHistogram {
+		w, found := want[mempoolFeeRateBuckets[i]]
+		if !found {
+			w = MempoolFeeRateBucket{FeeRate: mempoolFeeRateBuckets[i]}
+		}
+		if !reflect.DeepEqual(b, w) {
+			t.Errorf("GetStats() bucket %d = %+v, want %+v", i, b, w)
+		}
+	}
+	e := entry(2000, 141)
+	if f := e.feeRate(); f.FeeRate.Cmp(big.NewInt(14184)) != 0 || f.Size != 141 {
+		t.Errorf("feeRate() = %v, want 14184 satoshi per kB, size 141", f)
+	}
+}
+
+func TestUTXOMempool_findReplacedTxs(t *testing.T) {
+	m := &UTXOMempool{
+		txToInputs: map[string][]outpoint{
+			"a": {{"p1", 0}},
+			"b": {{"p1", 1}, {"p2", 0}},
+			"c": {{"p3", 0}},
+			"d": {{"", 0}},
+		},
+	}
+	newTxToInputs := map[string][]outpoint{
+		"a":  {{"p1", 0}},
+		"b2": {{"p2", 0}},
+		"e":  {{"p4", 0}},
+	}
+	newSpentOutpoints := map[outpoint]spendingInput{
+		{"p1", 0}: {"a", 0},
+		{"p2", 0}: {"b2", 0},
+		{"p4", 0}: {"e", 0},
+	}
+	got := m.findReplacedTxs(newTxToInputs, newSpentOutpoints)
+	want := map[string]string{"b": "b2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findReplacedTxs() = %v, want %v", got, want)
+	}
+	m.updateReplacedBy(got)
+	if r := m.GetReplacedBy("b"); r != "b2" {
+		t.Errorf("GetReplacedBy(b) = %v, want b2", r)
+	}
+	if r := m.GetReplacedBy("c"); r != "" {
+		t.Errorf("GetReplacedBy(c) = %v, want empty", r)
+	}
+}
+
+func TestUTXOMempool_findConflictingTx(t *testing.T) {
+	m := &UTXOMempool{
+		spentOutpoints: map[outpoint]spendingInput{
+			{"p1", 0}: {"a", 0},
+			{"p1", 1}: {"b", 0},
+			{"p2", 0}: {"b", 1},
+		},
+	}
+	tests := []struct {
+		name   string
+		txid   string
+		inputs []outpoint
+		want   string
+	}{
+		{
+			name:   "new outpoints",
+			txid:   "c",
+			inputs: []outpoint{{"p1", 2}, {"p3", 0}, {"", 0}},
+			want:   "",
+		},
+		{
+			name:   "same transaction",
+			txid:   "b",
+			inputs: []outpoint{{"p1", 1}, {"p2", 0}},
+			want:   "",
+		},
+		{
+			name:   "replacement",
+			txid:   "b2",
+			inputs: []outpoint{{"p3", 0}, {"p2", 0}},
+			want:   "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.findConflictingTx(tt.txid, tt.inputs); got != tt.want {
+				t.Errorf("findConflictingTx() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUTXOMempool_PackUnpack(t *testing.T) {
+	e := mempoolTxEntry{vsize: 141, time: 1534858021}
+	e.fee.SetInt64(2000)
+	m := &UTXOMempool{
+		txToInputOutput: map[string][]addrIndex{
+			"a": {{"\x00\xfe\x01", 0}, {"\x76\xa9", ^0}},
+			"c": {{"\x76\xa9", 1}},
+		},
+		addrIDToTx: map[string][]outpoint{
+			"\x00\xfe\x01": {{"a", 0}},
+			"\x76\xa9":     {{"a", ^0}, {"c", 1}},
+		},
+		txToInputs: map[string][]outpoint{
+			"a": {{"p1", 0}},
+			"b": {{"p2", 3}, {"a", 0}},
+		},
+		spentOutpoints: map[outpoint]spendingInput{
+			{"p1", 0}: {"a", 0},
+			{"p2", 3}: {"b", 0},
+			{"a", 0}:  {"b", 1},
+		},
+		txToFee:       map[string]mempoolTxEntry{"a": e},
+		txToFirstSeen: map[string]uint32{"a": 1534858021, "b": 1534858030, "c": 1534858040},
+	}
+	buf, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &UTXOMempool{}
+	if err = got.Unpack(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.txToInputOutput, m.txToInputOutput) {
+		t.Errorf("Unpack() txToInputOutput = %v, want %v", got.txToInputOutput, m.txToInputOutput)
+	}
+	if len(got.addrIDToTx) != len(m.addrIDToTx) || len(got.addrIDToTx["\x76\xa9"]) != 2 {
+		t.Errorf("Unpack() addrIDToTx = %v, want %v", got.addrIDToTx, m.addrIDToTx)
+	}
+	if !reflect.DeepEqual(got.txToInputs, m.txToInputs) {
+		t.Errorf("Unpack() txToInputs = %v, want %v", got.txToInputs, m.txToInputs)
+	}
+	if !reflect.DeepEqual(got.spentOutpoints, m.spentOutpoints) {
+		t.Errorf("Unpack() spentOutpoints = %v, want %v", got.spentOutpoints, m.spentOutpoints)
+	}
+	if g := got.txToFee["a"]; len(got.txToFee) != 1 || g.fee.Cmp(&e.fee) != 0 || g.vsize != e.vsize || g.time != e.time {
+		t.Errorf("Unpack() txToFee = %v, want %v", got.txToFee, m.txToFee)
+	}
+	if !reflect.DeepEqual(got.txToFirstSeen, m.txToFirstSeen) {
+		t.Errorf("Unpack() txToFirstSeen = %v, want %v", got.txToFirstSeen, m.txToFirstSeen)
+	}
+	if err = got.Unpack([]byte(`{"version":0}`)); err == nil {
+		t.Error("Unpack() of different version did not fail")
+	}
+}
+
+// testBlocksChain returns the blocks by height, the hash of the block is its height prefixed by "h"
+type testBlocksChain struct {
+	BlockChain
+	blocks map[uint32][]Tx
+}
+
+func (c *testBlocksChain) GetBlockHash(height uint32) (string, error) {
+	if _, found := c.blocks[height]; !found {
+		return "", errors.Errorf("block %d not found", height)
+	}
+	return "h" + strconv.Itoa(int(height)), nil
+}
+
+func (c *testBlocksChain) GetBlock(hash string, height uint32) (*Block, error) {
+	h, err := strconv.Atoi(hash[1:])
+	if err != nil {
+		return nil, err
+	}
+	return &Block{Txs: c.blocks[uint32(h)]}, nil
+}
+
+func (c *testBlocksChain) GetBlockTxids(hash string) ([]string, error) {
+	b, err := c.GetBlock(hash, 0)
+	if err != nil {
+		return nil, err
+	}
+	txids := make([]string, len(b.Txs))
+	for i := range b.Txs {
+		txids[i] = b.Txs[i].Txid
+	}
+	return txids, nil
+}
+
+func TestUTXOMempool_processConnectedBlocks(t *testing.T) {
+	entry := func(fee int64, vsize uint32) mempoolTxEntry {
+		e := mempoolTxEntry{vsize: vsize}
+		e.fee.SetInt64(fee)
+		return e
+	}
+	tx := func(txid string, inputs ...outpoint) Tx {
+		b := Tx{Txid: txid}
+		for _, o := range inputs {
+			b.Vin = append(b.Vin, Vin{Txid: o.txid, Vout: uint32(o.vout)})
+		}
+		return b
+	}
+	chain := &testBlocksChain{blocks: map[uint32][]Tx{
+		6: {tx("coinbase6"), tx("a", outpoint{"p", 0}), tx("b", outpoint{"p", 1})},
+		7: {tx("coinbase7"), tx("c"), tx("d"), tx("e"), tx("x", outpoint{"q", 1}, outpoint{"p", 2})},
+	}}
+	m := &UTXOMempool{
+		chain:        chain,
+		feeEstimator: NewFeeRateEstimator(10),
+		txToInputs: map[string][]outpoint{
+			"a": {{"p", 0}},
+			"b": {{"p", 1}},
+			"c": {{"r", 0}},
+			"d": nil,
+			"e": nil,
+			"f": nil,
+			"m": {{"s", 0}, {"p", 2}},
+			"n": {{"s", 1}},
+		},
+		txToFee: map[string]mempoolTxEntry{
+			"a": entry(1000, 100),
+			"b": entry(2000, 100),
+			"c": entry(3000, 100),
+			"d": entry(4000, 100),
+			"e": entry(5000, 200),
+			"f": entry(6000, 100),
+		},
+		replacedBy: map[string]mempoolReplacement{"d": {txid: "d2"}},
+	}
+	newTxToInputs := map[string][]outpoint{"f": nil}
+	// the first resync only sets the height, the mempool before it is not known
+	replaced := map[string]string{}
+	m.processConnectedBlocks(5, newTxToInputs, replaced)
+	if len(m.feeEstimator.blocks) != 0 || len(replaced) != 0 || m.blocksHeight != 5 {
+		t.Fatalf("first resync: blocks %+v, replaced %v, blocksHeight %d", m.feeEstimator.blocks, replaced, m.blocksHeight)
+	}
+	// block 8 is not available yet, the blocks 6 and 7 are processed one by one
+	replaced = map[string]string{"c": "c2"}
+	m.processConnectedBlocks(8, newTxToInputs, replaced)
+	want := []feeEstimatorBlock{
+		{height: 6, hash: "h6", size: 200, minFeeRate: big.NewInt(10000)},
+		{height: 7, hash: "h7", size: 200, minFeeRate: big.NewInt(25000)},
+	}
+	if !reflect.DeepEqual(m.feeEstimator.blocks, want) {
+		t.Errorf("processConnectedBlocks() blocks = %+v, want %+v", m.feeEstimator.blocks, want)
+	}
+	// m was double spent by the mined x, n left the mempool without a mined conflict
+	wantReplaced := map[string]string{"c": "c2", "m": "x"}
+	if !reflect.DeepEqual(replaced, wantReplaced) {
+		t.Errorf("processConnectedBlocks() replaced = %v, want %v", replaced, wantReplaced)
+	}
+	if m.blocksHeight != 7 {
+		t.Errorf("processConnectedBlocks() blocksHeight = %d, want 7", m.blocksHeight)
+	}
+	chain.blocks[8] = []Tx{tx("coinbase8"), tx("f")}
+	replaced = map[string]string{}
+	m.processConnectedBlocks(8, map[string][]outpoint{}, replaced)
+	if l := len(m.feeEstimator.blocks); l != 3 || m.feeEstimator.blocks[2].height != 8 || m.blocksHeight != 8 {
+		t.Errorf("processConnectedBlocks() blocks = %+v, blocksHeight %d, want block 8", m.feeEstimator.blocks, m.blocksHeight)
+	}
+	if len(replaced) != 0 {
+		t.Errorf("processConnectedBlocks() replaced = %v, want none", replaced)
+	}
+}
+
+type testMempoolParser struct {
+	BlockChainParser
+}
+
+func (p *testMempoolParser) AmountToBigInt(n json.Number) (big.Int, error) {
+	return (&BaseParser{AmountDecimalPoint: 8}).AmountToBigInt(n)
+}
+
+func (p *testMempoolParser) GetAddrIDFromVout(output *Vout) ([]byte, error) {
+	if len(output.ScriptPubKey.Addresses) != 1 {
+		return nil, ErrAddressMissing
+	}
+	return []byte(output.ScriptPubKey.Addresses[0]), nil
+}
+
+// testMempoolChain returns the mempool entries and the transactions from maps
+type testMempoolChain struct {
+	BlockChain
+	txs     map[string]*Tx
+	entries map[string]*MempoolEntry
+}
+
+func (c *testMempoolChain) GetChainParser() BlockChainParser {
+	return &testMempoolParser{}
+}
+
+func (c *testMempoolChain) GetMempoolEntry(txid string) (*MempoolEntry, error) {
+	if e, found := c.entries[txid]; found {
+		return e, nil
+	}
+	return nil, errors.Errorf("entry %v not found", txid)
+}
+
+func (c *testMempoolChain) GetTransactionForMempool(txid string) (*Tx, error) {
+	if tx, found := c.txs[txid]; found {
+		return tx, nil
+	}
+	return nil, errors.Errorf("tx %v not found", txid)
+}
+
+func TestUTXOMempool_AddTransaction(t *testing.T) {
+	out := func(n uint32, addr string) Vout {
+		return Vout{N: n, ScriptPubKey: ScriptPubKey{Addresses: []string{addr}}}
+	}
+	chain := &testMempoolChain{
+		txs: map[string]*Tx{
+			"p": {Txid: "p", Vout: []Vout{out(0, "A"), out(1, "B")}},
+		},
+		entries: map[string]*MempoolEntry{
+			"b": {Size: 200, Fee: 0.00001},
+			"c": {Size: 100, Fee: 0.00002},
+		},
+	}
+	m := &UTXOMempool{
+		chain:           chain,
+		txToInputOutput: map[string][]addrIndex{"a": {{"A", ^int32(0)}}},
+		addrIDToTx:      map[string][]outpoint{"A": {{"a", ^int32(0)}}},
+		txToInputs:      map[string][]outpoint{"a": {{"p", 0}}},
+		spentOutpoints:  map[outpoint]spendingInput{{"p", 0}: {"a", 0}},
+		txToFee:         map[string]mempoolTxEntry{},
+		txToFirstSeen:   map[string]uint32{},
+	}
+	type notification struct {
+		txid, addr string
+		txs        int
+	}
+	var got []notification
+	m.onNewTxAddr = func(txid, addr string) {
+		// the transaction is already in the mempool, which can be read by the callback
+		got = append(got, notification{txid, addr, m.GetStats().Txs})
+	}
+	// b conflicts with a, its addresses must not be notified
+	if m.AddTransaction(&Tx{Txid: "b", Vin: []Vin{{Txid: "p", Vout: 0}}, Vout: []Vout{out(0, "X")}}) {
+		t.Error("AddTransaction() of conflicting transaction returned true")
+	}
+	if len(got) != 0 {
+		t.Errorf("AddTransaction() of conflicting transaction notified %+v", got)
+	}
+	if !m.AddTransaction(&Tx{Txid: "c", Vin: []Vin{{Txid: "p", Vout: 1}}, Vout: []Vout{out(0, "Y")}}) {
+		t.Fatal("AddTransaction() returned false")
+	}
+	want := []notification{{"c", "Y", 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddTransaction() notified %+v, want %+v", got, want)
+	}
+	wantIO := []addrIndex{{"Y", 0}, {"B", ^int32(1)}}
+	if !reflect.DeepEqual(m.txToInputOutput["c"], wantIO) {
+		t.Errorf("AddTransaction() txToInputOutput = %+v, want %+v", m.txToInputOutput["c"], wantIO)
+	}
+}
+
+// testBatchMempoolChain gets the transactions in batches, a batch with the transaction "fail" fails as a whole
+type testBatchMempoolChain struct {
+	testMempoolChain
+	mux          sync.Mutex
+	batches      [][]string
+	entryBatches [][]string
+}
+
+func (c *testBatchMempoolChain) GetMempoolEntries(txids []string) ([]*MempoolEntry, error) {
+	c.mux.Lock()
+	c.entryBatches = append(c.entryBatches, txids)
+	c.mux.Unlock()
+	es := make([]*MempoolEntry, len(txids))
+	for i, txid := range txids {
+		es[i] = c.entries[txid]
+	}
+	return es, nil
+}
+
+func (c *testBatchMempoolChain) GetTransactionsForMempool(txids []string) ([]*Tx, error) {
+	c.mux.Lock()
+	c.batches = append(c.batches, txids)
+	c.mux.Unlock()
+	txs := make([]*Tx, len(txids))
+	for i, txid := range txids {
+		if txid == "fail" {
+			return nil, errors.New("batch failed")
+		}
+		txs[i] = c.txs[txid]
+	}
+	return txs, nil
+}
+
+func TestUTXOMempool_getAddrsFromTx_batches(t *testing.T) {
+	out := func(n uint32, addr string) Vout {
+		return Vout{N: n, ScriptPubKey: ScriptPubKey{Addresses: []string{addr}}}
+	}
+	chain := &testBatchMempoolChain{testMempoolChain: testMempoolChain{txs: map[string]*Tx{
+		"p": {Txid: "p", Vout: []Vout{out(0, "A"), out(1, "B")}},
+		"r": {Txid: "r", Vout: []Vout{out(0, "C")}},
+	}}}
+	m := &UTXOMempool{chain: chain}
+	m.EnableBatching(2)
+	// the inputs are resolved in the chunks {p:0, p:1}, {q:0, r:0} and {fail:0, fail:1},
+	// q is not returned by the batch and the last batch fails, their addresses are missing
+	tx := &Tx{
+		Txid: "t",
+		Vin: []Vin{
+			{Txid: "p", Vout: 0}, {Txid: "p", Vout: 1}, {Txid: "q", Vout: 0}, {Txid: "r", Vout: 0}, {Txid: "fail", Vout: 0}, {Txid: "fail", Vout: 1},
+		},
+		Vout: []Vout{out(0, "X")},
+	}
+	wantIO := []addrIndex{{"A", ^int32(0)}, {"B", ^int32(1)}, {"C", ^int32(0)}, {"X", 0}}
+	wantInputs := []outpoint{{"p", 0}, {"p", 1}, {"q", 0}, {"r", 0}, {"fail", 0}, {"fail", 1}}
+	wantBatches := [][]string{{"fail"}, {"p"}, {"q", "r"}}
+	sortIO := func(io []addrIndex) {
+		sort.Slice(io, func(i, j int) bool { return io[i].addrID < io[j].addrID })
+	}
+	chanInput := make(chan []outpoint, 1)
+	chanResult := make(chan []addrIndex, 1)
+	for i := 0; i < 2; i++ {
+		go func() {
+			for inputs := range chanInput {
+				chanResult <- m.getInputAddresses(inputs)
+			}
+		}()
+	}
+	defer close(chanInput)
+	for _, tt := range []struct {
+		name       string
+		chanInput  chan []outpoint
+		chanResult chan []addrIndex
+	}{
+		{"sequential", nil, nil},
+		{"subworkers", chanInput, chanResult},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			chain.batches = nil
+			io, inputs := m.getAddrsFromTx(tx, tt.chanInput, tt.chanResult)
+			sortIO(io)
+			if !reflect.DeepEqual(io, wantIO) {
+				t.Errorf("getAddrsFromTx() io = %+v, want %+v", io, wantIO)
+			}
+			if !reflect.DeepEqual(inputs, wantInputs) {
+				t.Errorf("getAddrsFromTx() inputs = %+v, want %+v", inputs, wantInputs)
+			}
+			sort.Slice(chain.batches, func(i, j int) bool { return chain.batches[i][0] < chain.batches[j][0] })
+			if !reflect.DeepEqual(chain.batches, wantBatches) {
+				t.Errorf("getAddrsFromTx() batches = %v, want %v", chain.batches, wantBatches)
+			}
+		})
+	}
+}
+
+func TestUTXOMempool_addMempoolTxEntries(t *testing.T) {
+	chain := &testBatchMempoolChain{testMempoolChain: testMempoolChain{entries: map[string]*MempoolEntry{
+		"b": {Size: 200, Fee: 0.00001, Time: 1534858021},
+		"c": {Size: 100, Fee: 0.00002},
+	}}}
+	m := &UTXOMempool{chain: chain}
+	m.EnableBatching(2)
+	txToFee := map[string]mempoolTxEntry{}
+	txToFirstSeen := map[string]uint32{"b": 1534859000, "c": 1534859000, "x": 1534859000}
+	// the entries are requested in one call, the chain splits them to batches, x has no entry
+	m.addMempoolTxEntries([]string{"b", "c", "x"}, txToFee, txToFirstSeen)
+	if want := [][]string{{"b", "c", "x"}}; !reflect.DeepEqual(chain.entryBatches, want) {
+		t.Errorf("addMempoolTxEntries() batches = %v, want %v", chain.entryBatches, want)
+	}
+	wantFee := map[string]mempoolTxEntry{
+		"b": {fee: *big.NewInt(1000), vsize: 200, time: 1534858021},
+		"c": {fee: *big.NewInt(2000), vsize: 100},
+	}
+	if !reflect.DeepEqual(txToFee, wantFee) {
+		t.Errorf("addMempoolTxEntries() txToFee = %+v, want %+v", txToFee, wantFee)
+	}
+	wantFirstSeen := map[string]uint32{"b": 1534858021, "c": 1534859000, "x": 1534859000}
+	if !reflect.DeepEqual(txToFirstSeen, wantFirstSeen) {
+		t.Errorf("addMempoolTxEntries() txToFirstSeen = %v, want %v", txToFirstSeen, wantFirstSeen)
+	}
+}
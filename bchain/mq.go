@@ -16,6 +16,7 @@ type MQ struct {
 	isRunning bool
 	finished  chan error
 	binding   string
+	topics    []string
 }
 
 // NotificationType is type of notification
@@ -28,11 +29,30 @@ const (
 	NotificationNewBlock NotificationType = iota
 	// NotificationNewTx message is sent when there is a new mempool transaction
 	NotificationNewTx NotificationType = iota
+	// NotificationMissedBlock message is sent when a gap in the sequence of block notifications is detected
+	NotificationMissedBlock NotificationType = iota
+	// NotificationMissedTx message is sent when a gap in the sequence of transaction notifications is detected
+	NotificationMissedTx NotificationType = iota
 )
 
+// mqSequences are the last sequence numbers of the message queue notifications by topic
+type mqSequences map[string]uint32
+
+// update stores the sequence number of the topic and returns the number of notifications missed since the previous one
+// the sequence numbers are reset by the restart of the backend, which is also reported as a gap
+func (s mqSequences) update(topic string, sequence uint32) uint32 {
+	last, found := s[topic]
+	s[topic] = sequence
+	if !found {
+		return 0
+	}
+	return sequence - last - 1
+}
+
 // NewMQ creates new Bitcoind ZeroMQ listener
-// callback function receives messages
-func NewMQ(binding string, callback func(NotificationType)) (*MQ, error) {
+// callback function receives messages with their payload, which is the hash of the block or transaction,
+// or the serialized transaction or block if rawTx or rawBlock is set and rawtx or rawblock is subscribed instead of hashtx or hashblock
+func NewMQ(binding string, rawTx bool, rawBlock bool, callback func(NotificationType, []byte)) (*MQ, error) {
 	context, err := zmq.NewContext()
 	if err != nil {
 		return nil, err
@@ -41,29 +61,31 @@ func NewMQ(binding string, callback func(NotificationType)) (*MQ, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = socket.SetSubscribe("hashblock")
-	if err != nil {
-		return nil, err
+	topics := []string{"hashblock", "hashtx"}
+	if rawBlock {
+		topics[0] = "rawblock"
 	}
-	err = socket.SetSubscribe("hashtx")
-	if err != nil {
-		return nil, err
+	if rawTx {
+		topics[1] = "rawtx"
+	}
+	// lost raw notifications are not fatal, mempool and index are periodically resynchronized
+	for _, t := range topics {
+		err = socket.SetSubscribe(t)
+		if err != nil {
+			return nil, err
+		}
 	}
-	// for now do not use raw subscriptions - we would have to handle skipped/lost notifications from zeromq
-	// on each notification we do sync or syncmempool respectively
-	// socket.SetSubscribe("rawblock")
-	// socket.SetSubscribe("rawtx")
 	err = socket.Connect(binding)
 	if err != nil {
 		return nil, err
 	}
-	glog.Info("MQ listening to ", binding)
-	mq := &MQ{context, socket, true, make(chan error), binding}
+	glog.Info("MQ listening to ", binding, ", topics ", topics)
+	mq := &MQ{context, socket, true, make(chan error), binding, topics}
 	go mq.run(callback)
 	return mq, nil
 }
 
-func (mq *MQ) run(callback func(NotificationType)) {
+func (mq *MQ) run(callback func(NotificationType, []byte)) {
 	defer func() {
 		if r := recover(); r != nil {
 			glog.Error("MQ loop recovered from ", r)
@@ -73,6 +95,7 @@ func (mq *MQ) run(callback func(NotificationType)) {
 		mq.finished <- nil
 	}()
 	mq.isRunning = true
+	sequences := make(mqSequences)
 	for {
 		msg, err := mq.socket.RecvMessageBytes(0)
 		if err != nil {
@@ -83,26 +106,32 @@ func (mq *MQ) run(callback func(NotificationType)) {
 			time.Sleep(100 * time.Millisecond)
 		}
 		if msg != nil && len(msg) >= 3 {
-			var nt NotificationType
+			var nt, missedNt NotificationType
 			switch string(msg[0]) {
-			case "hashblock":
+			case "hashblock", "rawblock":
 				nt = NotificationNewBlock
+				missedNt = NotificationMissedBlock
 				break
-			case "hashtx":
+			case "hashtx", "rawtx":
 				nt = NotificationNewTx
+				missedNt = NotificationMissedTx
 				break
 			default:
 				nt = NotificationUnknown
 				glog.Infof("MQ: NotificationUnknown %v", string(msg[0]))
 			}
-			if glog.V(2) {
-				sequence := uint32(0)
-				if len(msg[len(msg)-1]) == 4 {
-					sequence = binary.LittleEndian.Uint32(msg[len(msg)-1])
+			sequence := uint32(0)
+			if len(msg[len(msg)-1]) == 4 {
+				sequence = binary.LittleEndian.Uint32(msg[len(msg)-1])
+				if missed := sequences.update(string(msg[0]), sequence); missed != 0 && nt != NotificationUnknown {
+					glog.Warningf("MQ: %s missed %d notifications before sequence %d", string(msg[0]), missed, sequence)
+					callback(missedNt, nil)
 				}
+			}
+			if glog.V(2) {
 				glog.Infof("MQ: %v %s-%d", nt, string(msg[0]), sequence)
 			}
-			callback(nt)
+			callback(nt, msg[1])
 		}
 	}
 }
@@ -113,13 +142,11 @@ func (mq *MQ) Shutdown(ctx context.Context) error {
 	if mq.isRunning {
 		go func() {
 			// if errors in the closing sequence, let it close ungracefully
-			if err := mq.socket.SetUnsubscribe("hashtx"); err != nil {
-				mq.finished <- err
-				return
-			}
-			if err := mq.socket.SetUnsubscribe("hashblock"); err != nil {
-				mq.finished <- err
-				return
+			for _, t := range mq.topics {
+				if err := mq.socket.SetUnsubscribe(t); err != nil {
+					mq.finished <- err
+					return
+				}
 			}
 			if err := mq.socket.Unbind(mq.binding); err != nil {
 				mq.finished <- err
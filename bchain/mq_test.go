@@ -0,0 +1,30 @@
+// +build unittest
+
+package bchain
+
+import "testing"
+
+func Test_mqSequences_update(t *testing.T) {
+	s := make(mqSequences)
+	tests := []struct {
+		topic    string
+		sequence uint32
+		want     uint32
+	}{
+		{"hashblock", 10, 0},
+		{"hashtx", 0, 0},
+		{"hashtx", 1, 0},
+		{"hashblock", 11, 0},
+		{"hashtx", 5, 3},
+		{"hashblock", 12, 0},
+		{"hashtx", 0xffffffff, 0xfffffff9},
+		{"hashtx", 0, 0},
+		// restart of the backend resets the sequence
+		{"hashblock", 0, 0xfffffff3},
+	}
+	for i, tt := range tests {
+		if got := s.update(tt.topic, tt.sequence); got != tt.want {
+			t.Errorf("%d: update(%v, %v) = %v, want %v", i, tt.topic, tt.sequence, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package bchain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultRPCMaxErrorRate is the default maximal rate of failed requests of a healthy endpoint
+	DefaultRPCMaxErrorRate = 0.1
+	// DefaultRPCHealthCheckPeriod is the default period of the health checks of the endpoints in seconds
+	DefaultRPCHealthCheckPeriod = 10
+	// minRequestsForErrorRate is the number of requests in the health check period below which the error rate is not evaluated
+	minRequestsForErrorRate = 10
+)
+
+// RPCEndpoint is a backend endpoint with its health state
+type RPCEndpoint struct {
+	URL string
+	// Index is the position of the endpoint in the configuration, the first endpoint is the preferred one
+	Index    int
+	healthy  bool
+	height   uint32
+	requests int
+	errors   int
+}
+
+// RPCEndpoints selects a healthy backend endpoint for the requests
+// an endpoint is healthy if it responds to the health check, its best height does not lag
+// more than maxHeightLag blocks behind the other endpoints and its rate of failed requests does not exceed maxErrorRate
+type RPCEndpoints struct {
+	mux          sync.Mutex
+	endpoints    []*RPCEndpoint
+	active       int
+	maxHeightLag uint32
+	maxErrorRate float64
+}
+
+// NewRPCEndpoints creates the endpoints from the urls, the first url is the preferred endpoint
+func NewRPCEndpoints(urls []string, maxHeightLag uint32, maxErrorRate float64) *RPCEndpoints {
+	e := &RPCEndpoints{
+		endpoints:    make([]*RPCEndpoint, len(urls)),
+		maxHeightLag: maxHeightLag,
+		maxErrorRate: maxErrorRate,
+	}
+	for i, u := range urls {
+		e.endpoints[i] = &RPCEndpoint{URL: u, Index: i, healthy: true}
+	}
+	return e
+}
+
+// Len returns the number of the endpoints
+func (e *RPCEndpoints) Len() int {
+	return len(e.endpoints)
+}
+
+// All returns all endpoints regardless of their health
+func (e *RPCEndpoints) All() []*RPCEndpoint {
+	return e.endpoints
+}
+
+// Get returns the active endpoint, which is the first healthy endpoint
+func (e *RPCEndpoints) Get() *RPCEndpoint {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.endpoints[e.active]
+}
+
+// Observe counts the request to the endpoint, err is the transport error of the request
+func (e *RPCEndpoints) Observe(ep *RPCEndpoint, err error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	ep.requests++
+	if err != nil {
+		ep.errors++
+	}
+}
+
+// Fail marks the endpoint unhealthy after a failed request and returns the next active endpoint
+// the endpoint becomes healthy again after a successful health check
+func (e *RPCEndpoints) Fail(ep *RPCEndpoint, err error) *RPCEndpoint {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	ep.requests++
+	ep.errors++
+	if len(e.endpoints) > 1 && ep.healthy {
+		glog.Warning("rpc: endpoint ", ep.URL, " failed: ", err)
+		ep.healthy = false
+		e.selectActive()
+	}
+	return e.endpoints[e.active]
+}
+
+// selectActive sets the first healthy endpoint as the active one,
+// if there is no healthy endpoint, the endpoint with the highest known height is used
+func (e *RPCEndpoints) selectActive() {
+	active := -1
+	for i, ep := range e.endpoints {
+		if ep.healthy {
+			active = i
+			break
+		}
+	}
+	if active < 0 {
+		active = 0
+		for i, ep := range e.endpoints {
+			if ep.height > e.endpoints[active].height {
+				active = i
+			}
+		}
+	}
+	if active != e.active {
+		glog.Warning("rpc: switching from endpoint ", e.endpoints[e.active].URL, " to ", e.endpoints[active].URL)
+		e.active = active
+	}
+}
+
+// CheckHealth gets the best heights of the endpoints using getHeight, evaluates the health of the endpoints
+// and selects the active endpoint, the error counters are reset by each check
+func (e *RPCEndpoints) CheckHealth(getHeight func(ep *RPCEndpoint) (uint32, error)) {
+	heights := make([]uint32, len(e.endpoints))
+	errs := make([]error, len(e.endpoints))
+	var best uint32
+	for i, ep := range e.endpoints {
+		heights[i], errs[i] = getHeight(ep)
+		if errs[i] == nil && heights[i] > best {
+			best = heights[i]
+		}
+	}
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	for i, ep := range e.endpoints {
+		healthy := true
+		if errs[i] != nil {
+			healthy = false
+			glog.V(1).Info("rpc: endpoint ", ep.URL, " health check error: ", errs[i])
+		} else {
+			ep.height = heights[i]
+			if ep.height+e.maxHeightLag < best {
+				healthy = false
+				glog.V(1).Info("rpc: endpoint ", ep.URL, " lags at height ", ep.height, ", best height ", best)
+			}
+			if ep.requests >= minRequestsForErrorRate && float64(ep.errors)/float64(ep.requests) > e.maxErrorRate {
+				healthy = false
+				glog.V(1).Info("rpc: endpoint ", ep.URL, " failed ", ep.errors, " of ", ep.requests, " requests")
+			}
+		}
+		if healthy != ep.healthy {
+			glog.Info("rpc: endpoint ", ep.URL, " healthy ", healthy)
+			ep.healthy = healthy
+		}
+		ep.requests = 0
+		ep.errors = 0
+	}
+	e.selectActive()
+}
+
+// RunHealthChecks checks the health of the endpoints periodically until done is closed,
+// onCheck (if not nil) is called after each check with the active endpoint
+func (e *RPCEndpoints) RunHealthChecks(period time.Duration, getHeight func(ep *RPCEndpoint) (uint32, error), onCheck func(active *RPCEndpoint), done chan struct{}) {
+	glog.Info("rpc: checking health of ", len(e.endpoints), " endpoints every ", period)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.CheckHealth(getHeight)
+			if onCheck != nil {
+				onCheck(e.Get())
+			}
+		case <-done:
+			return
+		}
+	}
+}
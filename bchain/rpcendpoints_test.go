@@ -0,0 +1,59 @@
+// +build unittest
+
+package bchain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRPCEndpoints(t *testing.T) {
+	e := NewRPCEndpoints([]string{"a", "b", "c"}, 2, 0.1)
+	if ep := e.Get(); ep.URL != "a" || ep.Index != 0 {
+		t.Fatalf("Get() = %+v, want a", ep)
+	}
+	heights := map[string]uint32{"a": 100, "b": 101, "c": 101}
+	errs := map[string]error{}
+	getHeight := func(ep *RPCEndpoint) (uint32, error) {
+		return heights[ep.URL], errs[ep.URL]
+	}
+	check := func(step string, want string) {
+		e.CheckHealth(getHeight)
+		if ep := e.Get(); ep.URL != want {
+			t.Errorf("%s: Get() = %v, want %v", step, ep.URL, want)
+		}
+	}
+	check("all healthy", "a")
+	// a lags more than 2 blocks
+	heights["b"] = 103
+	heights["c"] = 103
+	check("lagging a", "b")
+	heights["a"] = 103
+	check("a catches up", "a")
+	// failed request of a switches immediately to b
+	if ep := e.Fail(e.Get(), errors.New("connection refused")); ep.URL != "b" {
+		t.Errorf("Fail() = %v, want b", ep.URL)
+	}
+	check("a recovered", "a")
+	// a has more than 10% of failed requests
+	for i := 0; i < 20; i++ {
+		var err error
+		if i%4 == 0 {
+			err = errors.New("timeout")
+		}
+		e.Observe(e.endpoints[0], err)
+	}
+	check("a with errors", "b")
+	check("a without errors", "a")
+	// a and b are not reachable
+	errs["a"] = errors.New("connection refused")
+	errs["b"] = errors.New("connection refused")
+	check("a and b down", "c")
+	// no healthy endpoint, the endpoint with the highest known height is used
+	errs["c"] = errors.New("connection refused")
+	heights["b"] = 105
+	errs["b"] = nil
+	heights["c"] = 110
+	errs["c"] = errors.New("connection refused")
+	check("b lags", "b")
+}
@@ -229,7 +229,7 @@ func (rt *Test) TestMempoolSync(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		txs := rt.getMempool(t)
 
-		n, err := rt.Client.ResyncMempool(nil)
+		n, err := rt.Client.ResyncMempool(nil, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -441,6 +441,7 @@ func (rt *Test) TestGetBlockHeader(t *testing.T) {
 	got.Confirmations = 0
 
 	got.Prev, got.Next = "", ""
+	got.Size, got.Time = 0, 0
 
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("GetBlockHeader() got=%v, want=%v", got, want)
@@ -148,7 +148,7 @@ func (m *ProtoTransaction_VinType) GetAddresses() []string {
 }
 
 type ProtoTransaction_VoutType struct {
-	Value           float64  `protobuf:"fixed64,1,opt,name=Value" json:"Value,omitempty"`
+	ValueSat        []byte   `protobuf:"bytes,1,opt,name=ValueSat,proto3" json:"ValueSat,omitempty"`
 	N               uint32   `protobuf:"varint,2,opt,name=N" json:"N,omitempty"`
 	ScriptPubKeyHex []byte   `protobuf:"bytes,3,opt,name=ScriptPubKeyHex,proto3" json:"ScriptPubKeyHex,omitempty"`
 	Addresses       []string `protobuf:"bytes,4,rep,name=Addresses" json:"Addresses,omitempty"`
@@ -159,11 +159,11 @@ func (m *ProtoTransaction_VoutType) String() string            { return proto.Co
 func (*ProtoTransaction_VoutType) ProtoMessage()               {}
 func (*ProtoTransaction_VoutType) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
 
-func (m *ProtoTransaction_VoutType) GetValue() float64 {
+func (m *ProtoTransaction_VoutType) GetValueSat() []byte {
 	if m != nil {
-		return m.Value
+		return m.ValueSat
 	}
-	return 0
+	return nil
 }
 
 func (m *ProtoTransaction_VoutType) GetN() uint32 {
@@ -196,26 +196,26 @@ func init() {
 func init() { proto.RegisterFile("tx.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 330 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x92, 0xc1, 0x4e, 0xc2, 0x40,
-	0x10, 0x86, 0xb3, 0xb4, 0x14, 0x18, 0x21, 0x92, 0x89, 0x31, 0x0d, 0xf1, 0x50, 0x39, 0xf5, 0xd4,
-	0x03, 0xc6, 0x07, 0x50, 0x2f, 0x24, 0x1a, 0x42, 0x16, 0xd2, 0x7b, 0x5b, 0x36, 0xb0, 0x11, 0x77,
-	0xb1, 0xdd, 0x1a, 0x78, 0x16, 0x1f, 0xc1, 0x97, 0x34, 0x3b, 0x2d, 0x45, 0x48, 0xbc, 0xed, 0xff,
-	0xef, 0x4c, 0xff, 0x6f, 0xff, 0x14, 0xba, 0x66, 0x1f, 0xed, 0x72, 0x6d, 0x34, 0x7a, 0x69, 0xb6,
-	0x49, 0xa4, 0x1a, 0x7f, 0xbb, 0x30, 0x9c, 0x5b, 0x67, 0x99, 0x27, 0xaa, 0x48, 0x32, 0x23, 0xb5,
-	0x42, 0x04, 0x77, 0xb9, 0x97, 0x2b, 0x9f, 0x05, 0x2c, 0xec, 0x73, 0x3a, 0xe3, 0x10, 0x9c, 0xa9,
-	0xd8, 0xfb, 0x2d, 0xb2, 0xec, 0x11, 0xef, 0xa0, 0xf7, 0xbc, 0xd5, 0xd9, 0xbb, 0x91, 0x1f, 0xc2,
-	0x77, 0x02, 0x16, 0xba, 0xfc, 0x64, 0xe0, 0x08, 0xba, 0x6f, 0xc7, 0x4b, 0x37, 0x60, 0xe1, 0x80,
-	0x37, 0x1a, 0x6f, 0xc1, 0x9b, 0x0a, 0xb9, 0xde, 0x18, 0xbf, 0x4d, 0x37, 0xb5, 0xc2, 0x09, 0x38,
-	0xb1, 0x54, 0xbe, 0x17, 0x38, 0xe1, 0xd5, 0x24, 0x88, 0x2a, 0xc4, 0xe8, 0x12, 0x2f, 0x8a, 0xa5,
-	0x5a, 0x1e, 0x76, 0x82, 0xdb, 0x61, 0x7c, 0x04, 0x37, 0xd6, 0xa5, 0xf1, 0x3b, 0xb4, 0x74, 0xff,
-	0xff, 0x92, 0x2e, 0x0d, 0x6d, 0xd1, 0xf8, 0xe8, 0x87, 0x41, 0xa7, 0xfe, 0x8e, 0x45, 0x7d, 0xd1,
-	0x52, 0xa5, 0x49, 0x21, 0xe8, 0xc9, 0x3d, 0xde, 0xe8, 0xa6, 0x8a, 0xd6, 0x9f, 0x2a, 0xb0, 0x8e,
-	0x74, 0x08, 0x9e, 0xce, 0x38, 0x86, 0xfe, 0x22, 0xcb, 0xe5, 0xce, 0x2c, 0xe4, 0xda, 0xf6, 0xe4,
-	0xd2, 0xfc, 0x99, 0x67, 0x73, 0x16, 0xe2, 0xb3, 0x14, 0x2a, 0x13, 0xf5, 0xc3, 0x1b, 0x6d, 0xcb,
-	0x7c, 0x5a, 0xad, 0x72, 0x51, 0x14, 0xa2, 0xa0, 0x02, 0x7a, 0xfc, 0x64, 0x8c, 0xbe, 0xa0, 0x7b,
-	0xe4, 0xc7, 0x1b, 0x68, 0xc7, 0xc9, 0xb6, 0xac, 0x50, 0x19, 0xaf, 0x04, 0xf6, 0x81, 0xcd, 0x08,
-	0x72, 0xc0, 0xd9, 0x0c, 0x43, 0xb8, 0xae, 0x92, 0xe7, 0x65, 0xfa, 0x2a, 0x0e, 0x16, 0xc8, 0x21,
-	0xa0, 0x4b, 0xfb, 0x3c, 0xd7, 0xbd, 0xc8, 0x4d, 0x3d, 0xfa, 0x59, 0x1e, 0x7e, 0x03, 0x00, 0x00,
-	0xff, 0xff, 0x64, 0x1d, 0x38, 0xac, 0x38, 0x02, 0x00, 0x00,
+	// 325 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x75, 0x52, 0x4d, 0x4f, 0xc2, 0x40,
+	0x10, 0x4d, 0xe9, 0x5a, 0xca, 0x08, 0x91, 0xcc, 0xc1, 0x34, 0xc4, 0x43, 0xe5, 0xc4, 0xa9, 0x07,
+	0x8c, 0x3f, 0x40, 0xbd, 0x90, 0x68, 0x08, 0xd9, 0x12, 0xee, 0xfd, 0xd8, 0xc0, 0x46, 0x6c, 0x6b,
+	0xbb, 0x4d, 0x4a, 0xe2, 0x9f, 0xf1, 0xec, 0x9f, 0xb4, 0x3b, 0x94, 0x22, 0x4d, 0xbc, 0xcd, 0x7b,
+	0xf3, 0x66, 0xdf, 0xdb, 0xd9, 0x05, 0x5b, 0x55, 0x5e, 0x96, 0xa7, 0x2a, 0x45, 0x2b, 0x8c, 0x76,
+	0x81, 0x4c, 0xa6, 0xdf, 0x0c, 0xc6, 0x2b, 0xcd, 0xac, 0xf3, 0x20, 0x29, 0x82, 0x48, 0xc9, 0x34,
+	0x41, 0x04, 0xb6, 0xae, 0x64, 0xec, 0x18, 0xae, 0x31, 0x1b, 0x72, 0xaa, 0x71, 0x0c, 0xe6, 0x42,
+	0x54, 0x4e, 0x8f, 0x28, 0x5d, 0xe2, 0x1d, 0x0c, 0x9e, 0xf7, 0x69, 0xf4, 0xae, 0xe4, 0x87, 0x70,
+	0xcc, 0x9a, 0x67, 0xfc, 0x4c, 0xe0, 0x04, 0xec, 0xb7, 0x53, 0x93, 0xd5, 0xcd, 0x11, 0x6f, 0x31,
+	0xde, 0x82, 0xb5, 0x10, 0x72, 0xbb, 0x53, 0xce, 0x15, 0x75, 0x1a, 0x84, 0x73, 0x30, 0x37, 0x32,
+	0x71, 0x2c, 0xd7, 0x9c, 0x5d, 0xcf, 0x5d, 0xef, 0x18, 0xd1, 0xeb, 0xc6, 0xf3, 0x6a, 0xcd, 0xfa,
+	0x90, 0x09, 0xae, 0xc5, 0xf8, 0x08, 0x6c, 0x93, 0x96, 0xca, 0xe9, 0xd3, 0xd0, 0xfd, 0xff, 0x43,
+	0xb5, 0x88, 0xa6, 0x48, 0x3e, 0xf9, 0x31, 0xa0, 0xdf, 0x9c, 0xa3, 0xa3, 0xbe, 0xa4, 0x32, 0x09,
+	0x83, 0x42, 0xd0, 0x95, 0x07, 0xbc, 0xc5, 0xed, 0x2a, 0x7a, 0x7f, 0x56, 0x81, 0x8d, 0xa5, 0x49,
+	0xe1, 0xa9, 0xc6, 0x29, 0x0c, 0xfd, 0x28, 0x97, 0x99, 0xf2, 0xe5, 0x56, 0xef, 0x89, 0x91, 0xfe,
+	0x82, 0xd3, 0x3e, 0xbe, 0xf8, 0x2c, 0x45, 0x12, 0x89, 0xe6, 0xe2, 0x2d, 0xd6, 0xcb, 0x7c, 0x8a,
+	0xe3, 0x5c, 0x14, 0x85, 0x28, 0x68, 0x01, 0x03, 0x7e, 0x26, 0x26, 0x5f, 0x60, 0x9f, 0xf2, 0xeb,
+	0x53, 0x36, 0xc1, 0xbe, 0x14, 0x7e, 0xa0, 0x9a, 0x07, 0x6a, 0x31, 0x0e, 0xc1, 0x58, 0x52, 0xd4,
+	0x11, 0x37, 0x96, 0x38, 0x83, 0x9b, 0xa3, 0xff, 0xaa, 0x0c, 0x5f, 0xc5, 0x41, 0xc7, 0x32, 0x69,
+	0xa0, 0x4b, 0x5f, 0xba, 0xb3, 0x8e, 0x7b, 0x68, 0xd1, 0x97, 0x79, 0xf8, 0x05, 0x95, 0x9e, 0x00,
+	0x7a, 0x3e, 0x02, 0x00, 0x00,
 }
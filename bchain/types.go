@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 )
 
 // errors with specific meaning returned by blockchain rpc
@@ -48,8 +49,11 @@ type Address interface {
 	InSlice(addrs []string) bool
 }
 
+// Vout contains the output value as exact integer amount in the smallest unit of the coin (ValueSat)
+// JsonValue is used only to unmarshal the value from the backend json, it is converted to ValueSat by the parser
 type Vout struct {
-	Value        float64      `json:"value"`
+	ValueSat     big.Int
+	JsonValue    json.Number  `json:"value"`
 	N            uint32       `json:"n"`
 	ScriptPubKey ScriptPubKey `json:"scriptPubKey"`
 	Address      Address
@@ -68,6 +72,59 @@ type Tx struct {
 	Confirmations uint32 `json:"confirmations,omitempty"`
 	Time          int64  `json:"time,omitempty"`
 	Blocktime     int64  `json:"blocktime,omitempty"`
+	// CoinSpecificData holds data of the transaction which are specific to the coin, for example eth receipt
+	CoinSpecificData interface{} `json:"-"`
+}
+
+// Erc20Transfer is a transfer of ERC20 tokens of the Contract from the address From to the address To
+type Erc20Transfer struct {
+	Contract string
+	From     string
+	To       string
+	Tokens   big.Int
+}
+
+// ContractLog is an event log emitted by the Contract during the execution of a transaction
+type ContractLog struct {
+	Contract string
+	Topics   []string
+	Data     string
+}
+
+// TxStatus is the result of the execution of a transaction on chains with smart contracts
+type TxStatus int
+
+const (
+	// TxStatusUnknown is the status of transactions without status in the receipt (before Byzantium fork) and of mempool transactions
+	TxStatusUnknown = TxStatus(iota - 1)
+	// TxStatusFailure is the status of a failed transaction
+	TxStatusFailure
+	// TxStatusOK is the status of a successful transaction
+	TxStatusOK
+)
+
+// TxReceipt contains the result of the execution of a transaction on chains with smart contracts
+type TxReceipt struct {
+	Status            TxStatus
+	GasLimit          big.Int
+	GasPrice          big.Int
+	GasUsed           big.Int
+	CumulativeGasUsed big.Int
+	// ContractAddress is the address of the contract created by the transaction, empty for other transactions
+	ContractAddress string
+}
+
+// AccountState is the state of an account on account based chains
+type AccountState struct {
+	Balance big.Int
+	Nonce   uint64
+}
+
+// InternalTransfer is a transfer of Value made by a contract during the execution of a transaction (an internal transaction)
+type InternalTransfer struct {
+	From  string
+	To    string
+	Value big.Int
 }
 
 type Block struct {
@@ -86,6 +143,8 @@ type BlockHeader struct {
 	Next          string `json:"nextblockhash"`
 	Height        uint32 `json:"height"`
 	Confirmations int    `json:"confirmations"`
+	Size          int    `json:"size"`
+	Time          int64  `json:"time,omitempty"`
 }
 
 type MempoolEntry struct {
@@ -103,6 +162,32 @@ type MempoolEntry struct {
 	Depends         []string `json:"depends"`
 }
 
+// FeeLevels are the estimated fees per unit of size (gas price for Ethereum) in the smallest units of the coin
+// for the confirmation within Blocks blocks, from the cheapest to the fastest
+type FeeLevels struct {
+	Blocks   int
+	Slow     big.Int
+	Standard big.Int
+	Fast     big.Int
+}
+
+// MempoolFeeRateBucket contains the mempool transactions with the fee rate (in satoshi per vbyte)
+// from FeeRate up to the FeeRate of the next bucket
+type MempoolFeeRateBucket struct {
+	FeeRate int64
+	Txs     int
+	Vsize   int64
+}
+
+// MempoolStats are the statistics of the mempool
+// Vsize, TotalFee and FeeRateHistogram include only the transactions with a known mempool entry
+type MempoolStats struct {
+	Txs              int
+	Vsize            int64
+	TotalFee         big.Int
+	FeeRateHistogram []MempoolFeeRateBucket
+}
+
 type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -129,15 +214,30 @@ type BlockChain interface {
 	GetBlockHash(height uint32) (string, error)
 	GetBlockHeader(hash string) (*BlockHeader, error)
 	GetBlock(hash string, height uint32) (*Block, error)
+	// GetBlockTxids returns the txids of the transactions of the block without getting the transactions
+	GetBlockTxids(hash string) ([]string, error)
 	GetMempool() ([]string, error)
 	GetTransaction(txid string) (*Tx, error)
 	GetTransactionForMempool(txid string) (*Tx, error)
 	EstimateSmartFee(blocks int, conservative bool) (float64, error)
 	EstimateFee(blocks int) (float64, error)
+	EstimateFeeLevels(blocks int) (*FeeLevels, error)
 	SendRawTransaction(tx string) (string, error)
 	// mempool
-	ResyncMempool(onNewTxAddr func(txid string, addr string)) (int, error)
+	ResyncMempool(onNewTxAddr func(txid string, addr string), onReplacedTx func(txid string, replacedBy string)) (int, error)
 	GetMempoolTransactions(address string) ([]string, error)
+	GetMempoolSpendingTx(txid string, vout uint32) (string, int, error)
+	GetMempoolStats() (*MempoolStats, error)
+	// GetMempoolReplacedBy returns the transaction which replaced the mempool transaction, empty string if not known
+	GetMempoolReplacedBy(txid string) (string, error)
+	// PackMempoolState returns the mempool state to be persisted across restarts, nil if the state is not persisted
+	PackMempoolState() ([]byte, error)
+	// UnpackMempoolState restores the persisted mempool state, it must be called before the first ResyncMempool
+	UnpackMempoolState(buf []byte) error
+	// tokens
+	GetErc20ContractBalance(address, contract string) (*big.Int, error)
+	// account state, negative height means the tip of the chain
+	GetAccountState(address string, height int64) (*AccountState, error)
 	GetMempoolEntry(txid string) (*MempoolEntry, error)
 	// parser
 	GetChainParser() BlockChainParser
@@ -167,8 +267,20 @@ type BlockChainParser interface {
 	ParseTxFromJson(json.RawMessage) (*Tx, error)
 	PackTx(tx *Tx, height uint32, blockTime int64) ([]byte, error)
 	UnpackTx(buf []byte) (*Tx, uint32, error)
+	GetErc20TransfersFromTx(tx *Tx) ([]Erc20Transfer, error)
+	GetInternalTransfersFromTx(tx *Tx) ([]InternalTransfer, error)
+	GetTxReceipt(tx *Tx) (*TxReceipt, error)
+	GetTxFeeRate(tx *Tx) (*big.Int, error)
+	// contract logs
+	IndexContractLogs() bool
+	GetContractLogsFromTx(tx *Tx) ([]ContractLog, error)
+	// amounts
+	AmountToBigInt(n json.Number) (big.Int, error)
+	AmountToDecimalString(a *big.Int) string
 	// blocks
 	PackBlockHash(hash string) ([]byte, error)
 	UnpackBlockHash(buf []byte) (string, error)
 	ParseBlock(b []byte) (*Block, error)
+	// xpub
+	DeriveAddrIDsFromXpub(xpub string, change uint32, fromIndex uint32, toIndex uint32) ([][]byte, error)
 }
@@ -14,6 +14,7 @@ import (
 
 	"github.com/juju/errors"
 
+	"blockbook/api"
 	"blockbook/bchain"
 	"blockbook/bchain/coins"
 	"blockbook/common"
@@ -46,8 +47,15 @@ var (
 
 	queryAddress = flag.String("address", "", "query contents of this address")
 
+	exportAddresses = flag.String("export", "", "export history of comma separated addresses to stdout and exit")
+	exportFormat    = flag.String("exportformat", "csv", "format of the export, csv or jsonl")
+	exportFrom      = flag.Uint("exportfrom", 0, "height of the first block of the export")
+	exportUntil     = flag.Uint("exportuntil", 0, "height of the last block of the export (default the best block)")
+	exportMaxTxs    = flag.Int("exportmaxtxs", 0, "maximal number of exported transactions (default unlimited)")
+
 	synchronize = flag.Bool("sync", false, "synchronizes until tip, if together with zeromq, keeps index synchronized")
 	repair      = flag.Bool("repair", false, "repair the database")
+	migrate     = flag.Bool("migrate", false, "migrate the database to the current version and exit")
 	prof        = flag.String("prof", "", "http server binding [address]:port of the interface to profiling data /debug/pprof/ (default no profiling)")
 
 	syncChunk   = flag.Int("chunk", 100, "block chunk size for processing")
@@ -87,6 +95,7 @@ var (
 	internalState              *common.InternalState
 	callbacksOnNewBlockHash    []func(hash string)
 	callbacksOnNewTxAddr       []func(txid string, addr string)
+	callbacksOnReplacedTx      []func(txid string, replacedBy string)
 	chanOsSignal               chan os.Signal
 	inShutdown                 int32
 )
@@ -178,6 +187,17 @@ func main() {
 		glog.Warning("internalState: database in not closed state ", internalState.DbState, ", possibly previous ungraceful shutdown")
 	}
 
+	if *migrate {
+		if err = index.Migrate(chain, chanOsSignal); err != nil {
+			glog.Error("migrate: ", err)
+		}
+		return
+	}
+	if index.MigrationNeeded() {
+		glog.Error("internalState: database version ", internalState.DbVersion, " is not current, run blockbook with -migrate to upgrade it")
+		return
+	}
+
 	if *computeColumnStats {
 		internalState.DbState = common.DbStateOpen
 		err = index.ComputeInternalStateColumnStats(chanOsSignal)
@@ -232,6 +252,19 @@ func main() {
 		return
 	}
 
+	if *exportAddresses != "" {
+		w, err := api.NewWorker(index, chain, txCache, internalState)
+		if err != nil {
+			glog.Error("export: ", err)
+			return
+		}
+		opts := &api.ExportOptions{FromHeight: uint32(*exportFrom), ToHeight: uint32(*exportUntil), MaxTxs: *exportMaxTxs}
+		if err = w.ExportAddressHistory(strings.Split(*exportAddresses, ","), opts, *exportFormat, os.Stdout); err != nil {
+			glog.Error("export: ", err)
+		}
+		return
+	}
+
 	var internalServer *server.InternalServer
 	if *internalBinding != "" {
 		internalServer, err = server.NewInternalServer(*internalBinding, *certFiles, index, chain, txCache, internalState)
@@ -257,7 +290,8 @@ func main() {
 			glog.Error("resyncIndex ", err)
 			return
 		}
-		if _, err = chain.ResyncMempool(nil); err != nil {
+		loadMempoolState()
+		if _, err = chain.ResyncMempool(nil, nil); err != nil {
 			glog.Error("resyncMempool ", err)
 			return
 		}
@@ -283,6 +317,7 @@ func main() {
 		}()
 		callbacksOnNewBlockHash = append(callbacksOnNewBlockHash, publicServer.OnNewBlockHash)
 		callbacksOnNewTxAddr = append(callbacksOnNewTxAddr, publicServer.OnNewTxAddr)
+		callbacksOnReplacedTx = append(callbacksOnReplacedTx, publicServer.OnReplacedTx)
 	}
 
 	if *synchronize {
@@ -404,7 +439,7 @@ func syncMempoolLoop() {
 	// resync mempool about every minute if there are no chanSyncMempool requests, with debounce 1 second
 	tickAndDebounce(time.Duration(*resyncMempoolPeriodMs)*time.Millisecond, debounceResyncMempoolMs*time.Millisecond, chanSyncMempool, func() {
 		internalState.StartedMempoolSync()
-		if count, err := chain.ResyncMempool(onNewTxAddr); err != nil {
+		if count, err := chain.ResyncMempool(onNewTxAddr, onReplacedTx); err != nil {
 			glog.Error("syncMempoolLoop ", errors.ErrorStack(err))
 		} else {
 			internalState.FinishedMempoolSync(count)
@@ -412,6 +447,42 @@ func syncMempoolLoop() {
 		}
 	})
 	glog.Info("syncMempoolLoop stopped")
+	storeMempoolState()
+}
+
+// loadMempoolState restores the mempool state stored at the last shutdown
+// so that the initial mempool resync fetches only the transactions which appeared in the meantime
+func loadMempoolState() {
+	buf, err := index.LoadMempoolState()
+	if err != nil {
+		glog.Error("loadMempoolState ", err)
+		return
+	}
+	if buf == nil {
+		return
+	}
+	if err = chain.UnpackMempoolState(buf); err != nil {
+		glog.Error("loadMempoolState ", err)
+		return
+	}
+	glog.Info("loadMempoolState: restored mempool state, ", len(buf), " bytes")
+}
+
+// storeMempoolState stores the mempool state, it must not run concurrently with the mempool resync
+func storeMempoolState() {
+	buf, err := chain.PackMempoolState()
+	if err != nil {
+		glog.Error("storeMempoolState ", err)
+		return
+	}
+	if buf == nil {
+		return
+	}
+	if err = index.StoreMempoolState(buf); err != nil {
+		glog.Error("storeMempoolState ", err)
+		return
+	}
+	glog.Info("storeMempoolState: stored mempool state, ", len(buf), " bytes")
 }
 
 func storeInternalStateLoop() {
@@ -450,14 +521,21 @@ func onNewTxAddr(txid string, addr string) {
 	}
 }
 
+func onReplacedTx(txid string, replacedBy string) {
+	for _, c := range callbacksOnReplacedTx {
+		c(txid, replacedBy)
+	}
+}
+
 func pushSynchronizationHandler(nt bchain.NotificationType) {
 	if atomic.LoadInt32(&inShutdown) != 0 {
 		return
 	}
 	glog.V(1).Infof("MQ: notification ", nt)
-	if nt == bchain.NotificationNewBlock {
+	// missed notifications trigger the resync immediately, without waiting for the periodic resync
+	if nt == bchain.NotificationNewBlock || nt == bchain.NotificationMissedBlock {
 		chanSyncIndex <- struct{}{}
-	} else if nt == bchain.NotificationNewTx {
+	} else if nt == bchain.NotificationNewTx || nt == bchain.NotificationMissedTx {
 		chanSyncMempool <- struct{}{}
 	} else {
 		glog.Error("MQ: unknown notification sent")
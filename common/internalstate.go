@@ -31,7 +31,8 @@ type InternalState struct {
 	CoinShortcut string `json:"coinShortcut"`
 	Host         string `json:"host"`
 
-	DbState uint32 `json:"dbState"`
+	DbState   uint32 `json:"dbState"`
+	DbVersion uint32 `json:"dbVersion"`
 
 	LastStore time.Time `json:"lastStore"`
 
@@ -19,8 +19,11 @@ type Metrics struct {
 	IndexDBSize           prometheus.Gauge
 	ExplorerViews         *prometheus.CounterVec
 	MempoolSize           prometheus.Gauge
+	MempoolVsize          prometheus.Gauge
+	MempoolFeeRateBuckets *prometheus.GaugeVec
 	DbColumnRows          *prometheus.GaugeVec
 	DbColumnSize          *prometheus.GaugeVec
+	MQSequenceGaps        *prometheus.CounterVec
 }
 
 type Labels = prometheus.Labels
@@ -123,6 +126,21 @@ func GetMetrics(coin string) (*Metrics, error) {
 			ConstLabels: Labels{"coin": coin},
 		},
 	)
+	metrics.MempoolVsize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name:        "blockbook_mempool_vsize",
+			Help:        "Mempool size (total virtual size of transactions in bytes)",
+			ConstLabels: Labels{"coin": coin},
+		},
+	)
+	metrics.MempoolFeeRateBuckets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "blockbook_mempool_feerate_buckets",
+			Help:        "Virtual size of mempool transactions by fee rate bucket (lower bound in satoshi per vbyte)",
+			ConstLabels: Labels{"coin": coin},
+		},
+		[]string{"feerate"},
+	)
 	metrics.DbColumnRows = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name:        "blockbook_dbcolumn_rows",
@@ -139,6 +157,14 @@ func GetMetrics(coin string) (*Metrics, error) {
 		},
 		[]string{"column"},
 	)
+	metrics.MQSequenceGaps = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name:        "blockbook_mq_sequence_gaps",
+			Help:        "Number of gaps in the sequence of message queue notifications by notification",
+			ConstLabels: Labels{"coin": coin},
+		},
+		[]string{"notification"},
+	)
 
 	v := reflect.ValueOf(metrics)
 	for i := 0; i < v.NumField(); i++ {
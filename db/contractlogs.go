@@ -0,0 +1,289 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/bsm/go-vlq"
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// The event logs of contracts are indexed in the contractlogs column under the key contractID+topic0+height,
+// the value is the list of the logs as btxID, index of the transaction in the block, index of the log in the transaction,
+// number of other topics and the other topics
+// Logs without topics (anonymous events) are stored with zero topic0.
+
+// topicLen is the length of a topic of the event log
+const topicLen = 32
+
+// maxTopics is the maximum number of topics of the event log
+const maxTopics = 4
+
+// ContractLogRef is a reference to an event log of a contract found in the index
+type ContractLogRef struct {
+	Txid     string
+	Height   uint32
+	LogIndex uint32
+}
+
+type contractLogEntry struct {
+	btxID    []byte
+	txIndex  uint32
+	logIndex uint32
+	topics   [][]byte
+}
+
+func decodeTopic(topic string) ([]byte, error) {
+	if strings.HasPrefix(topic, "0x") || strings.HasPrefix(topic, "0X") {
+		topic = topic[2:]
+	}
+	b, err := hex.DecodeString(topic)
+	if err != nil || len(b) != topicLen {
+		return nil, errors.Errorf("Invalid topic %v", topic)
+	}
+	return b, nil
+}
+
+// addContractLogsToRecords adds the event logs of the transaction to the records of the contractlogs column
+func (d *RocksDB) addContractLogsToRecords(logs map[string][]contractLogEntry, tx *bchain.Tx, btxID []byte, txIndex int, height uint32) error {
+	txLogs, err := d.chainParser.GetContractLogsFromTx(tx)
+	if err != nil {
+		return err
+	}
+	for i := range txLogs {
+		l := &txLogs[i]
+		contractID, err := d.chainParser.GetAddrIDFromAddress(l.Contract)
+		if err != nil {
+			glog.Warningf("rocksdb: contract addrID: %v - height %d, tx %v, contract %v", err, height, tx.Txid, l.Contract)
+			continue
+		}
+		if len(l.Topics) > maxTopics {
+			glog.Warningf("rocksdb: height %d, tx %v, log %d has %d topics", height, tx.Txid, i, len(l.Topics))
+			continue
+		}
+		topics := make([][]byte, len(l.Topics))
+		for j, t := range l.Topics {
+			if topics[j], err = decodeTopic(t); err != nil {
+				return errors.Annotatef(err, "height %d, tx %v, log %d", height, tx.Txid, i)
+			}
+		}
+		topic0 := make([]byte, topicLen)
+		if len(topics) > 0 {
+			topic0 = topics[0]
+			topics = topics[1:]
+		}
+		key := string(contractID) + string(topic0)
+		logs[key] = append(logs[key], contractLogEntry{btxID: btxID, txIndex: uint32(txIndex), logIndex: uint32(i), topics: topics})
+	}
+	return nil
+}
+
+func (d *RocksDB) packContractLogs(entries []contractLogEntry) []byte {
+	buf := make([]byte, 0)
+	bv := make([]byte, vlq.MaxLen32)
+	for _, e := range entries {
+		buf = append(buf, e.btxID...)
+		l := packVaruint(uint(e.txIndex), bv)
+		buf = append(buf, bv[:l]...)
+		l = packVaruint(uint(e.logIndex), bv)
+		buf = append(buf, bv[:l]...)
+		buf = append(buf, byte(len(e.topics)))
+		for _, t := range e.topics {
+			buf = append(buf, t...)
+		}
+	}
+	return buf
+}
+
+func (d *RocksDB) unpackContractLogs(buf []byte) ([]contractLogEntry, error) {
+	txidLen := d.chainParser.PackedTxidLen()
+	entries := make([]contractLogEntry, 0)
+	for i := 0; i < len(buf); {
+		if i+txidLen >= len(buf) {
+			return nil, errors.New("Inconsistent data in unpackContractLogs")
+		}
+		e := contractLogEntry{btxID: append([]byte(nil), buf[i:i+txidLen]...)}
+		i += txidLen
+		txIndex, l := unpackVaruint(buf[i:])
+		e.txIndex = uint32(txIndex)
+		i += l
+		if i >= len(buf) {
+			return nil, errors.New("Inconsistent data in unpackContractLogs")
+		}
+		logIndex, l := unpackVaruint(buf[i:])
+		e.logIndex = uint32(logIndex)
+		i += l
+		if i >= len(buf) {
+			return nil, errors.New("Inconsistent data in unpackContractLogs")
+		}
+		n := int(buf[i])
+		i++
+		if i+n*topicLen > len(buf) {
+			return nil, errors.New("Inconsistent data in unpackContractLogs")
+		}
+		e.topics = make([][]byte, n)
+		for j := 0; j < n; j++ {
+			e.topics[j] = append([]byte(nil), buf[i:i+topicLen]...)
+			i += topicLen
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// writeContractLogRecords stores or deletes the records of the contractlogs column of the block
+func (d *RocksDB) writeContractLogRecords(wb *gorocksdb.WriteBatch, height uint32, op int, logs map[string][]contractLogEntry) {
+	for id, entries := range logs {
+		key := packAddressKey([]byte(id), height)
+		switch op {
+		case opInsert:
+			wb.PutCF(d.cfh[cfContractLogs], key, d.packContractLogs(entries))
+		case opDelete:
+			wb.DeleteCF(d.cfh[cfContractLogs], key)
+		}
+	}
+}
+
+// contractLogGroup is the sequence of the keys of the contractlogs column with the same contract and topic0,
+// height is the height of the current key of the group
+type contractLogGroup struct {
+	prefix []byte
+	height uint32
+}
+
+// GetContractLogs finds the event logs of the contract in blocks lower-higher, which match the topics
+// topics are hex encoded, empty topic matches any value, at most maxTopics topics can be specified
+// the logs are returned ordered by the height of the block and by the position in the block,
+// the first skip matching logs are skipped and at most count logs are returned, count 0 means no limit
+func (d *RocksDB) GetContractLogs(contract string, topics []string, lower uint32, higher uint32, skip int, count int) ([]ContractLogRef, error) {
+	if len(topics) > maxTopics {
+		return nil, errors.Errorf("At most %d topics can be specified", maxTopics)
+	}
+	contractID, err := d.chainParser.GetAddrIDFromAddress(contract)
+	if err != nil {
+		return nil, err
+	}
+	filter := make([][]byte, len(topics))
+	for i, t := range topics {
+		if t != "" {
+			if filter[i], err = decodeTopic(t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	keyLen := len(contractID) + topicLen + packedHeightBytes
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfContractLogs])
+	defer it.Close()
+	var groups []*contractLogGroup
+	if len(filter) > 0 && filter[0] != nil {
+		groups = append(groups, &contractLogGroup{prefix: append(append([]byte(nil), contractID...), filter[0]...)})
+	} else {
+		// without topic0 the logs are in multiple groups, find them by skipping over the keys of each group
+		for it.Seek(contractID); it.Valid(); {
+			key := it.Key().Data()
+			if !bytes.HasPrefix(key, contractID) {
+				break
+			}
+			if len(key) != keyLen {
+				it.Next()
+				continue
+			}
+			g := &contractLogGroup{prefix: append([]byte(nil), key[:len(contractID)+topicLen]...)}
+			groups = append(groups, g)
+			last := packAddressKey(g.prefix, ^uint32(0))
+			it.Seek(last)
+			if it.Valid() && bytes.Equal(it.Key().Data(), last) {
+				it.Next()
+			}
+		}
+	}
+	// load sets the height of the group from the current key, returns false if the group has no more keys in the range
+	load := func(g *contractLogGroup) bool {
+		if !it.Valid() {
+			return false
+		}
+		key := it.Key().Data()
+		if len(key) != keyLen || !bytes.HasPrefix(key, g.prefix) {
+			return false
+		}
+		g.height = unpackUint(key[len(key)-packedHeightBytes:])
+		return g.height <= higher
+	}
+	active := make([]*contractLogGroup, 0, len(groups))
+	for _, g := range groups {
+		it.Seek(packAddressKey(g.prefix, lower))
+		if load(g) {
+			active = append(active, g)
+		}
+	}
+	type logRef struct {
+		btxID    []byte
+		txIndex  uint32
+		logIndex uint32
+	}
+	r := make([]ContractLogRef, 0)
+	// merge the groups by height, only the logs of one block are held in memory
+	for len(active) > 0 {
+		height := active[0].height
+		for _, g := range active[1:] {
+			if g.height < height {
+				height = g.height
+			}
+		}
+		var refs []logRef
+		next := active[:0]
+		for _, g := range active {
+			if g.height == height {
+				it.Seek(packAddressKey(g.prefix, height))
+				entries, err := d.unpackContractLogs(it.Value().Data())
+				if err != nil {
+					return nil, err
+				}
+			entriesLoop:
+				for _, e := range entries {
+					for i := 1; i < len(filter); i++ {
+						if filter[i] == nil {
+							continue
+						}
+						if i > len(e.topics) || !bytes.Equal(e.topics[i-1], filter[i]) {
+							continue entriesLoop
+						}
+					}
+					refs = append(refs, logRef{btxID: e.btxID, txIndex: e.txIndex, logIndex: e.logIndex})
+				}
+				it.Next()
+				if !load(g) {
+					continue
+				}
+			}
+			next = append(next, g)
+		}
+		active = next
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].txIndex != refs[j].txIndex {
+				return refs[i].txIndex < refs[j].txIndex
+			}
+			return refs[i].logIndex < refs[j].logIndex
+		})
+		for i := range refs {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			txid, err := d.chainParser.UnpackTxid(refs[i].btxID)
+			if err != nil {
+				return nil, err
+			}
+			r = append(r, ContractLogRef{Txid: txid, Height: height, LogIndex: refs[i].logIndex})
+			if count > 0 && len(r) >= count {
+				return r, nil
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"encoding/hex"
+
+	"github.com/golang/glog"
+	"github.com/tecbot/gorocksdb"
+)
+
+// ERC20 token transfers are indexed in two places:
+// - in the addresses column, the sender and the receiver of the j-th transfer of a transaction are stored
+//   as input ^(j+1) and output j+1 (index 0 is used by the transaction itself)
+// - in the erc20transfers column under the key addrID+contractID+height, with the same outpoints as in the addresses column
+
+// Erc20Contract is a contract, with which an address has ERC20 token transfers
+type Erc20Contract struct {
+	Contract string
+	Txs      int
+}
+
+// erc20TransferIndex returns the index of the j-th transfer of a transaction in the addresses column
+func erc20TransferIndex(j int, isOutput bool) int32 {
+	if isOutput {
+		return int32(j + 1)
+	}
+	return ^int32(j + 1)
+}
+
+// addErc20TransfersToRecords adds the senders and receivers of the ERC20 transfers of the transaction to the records
+// of the addresses and erc20transfers columns
+func (d *RocksDB) addErc20TransfersToRecords(op int, wb *gorocksdb.WriteBatch, addresses map[string][]outpoint, erc20 map[string][]outpoint,
+	tx *bchain.Tx, btxID []byte, height uint32) error {
+	transfers, err := d.chainParser.GetErc20TransfersFromTx(tx)
+	if err != nil {
+		return err
+	}
+	for j := range transfers {
+		t := &transfers[j]
+		contractID, err := d.chainParser.GetAddrIDFromAddress(t.Contract)
+		if err != nil {
+			glog.Warningf("rocksdb: contract addrID: %v - height %d, tx %v, contract %v", err, height, tx.Txid, t.Contract)
+			continue
+		}
+		for _, a := range []struct {
+			address  string
+			isOutput bool
+		}{{t.From, false}, {t.To, true}} {
+			addrID, err := d.chainParser.GetAddrIDFromAddress(a.address)
+			if err != nil {
+				glog.Warningf("rocksdb: addrID: %v - height %d, tx %v, address %v", err, height, tx.Txid, a.address)
+				continue
+			}
+			index := erc20TransferIndex(j, a.isOutput)
+			if err = d.addAddrIDToRecords(op, wb, addresses, addrID, btxID, index, height); err != nil {
+				return err
+			}
+			key := string(addrID) + string(contractID)
+			erc20[key] = append(erc20[key], outpoint{btxID: btxID, vout: index})
+		}
+	}
+	return nil
+}
+
+// writeErc20Records stores or deletes the records of the erc20transfers column of the block
+func (d *RocksDB) writeErc20Records(wb *gorocksdb.WriteBatch, height uint32, op int, erc20 map[string][]outpoint) {
+	for id, outpoints := range erc20 {
+		key := packAddressKey([]byte(id), height)
+		switch op {
+		case opInsert:
+			wb.PutCF(d.cfh[cfErc20Transfers], key, d.packOutpoints(outpoints))
+		case opDelete:
+			wb.DeleteCF(d.cfh[cfErc20Transfers], key)
+		}
+	}
+}
+
+// GetAddrIDErc20Transactions finds transactions with ERC20 transfers of the contract from or to the address addrID
+// Transactions are passed to callback function together with the height of the block and the index of the transfer.
+func (d *RocksDB) GetAddrIDErc20Transactions(addrID []byte, contractID []byte, lower uint32, higher uint32, fn func(txid string, height uint32, vout uint32, isOutput bool) error) error {
+	id := make([]byte, 0, len(addrID)+len(contractID))
+	id = append(id, addrID...)
+	id = append(id, contractID...)
+	return d.iterateOutpoints(cfErc20Transfers, id, lower, higher, fn)
+}
+
+// GetAddrIDErc20Contracts returns the contracts, with which the address addrID has ERC20 token transfers,
+// together with the number of transactions with the transfers
+func (d *RocksDB) GetAddrIDErc20Contracts(addrID []byte) ([]Erc20Contract, error) {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfErc20Transfers])
+	defer it.Close()
+	var contracts []Erc20Contract
+	var last []byte
+	for it.Seek(addrID); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if !bytes.HasPrefix(key, addrID) || len(key) <= len(addrID)+packedHeightBytes {
+			break
+		}
+		contractID := key[len(addrID) : len(key)-packedHeightBytes]
+		outpoints, err := d.unpackOutpoints(it.Value().Data())
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(contractID, last) {
+			// the addrID of ethereum address is the address without the 0x prefix
+			contracts = append(contracts, Erc20Contract{Contract: "0x" + hex.EncodeToString(contractID)})
+			last = append([]byte(nil), contractID...)
+		}
+		contracts[len(contracts)-1].Txs += countTxs(outpoints)
+	}
+	return contracts, nil
+}
@@ -0,0 +1,1004 @@
+package db
+
+import (
+	"blockbook/bchain"
+	"bytes"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// migrateBatchSize is the number of changed records written to db in one batch
+const migrateBatchSize = 10000
+
+// migrateProgressPeriod is the period of logging of the migration progress
+const migrateProgressPeriod = 10 * time.Second
+
+// migration transforms the db from version fromVersion to version fromVersion+1
+// migrate must transform the data in place and must be able to continue after an interruption
+type migration struct {
+	fromVersion uint32
+	description string
+	migrate     func(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error
+}
+
+// migrations is the registry of migrations, ordered by fromVersion
+var migrations = []migration{
+	{
+		fromVersion: 0,
+		description: "remove cached transactions, create the addressbalance column, add the values of outputs to the unspenttxs and blockaddresses columns",
+		migrate:     migrateAddrBalances,
+	},
+	{
+		fromVersion: 1,
+		description: "create the undo data of the blocks in the blockaddresses column",
+		migrate:     migrateBlockUndo,
+	},
+	{
+		fromVersion: 2,
+		description: "store block time, number of transactions and size in the height column",
+		migrate:     migrateBlockInfo,
+	},
+	{
+		fromVersion: 3,
+		description: "index the inputs spending the outputs of transactions in the spendingtxs column",
+		migrate:     migrateSpendingTxs,
+	},
+	{
+		fromVersion: 4,
+		description: "index ERC20 token transfers in the addresses and erc20transfers columns",
+		migrate:     migrateErc20Transfers,
+	},
+	{
+		fromVersion: 5,
+		description: "index the contracts created by transactions and remove cached transactions without receipt data",
+		migrate:     migrateContractCreations,
+	},
+	{
+		fromVersion: 6,
+		description: "create the contractlogs column and index the event logs of contracts if enabled",
+		migrate:     migrateContractLogs,
+	},
+}
+
+// canMigrate returns true if there is a chain of migrations from the given version to dbVersion
+func canMigrate(version uint32) bool {
+	for _, m := range migrations {
+		if m.fromVersion == version {
+			version++
+		}
+	}
+	return version == dbVersion
+}
+
+// MigrationNeeded returns true if the db must be migrated before it can be used
+func (d *RocksDB) MigrationNeeded() bool {
+	return d.is != nil && d.is.DbVersion != dbVersion
+}
+
+// Migrate runs the migrations necessary to transform the db to dbVersion
+// the internal state is stored after each migration so that an interrupted migration continues from the last finished step
+func (d *RocksDB) Migrate(chain bchain.BlockChain, stop chan os.Signal) error {
+	if d.is == nil {
+		return errors.New("Internal state not set")
+	}
+	if d.is.DbVersion == dbVersion {
+		glog.Info("rocksdb: db is at version ", dbVersion, ", no migration necessary")
+		return nil
+	}
+	for _, m := range migrations {
+		if m.fromVersion != d.is.DbVersion {
+			continue
+		}
+		glog.Info("rocksdb: migration from version ", m.fromVersion, " to ", m.fromVersion+1, ": ", m.description)
+		start := time.Now()
+		if err := m.migrate(d, chain, stop); err != nil {
+			return errors.Annotatef(err, "migration from version %v", m.fromVersion)
+		}
+		d.is.DbVersion = m.fromVersion + 1
+		for i := range d.is.DbColumns {
+			d.is.DbColumns[i].Version = d.is.DbVersion
+		}
+		if err := d.StoreInternalState(d.is); err != nil {
+			return err
+		}
+		glog.Info("rocksdb: migration to version ", d.is.DbVersion, " finished in ", time.Since(start))
+	}
+	if d.is.DbVersion != dbVersion {
+		return errors.Errorf("Missing migration from version %v", d.is.DbVersion)
+	}
+	return nil
+}
+
+// migrationProgress logs progress of the migration of a column
+type migrationProgress struct {
+	column  string
+	total   int64
+	done    int64
+	changed int64
+	start   time.Time
+	lastLog time.Time
+}
+
+func newMigrationProgress(column string, total int64) *migrationProgress {
+	now := time.Now()
+	return &migrationProgress{column: column, total: total, start: now, lastLog: now}
+}
+
+func (p *migrationProgress) log(final bool) {
+	now := time.Now()
+	if !final && now.Sub(p.lastLog) < migrateProgressPeriod {
+		return
+	}
+	p.lastLog = now
+	if final {
+		glog.Infof("rocksdb: migrated column %v, %d records processed, %d changed in %v", p.column, p.done, p.changed, now.Sub(p.start))
+	} else if p.total > 0 && p.done <= p.total {
+		eta := time.Duration(float64(now.Sub(p.start)) / float64(p.done) * float64(p.total-p.done))
+		glog.Infof("rocksdb: migrating column %v, %d of %d records (%.1f%%), %d changed, remaining time %v", p.column, p.done, p.total, float64(p.done)*100/float64(p.total), p.changed, eta.Round(time.Second))
+	} else {
+		glog.Infof("rocksdb: migrating column %v, %d records, %d changed", p.column, p.done, p.changed)
+	}
+}
+
+// transformColumn rewrites the records of the column cf in place using the function transform
+// transform returns nil value if the record is to be kept unchanged
+// if the returned key differs from the original key, the original record is deleted
+func (d *RocksDB) transformColumn(cf int, transform func(key, val []byte) ([]byte, []byte, error), stop chan os.Signal) error {
+	// the iterator reads a snapshot of the db, the written records are not visited again
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cf])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows, _, _ := d.is.GetDBColumnStatValues(cf)
+	p := newMigrationProgress(cfNames[cf], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cf])
+		default:
+		}
+		key := it.Key().Data()
+		newKey, newVal, err := transform(key, it.Value().Data())
+		if err != nil {
+			return errors.Annotatef(err, "key %x", key)
+		}
+		p.done++
+		if newVal != nil {
+			if !bytes.Equal(key, newKey) {
+				wb.DeleteCF(d.cfh[cf], append([]byte{}, key...))
+			}
+			wb.PutCF(d.cfh[cf], newKey, newVal)
+			p.changed++
+			if wb.Count() >= migrateBatchSize {
+				if err := d.db.Write(d.wo, wb); err != nil {
+					return err
+				}
+				wb.Clear()
+			}
+		}
+		p.log(false)
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateAddrBalancesHeightKey is the key in the default column of the height of the next block processed by migrateAddrBalances
+// the key is present only while the migration of the amounts is in progress
+const migrateAddrBalancesHeightKey = "migrateAddrBalancesHeight"
+
+// migrateAddrBalances removes the cached transactions, which store the values of outputs as floating point numbers,
+// and creates the addressbalance column, the number of transactions of each address is counted from the addresses column
+// the amounts of addresses of UTXO chains are computed from the blocks taken from the backend, the outputs still in the unspenttxs column
+// are the balance, the other outputs were sent; the values of the outputs are added to the records of the unspenttxs and blockaddresses columns
+// the height of the next block is stored with the processed data so that an interrupted migration continues from the last written block
+func migrateAddrBalances(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(migrateAddrBalancesHeightKey))
+	if err != nil {
+		return err
+	}
+	var from uint32
+	inProgress := val.Size() == packedHeightBytes
+	if inProgress {
+		from = unpackUint(val.Data())
+	}
+	val.Free()
+	if !inProgress {
+		if err := d.clearColumn(cfTransactions, stop); err != nil {
+			return err
+		}
+		if err := d.migrateAddrTxs(stop); err != nil {
+			return err
+		}
+		if !d.chainParser.IsUTXOChain() {
+			return nil
+		}
+		if err := d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(migrateAddrBalancesHeightKey), packUint(0)); err != nil {
+			return err
+		}
+	}
+	if err := d.migrateUTXOAmounts(chain, from, stop); err != nil {
+		return err
+	}
+	return d.db.DeleteCF(d.wo, d.cfh[cfDefault], []byte(migrateAddrBalancesHeightKey))
+}
+
+// clearColumn deletes all records of the column cf
+func (d *RocksDB) clearColumn(cf int, stop chan os.Signal) error {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cf])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows, _, _ := d.is.GetDBColumnStatValues(cf)
+	p := newMigrationProgress(cfNames[cf], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cf])
+		default:
+		}
+		wb.DeleteCF(d.cfh[cf], append([]byte(nil), it.Key().Data()...))
+		p.done++
+		p.changed++
+		if wb.Count() >= migrateBatchSize {
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			wb.Clear()
+		}
+		p.log(false)
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateAddrTxs writes the number of transactions of each address in the addresses column to the addressbalance column
+// the amounts are zero, the records are overwritten therefore the migration can be repeated
+func (d *RocksDB) migrateAddrTxs(stop chan os.Signal) error {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddresses])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows, _, _ := d.is.GetDBColumnStatValues(cfAddresses)
+	p := newMigrationProgress(cfNames[cfAddressBalance], rows)
+	var addrID []byte
+	txs := 0
+	put := func() {
+		if txs > 0 {
+			wb.PutCF(d.cfh[cfAddressBalance], addrID, packAddrBalance(&AddrBalance{Txs: uint32(txs)}))
+			p.changed++
+		}
+	}
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfAddressBalance])
+		default:
+		}
+		id, _, err := unpackAddressKey(it.Key().Data())
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(id, addrID) {
+			put()
+			addrID = append([]byte(nil), id...)
+			txs = 0
+			if wb.Count() >= migrateBatchSize {
+				if err := d.db.Write(d.wo, wb); err != nil {
+					return err
+				}
+				wb.Clear()
+			}
+		}
+		outpoints, err := d.unpackOutpoints(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		txs += countTxs(outpoints)
+		p.done++
+		p.log(false)
+	}
+	put()
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateUTXOAmounts processes the blocks from the height from, see migrateAddrBalances
+func (d *RocksDB) migrateUTXOAmounts(chain bchain.BlockChain, from uint32, stop chan os.Signal) error {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	balances := make(map[string]*AddrBalance)
+	flush := func(next uint32) error {
+		d.writeAddrBalances(wb, balances)
+		wb.PutCF(d.cfh[cfDefault], []byte(migrateAddrBalancesHeightKey), packUint(next))
+		if err := d.db.Write(d.wo, wb); err != nil {
+			return err
+		}
+		wb.Clear()
+		balances = make(map[string]*AddrBalance)
+		return nil
+	}
+	rows, _, _ := d.is.GetDBColumnStatValues(cfHeight)
+	p := newMigrationProgress(cfNames[cfAddressBalance], rows)
+	next := from
+	for it.Seek(packUint(from)); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := flush(next); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfAddressBalance])
+		default:
+		}
+		height := unpackUint(it.Key().Data())
+		info, err := d.unpackBlockInfo(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		block, err := chain.GetBlock(info.Hash, height)
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		if err := d.migrateBlockAmounts(wb, balances, chain, block); err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		next = height + 1
+		p.done++
+		if wb.Count() >= migrateBatchSize {
+			if err := flush(next); err != nil {
+				return err
+			}
+		}
+		p.log(false)
+	}
+	if err := flush(next); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// unspentAddrV0 is an unspent output in the unspenttxs column of the db version 0, i.e. without value
+type unspentAddrV0 struct {
+	addrID []byte
+	vout   int32
+}
+
+// unpackUnspentAddrsV0 unpacks the record of the unspenttxs column in the format of the db version 0,
+// packed as lenaddrID addrID vout, returns false if the record is not in this format
+func unpackUnspentAddrsV0(buf []byte) ([]unspentAddrV0, bool) {
+	var addrs []unspentAddrV0
+	for i := 0; i < len(buf); {
+		l, lv := unpackVarint(buf[i:])
+		j := i + lv + int(l)
+		if l < 0 || j >= len(buf) {
+			return nil, false
+		}
+		vout, vl := unpackVarint(buf[j:])
+		addrs = append(addrs, unspentAddrV0{addrID: buf[i+lv : j], vout: vout})
+		i = j + vl
+	}
+	return addrs, true
+}
+
+// migrateBlockAmounts adds the amounts of the outputs of the block to the balances of the addresses,
+// converts the unspenttxs records of the transactions of the block and the blockaddresses record of the block to the current format
+func (d *RocksDB) migrateBlockAmounts(wb *gorocksdb.WriteBatch, balances map[string]*AddrBalance, chain bchain.BlockChain, block *bchain.Block) error {
+	outputs := make(map[string]*spentOutput)
+	deltas := make(map[string]*addrBalanceDelta)
+	for i := range block.Txs {
+		tx := &block.Txs[i]
+		btxID, err := d.chainParser.PackTxid(tx.Txid)
+		if err != nil {
+			return err
+		}
+		vouts := make(map[int32]*bchain.Vout)
+		addrIDs := make(map[int32][]byte)
+		for j := range tx.Vout {
+			output := &tx.Vout[j]
+			addrID, err := d.chainParser.GetAddrIDFromVout(output)
+			if err != nil || len(addrID) == 0 || len(addrID) > 1024 {
+				continue
+			}
+			vouts[int32(output.N)] = output
+			addrIDs[int32(output.N)] = addrID
+		}
+		val, err := d.getUnspentTx(btxID)
+		if err != nil {
+			return err
+		}
+		unspent := make(map[int32]struct{})
+		if len(val) > 0 {
+			addrs, ok := unpackUnspentAddrsV0(val)
+			for _, a := range addrs {
+				if vouts[a.vout] == nil || !bytes.Equal(addrIDs[a.vout], a.addrID) {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				// duplicate transaction (BIP30) already processed in an earlier block
+				glog.Warning("rocksdb: unspent outputs of tx ", tx.Txid, " are not in the format of db version 0, skipping")
+				continue
+			}
+			txAddrs := make([]byte, 0)
+			for j, a := range addrs {
+				unspent[a.vout] = struct{}{}
+				txAddrs = appendPackedAddrID(txAddrs, a.addrID, uint32(a.vout), &vouts[a.vout].ValueSat, len(addrs)-j-1)
+			}
+			wb.PutCF(d.cfh[cfUnspentTxs], btxID, txAddrs)
+		}
+		for n, output := range vouts {
+			addrID := addrIDs[n]
+			ab, err := d.getAddrBalanceForUpdate(balances, addrID)
+			if err != nil {
+				return err
+			}
+			if _, ok := unspent[n]; ok {
+				ab.BalanceSat.Add(&ab.BalanceSat, &output.ValueSat)
+			} else {
+				ab.SentSat.Add(&ab.SentSat, &output.ValueSat)
+			}
+			outputs[outpointKey(btxID, n)] = &spentOutput{btxID: btxID, vout: n, addrID: addrID, valueSat: &output.ValueSat}
+			bd := getAddrBalanceDelta(deltas, addrID)
+			bd.receivedSat.Add(&bd.receivedSat, &output.ValueSat)
+		}
+	}
+	return d.migrateBlockAddresses(wb, chain, block, outputs, deltas)
+}
+
+// unpackBlockAddressesV0 unpacks the record of the blockaddresses column in the format of the db version 0,
+// packed as lenaddrID addrID number of spent outpoints and the spent outpoints without values
+func (d *RocksDB) unpackBlockAddressesV0(buf []byte) ([][]byte, [][]outpoint, error) {
+	txidUnpackedLen := d.chainParser.PackedTxidLen()
+	addresses := make([][]byte, 0)
+	outpointsArray := make([][]outpoint, 0)
+	for i := 0; i < len(buf); {
+		l, lv := unpackVarint(buf[i:])
+		j := i + lv + int(l)
+		if l < 0 || j >= len(buf) {
+			return nil, nil, errors.New("Inconsistent data in blockAddresses")
+		}
+		addrID := append([]byte(nil), buf[i+lv:j]...)
+		n, nl := unpackVarint(buf[j:])
+		j += nl
+		outpoints := make([]outpoint, n)
+		for k := range outpoints {
+			if j+txidUnpackedLen >= len(buf) {
+				return nil, nil, errors.New("Inconsistent data in blockAddresses")
+			}
+			outpoints[k].btxID = append([]byte(nil), buf[j:j+txidUnpackedLen]...)
+			j += txidUnpackedLen
+			vout, vl := unpackVarint(buf[j:])
+			outpoints[k].vout = vout
+			j += vl
+		}
+		addresses = append(addresses, addrID)
+		outpointsArray = append(outpointsArray, outpoints)
+		i = j
+	}
+	return addresses, outpointsArray, nil
+}
+
+// migrateBlockAddresses converts the blockaddresses record of the block, if there is one, to the current format
+// the values of the outputs spent by the block are taken from the outputs of the block or from the transactions returned by the backend
+func (d *RocksDB) migrateBlockAddresses(wb *gorocksdb.WriteBatch, chain bchain.BlockChain, block *bchain.Block, outputs map[string]*spentOutput, deltas map[string]*addrBalanceDelta) error {
+	key := packUint(block.Height)
+	val, err := d.db.GetCF(d.ro, d.cfh[cfBlockAddresses], key)
+	if err != nil {
+		return err
+	}
+	buf := append([]byte(nil), val.Data()...)
+	val.Free()
+	if len(buf) == 0 {
+		return nil
+	}
+	addrIDs, spent, err := d.unpackBlockAddressesV0(buf)
+	if err != nil {
+		return err
+	}
+	// position of the spent outpoints in the record by outpoint
+	spentIndex := make(map[string][2]int)
+	for i := range spent {
+		for j, o := range spent[i] {
+			spentIndex[outpointKey(o.btxID, o.vout)] = [2]int{i, j}
+		}
+	}
+	txs := make(map[string]*bchain.Tx)
+	for i := range block.Txs {
+		for _, input := range block.Txs[i].Vin {
+			btxID, err := d.chainParser.PackTxid(input.Txid)
+			if err != nil {
+				if err == bchain.ErrTxidMissing {
+					continue
+				}
+				return err
+			}
+			k := outpointKey(btxID, int32(input.Vout))
+			if so, ok := outputs[k]; ok {
+				bd := getAddrBalanceDelta(deltas, so.addrID)
+				bd.sentSat.Add(&bd.sentSat, so.valueSat)
+				continue
+			}
+			si, ok := spentIndex[k]
+			if !ok {
+				continue
+			}
+			o := &spent[si[0]][si[1]]
+			itx, ok := txs[input.Txid]
+			if !ok {
+				if itx, err = chain.GetTransaction(input.Txid); err != nil {
+					return errors.Annotatef(err, "txid %v", input.Txid)
+				}
+				txs[input.Txid] = itx
+			}
+			if int(input.Vout) >= len(itx.Vout) {
+				return errors.Errorf("Output %v of tx %v not found", input.Vout, input.Txid)
+			}
+			o.valueSat = &itx.Vout[input.Vout].ValueSat
+			bd := getAddrBalanceDelta(deltas, addrIDs[si[0]])
+			bd.sentSat.Add(&bd.sentSat, o.valueSat)
+		}
+	}
+	spentTxs := make(map[string][]outpoint)
+	for i, addrID := range addrIDs {
+		spentTxs[string(addrID)] = spent[i]
+	}
+	blockAddresses := make([]byte, 0)
+	for _, addrID := range addrIDs {
+		blockAddresses = append(blockAddresses, d.packBlockAddress(addrID, spentTxs, deltas[string(addrID)])...)
+	}
+	wb.PutCF(d.cfh[cfBlockAddresses], key, blockAddresses)
+	return nil
+}
+
+// migrateBlockUndo creates the undo data of the blocks in the blockaddresses column from the outpoints spent by the addresses in the block
+// the older blocks do not have the undo data, they cannot be disconnected after the migration
+func migrateBlockUndo(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	if !d.chainParser.IsUTXOChain() {
+		return nil
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfBlockAddresses])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows, _, _ := d.is.GetDBColumnStatValues(cfBlockAddresses)
+	p := newMigrationProgress(cfNames[cfBlockUndo], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfBlockUndo])
+		default:
+		}
+		key := it.Key().Data()
+		addrIDs, outpoints, _, err := d.unpackBlockAddresses(it.Value().Data())
+		if err != nil {
+			return errors.Annotatef(err, "key %x", key)
+		}
+		spentOutputs := make([]spentOutput, 0)
+		for i, addrID := range addrIDs {
+			for _, o := range outpoints[i] {
+				spentOutputs = append(spentOutputs, spentOutput{btxID: o.btxID, vout: o.vout, addrID: addrID, valueSat: o.valueSat})
+			}
+		}
+		wb.PutCF(d.cfh[cfBlockUndo], append([]byte(nil), key...), d.packBlockUndo(spentOutputs))
+		p.done++
+		p.changed++
+		if wb.Count() >= migrateBatchSize {
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			wb.Clear()
+		}
+		p.log(false)
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateBlockInfo fills the block time, number of transactions and size to the records of the height column
+// which contain only the block hash, the data are taken from the backend
+func migrateBlockInfo(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	return d.transformColumn(cfHeight, func(key, val []byte) ([]byte, []byte, error) {
+		if !isBlockInfoWithoutMetadata(val) {
+			return nil, nil, nil
+		}
+		info, err := d.unpackBlockInfo(val)
+		if err != nil {
+			return nil, nil, err
+		}
+		height := unpackUint(key)
+		block, err := chain.GetBlock(info.Hash, height)
+		if err != nil {
+			return nil, nil, err
+		}
+		newVal, err := d.packBlockInfo(&BlockInfo{
+			Hash: info.Hash,
+			Time: block.Time,
+			Txs:  uint32(len(block.Txs)),
+			Size: uint32(block.Size),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return append([]byte{}, key...), newVal, nil
+	}, stop)
+}
+
+// migrateSpendingTxs fills the spendingtxs column from the inputs of the transactions in all indexed blocks
+// and adds the outpoints spent in the blocks, which are missing there, to the undo data of the blocks
+// the blocks are taken from the backend, the records are only added therefore the migration can be repeated
+func migrateSpendingTxs(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	if !d.chainParser.IsUTXOChain() {
+		return nil
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows, _, _ := d.is.GetDBColumnStatValues(cfHeight)
+	p := newMigrationProgress(cfNames[cfSpendingTxs], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfSpendingTxs])
+		default:
+		}
+		height := unpackUint(it.Key().Data())
+		info, err := d.unpackBlockInfo(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		block, err := chain.GetBlock(info.Hash, height)
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		// the undo data of the blocks indexed before the migration of blockundo column are missing
+		key := packUint(height)
+		spentOutputs, err := d.getBlockUndo(key)
+		hasUndo := err == nil
+		undoKeys := make(map[string]struct{}, len(spentOutputs))
+		for _, so := range spentOutputs {
+			undoKeys[outpointKey(so.btxID, so.vout)] = struct{}{}
+		}
+		undoChanged := false
+		for _, tx := range block.Txs {
+			spendingTxid, err := d.chainParser.PackTxid(tx.Txid)
+			if err != nil {
+				return err
+			}
+			for i, input := range tx.Vin {
+				btxID, err := d.chainParser.PackTxid(input.Txid)
+				if err != nil {
+					if err == bchain.ErrTxidMissing {
+						continue
+					}
+					return err
+				}
+				k := outpointKey(btxID, int32(input.Vout))
+				wb.PutCF(d.cfh[cfSpendingTxs], []byte(k), d.packSpendingTx(spendingTxid, uint32(i), height))
+				p.changed++
+				if _, exists := undoKeys[k]; hasUndo && !exists {
+					spentOutputs = append(spentOutputs, spentOutput{btxID: btxID, vout: int32(input.Vout)})
+					undoKeys[k] = struct{}{}
+					undoChanged = true
+				}
+			}
+		}
+		if undoChanged {
+			wb.PutCF(d.cfh[cfBlockUndo], key, d.packBlockUndo(spentOutputs))
+		}
+		p.done++
+		if wb.Count() >= migrateBatchSize {
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			wb.Clear()
+		}
+		p.log(false)
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateErc20Transfers adds the ERC20 token transfers of the transactions in all indexed blocks
+// to the addresses and erc20transfers columns, the blocks are taken from the backend
+// outpoints already present in the addresses column are skipped therefore the migration can be repeated
+func migrateErc20Transfers(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	if d.chainParser.IsUTXOChain() {
+		return nil
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	balances := make(map[string]*AddrBalance)
+	flush := func() error {
+		d.writeAddrBalances(wb, balances)
+		if err := d.db.Write(d.wo, wb); err != nil {
+			return err
+		}
+		wb.Clear()
+		balances = make(map[string]*AddrBalance)
+		return nil
+	}
+	rows, _, _ := d.is.GetDBColumnStatValues(cfHeight)
+	p := newMigrationProgress(cfNames[cfErc20Transfers], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := flush(); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfErc20Transfers])
+		default:
+		}
+		height := unpackUint(it.Key().Data())
+		info, err := d.unpackBlockInfo(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		block, err := chain.GetBlock(info.Hash, height)
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		addresses := make(map[string][]outpoint)
+		erc20 := make(map[string][]outpoint)
+		for i := range block.Txs {
+			tx := &block.Txs[i]
+			btxID, err := d.chainParser.PackTxid(tx.Txid)
+			if err != nil {
+				return err
+			}
+			if err = d.addErc20TransfersToRecords(opInsert, wb, addresses, erc20, tx, btxID, height); err != nil {
+				return err
+			}
+		}
+		for addrID, outpoints := range addresses {
+			changed, err := d.mergeAddressRecord(wb, balances, []byte(addrID), height, outpoints)
+			if err != nil {
+				return err
+			}
+			if changed {
+				p.changed++
+			}
+		}
+		d.writeErc20Records(wb, height, opInsert, erc20)
+		p.done++
+		if wb.Count() >= migrateBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		p.log(false)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateContractCreations adds the contracts created by transactions in all indexed blocks to the addresses column,
+// the transactions of the blocks are removed from the transactions cache, they are cached again with the receipt data
+// the blocks are taken from the backend, outpoints already present in the addresses column are skipped therefore the migration can be repeated
+func migrateContractCreations(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	if d.chainParser.IsUTXOChain() {
+		return nil
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	balances := make(map[string]*AddrBalance)
+	flush := func() error {
+		d.writeAddrBalances(wb, balances)
+		if err := d.db.Write(d.wo, wb); err != nil {
+			return err
+		}
+		wb.Clear()
+		balances = make(map[string]*AddrBalance)
+		return nil
+	}
+	rows, _, _ := d.is.GetDBColumnStatValues(cfHeight)
+	p := newMigrationProgress(cfNames[cfAddresses], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := flush(); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfAddresses])
+		default:
+		}
+		height := unpackUint(it.Key().Data())
+		info, err := d.unpackBlockInfo(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		block, err := chain.GetBlock(info.Hash, height)
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		addresses := make(map[string][]outpoint)
+		for i := range block.Txs {
+			tx := &block.Txs[i]
+			btxID, err := d.chainParser.PackTxid(tx.Txid)
+			if err != nil {
+				return err
+			}
+			d.internalDeleteTx(wb, btxID)
+			for j := range tx.Vout {
+				output := &tx.Vout[j]
+				addrID, err := d.chainParser.GetAddrIDFromVout(output)
+				if err != nil {
+					continue
+				}
+				addresses[string(addrID)] = append(addresses[string(addrID)], outpoint{btxID: btxID, vout: int32(output.N)})
+			}
+		}
+		for addrID, outpoints := range addresses {
+			changed, err := d.mergeAddressRecord(wb, balances, []byte(addrID), height, outpoints)
+			if err != nil {
+				return err
+			}
+			if changed {
+				p.changed++
+			}
+		}
+		p.done++
+		if wb.Count() >= migrateBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		p.log(false)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// migrateContractLogs indexes the event logs of contracts in all indexed blocks in the contractlogs column
+// if the index of the logs is enabled, the blocks are taken from the backend
+// the records of the blocks are overwritten therefore the migration can be repeated
+func migrateContractLogs(d *RocksDB, chain bchain.BlockChain, stop chan os.Signal) error {
+	if d.chainParser.IsUTXOChain() || !d.chainParser.IndexContractLogs() {
+		return nil
+	}
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+	defer it.Close()
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	rows, _, _ := d.is.GetDBColumnStatValues(cfHeight)
+	p := newMigrationProgress(cfNames[cfContractLogs], rows)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		select {
+		case <-stop:
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			return errors.Errorf("Migration of column %v interrupted", cfNames[cfContractLogs])
+		default:
+		}
+		height := unpackUint(it.Key().Data())
+		info, err := d.unpackBlockInfo(it.Value().Data())
+		if err != nil {
+			return err
+		}
+		block, err := chain.GetBlock(info.Hash, height)
+		if err != nil {
+			return errors.Annotatef(err, "height %d", height)
+		}
+		logs := make(map[string][]contractLogEntry)
+		for i := range block.Txs {
+			tx := &block.Txs[i]
+			btxID, err := d.chainParser.PackTxid(tx.Txid)
+			if err != nil {
+				return err
+			}
+			if err = d.addContractLogsToRecords(logs, tx, btxID, i, height); err != nil {
+				return err
+			}
+		}
+		if len(logs) > 0 {
+			d.writeContractLogRecords(wb, height, opInsert, logs)
+			p.changed++
+		}
+		p.done++
+		if wb.Count() >= migrateBatchSize {
+			if err := d.db.Write(d.wo, wb); err != nil {
+				return err
+			}
+			wb.Clear()
+		}
+		p.log(false)
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		return err
+	}
+	p.log(true)
+	return nil
+}
+
+// mergeAddressRecord adds the outpoints missing in the record of the addresses column of the address at the height
+// and increases the number of transactions of the address by the transactions which were not in the record
+// returns true if the record was changed
+func (d *RocksDB) mergeAddressRecord(wb *gorocksdb.WriteBatch, balances map[string]*AddrBalance, addrID []byte, height uint32, outpoints []outpoint) (bool, error) {
+	key := packAddressKey(addrID, height)
+	val, err := d.db.GetCF(d.ro, d.cfh[cfAddresses], key)
+	if err != nil {
+		return false, err
+	}
+	existing, err := d.unpackOutpoints(val.Data())
+	val.Free()
+	if err != nil {
+		return false, err
+	}
+	known := make(map[string]struct{})
+	txs := make(map[string]struct{})
+	for _, o := range existing {
+		known[outpointKey(o.btxID, o.vout)] = struct{}{}
+		txs[string(o.btxID)] = struct{}{}
+	}
+	merged := existing
+	newTxs := 0
+	for _, o := range outpoints {
+		k := outpointKey(o.btxID, o.vout)
+		if _, ok := known[k]; ok {
+			continue
+		}
+		known[k] = struct{}{}
+		merged = append(merged, o)
+		if _, ok := txs[string(o.btxID)]; !ok {
+			txs[string(o.btxID)] = struct{}{}
+			newTxs++
+		}
+	}
+	if len(merged) == len(existing) {
+		return false, nil
+	}
+	wb.PutCF(d.cfh[cfAddresses], key, d.packOutpoints(merged))
+	if newTxs > 0 {
+		ab, err := d.getAddrBalanceForUpdate(balances, addrID)
+		if err != nil {
+			return false, err
+		}
+		ab.applyDelta(newTxs, nil, opInsert)
+	}
+	return true, nil
+}
@@ -0,0 +1,372 @@
+// +build unittest
+
+package db
+
+import (
+	"blockbook/bchain"
+	"blockbook/bchain/coins/btc"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+// testMigrationChain implements only GetBlock and GetTransaction of the BlockChain interface
+type testMigrationChain struct {
+	bchain.BlockChain
+	blocks []*bchain.Block
+}
+
+func (c *testMigrationChain) GetBlock(hash string, height uint32) (*bchain.Block, error) {
+	for _, b := range c.blocks {
+		if b.Hash == hash {
+			return b, nil
+		}
+	}
+	return nil, bchain.ErrBlockNotFound
+}
+
+func (c *testMigrationChain) GetTransaction(txid string) (*bchain.Tx, error) {
+	for _, b := range c.blocks {
+		for i := range b.Txs {
+			if b.Txs[i].Txid == txid {
+				return &b.Txs[i], nil
+			}
+		}
+	}
+	return nil, errors.Errorf("Transaction %v not found", txid)
+}
+
+// setDbVersion simulates db created by an older version of blockbook
+func setDbVersion(t *testing.T, d *RocksDB, version uint32) {
+	d.is.DbVersion = version
+	for i := range d.is.DbColumns {
+		d.is.DbColumns[i].Version = version
+	}
+	if err := d.StoreInternalState(d.is); err != nil {
+		t.Fatal(err)
+	}
+	is, err := d.LoadInternalState("btc-testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetInternalState(is)
+}
+
+func TestRocksDB_Migrate(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: &btc.BitcoinParser{
+			BaseParser: &bchain.BaseParser{BlockAddressesToKeep: 1},
+			Params:     btc.GetChainParams("test"),
+		},
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	if d.MigrationNeeded() {
+		t.Fatal("MigrationNeeded() = true for new db")
+	}
+
+	block1 := getTestUTXOBlock1(t, d)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	block2 := getTestUTXOBlock2(t, d)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+
+	// store the height column in the format of version 2, i.e. only the block hash
+	for _, b := range []*bchain.Block{block1, block2} {
+		hash, err := d.chainParser.PackBlockHash(b.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.db.PutCF(d.wo, d.cfh[cfHeight], packUint(b.Height), hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setDbVersion(t, d, 2)
+	if !d.MigrationNeeded() {
+		t.Fatal("MigrationNeeded() = false for db version 2")
+	}
+
+	// migration fails if the backend does not have the blocks, the db stays at the old version
+	err := d.Migrate(&testMigrationChain{blocks: []*bchain.Block{block1}}, nil)
+	if errors.Cause(err) != bchain.ErrBlockNotFound {
+		t.Fatalf("Migrate() error = %v, want %v", err, bchain.ErrBlockNotFound)
+	}
+	if d.is.DbVersion != 2 {
+		t.Fatalf("DbVersion = %v, want 2", d.is.DbVersion)
+	}
+
+	if err := d.Migrate(&testMigrationChain{blocks: []*bchain.Block{block1, block2}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterUTXOBlock2(t, d)
+	if d.MigrationNeeded() {
+		t.Fatal("MigrationNeeded() = true after migration")
+	}
+	for _, c := range d.is.DbColumns {
+		if c.Version != dbVersion {
+			t.Fatalf("column %v version %v, want %v", c.Name, c.Version, dbVersion)
+		}
+	}
+
+	// version without migrations is not compatible
+	d.is.DbVersion = dbVersion + 1
+	for i := range d.is.DbColumns {
+		d.is.DbColumns[i].Version = dbVersion + 1
+	}
+	if err := d.StoreInternalState(d.is); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.LoadInternalState("btc-testnet"); err == nil {
+		t.Fatalf("LoadInternalState() expected error for db version %v", dbVersion+1)
+	}
+}
+
+func TestRocksDB_MigrateBlockInfo(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: &btc.BitcoinParser{
+			BaseParser: &bchain.BaseParser{BlockAddressesToKeep: 1},
+			Params:     btc.GetChainParams("test"),
+		},
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	block1 := getTestUTXOBlock1(t, d)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	block2 := getTestUTXOBlock2(t, d)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := d.chainParser.PackBlockHash(block1.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.PutCF(d.wo, d.cfh[cfHeight], packUint(block1.Height), hash); err != nil {
+		t.Fatal(err)
+	}
+	info, err := d.GetBlockInfo(block1.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BlockInfo{Hash: block1.Hash, Height: block1.Height}); *info != want {
+		t.Fatalf("GetBlockInfo() = %+v, want %+v", info, want)
+	}
+
+	// only the records containing just the block hash are rewritten, the backend is not asked for the other blocks
+	if err := migrateBlockInfo(d, &testMigrationChain{blocks: []*bchain.Block{block1}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []*bchain.Block{block1, block2} {
+		info, err := d.GetBlockInfo(b.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := BlockInfo{Hash: b.Hash, Time: b.Time, Txs: uint32(len(b.Txs)), Size: uint32(b.Size), Height: b.Height}
+		if *info != want {
+			t.Errorf("GetBlockInfo(%v) = %+v, want %+v", b.Height, info, want)
+		}
+	}
+}
+
+// getColumn returns all records of the column as hex strings
+func getColumn(t *testing.T, d *RocksDB, col int) map[string]string {
+	r := make(map[string]string)
+	it := d.db.NewIteratorCF(d.ro, d.cfh[col])
+	defer it.Close()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		r[hex.EncodeToString(it.Key().Data())] = hex.EncodeToString(it.Value().Data())
+	}
+	return r
+}
+
+func deleteColumn(t *testing.T, d *RocksDB, col int) {
+	for k := range getColumn(t, d, col) {
+		key, _ := hex.DecodeString(k)
+		if err := d.db.DeleteCF(d.wo, d.cfh[col], key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// sortUnspentAddrs sorts the outputs in the hex encoded record of the unspenttxs column
+func sortUnspentAddrs(t *testing.T, v string) string {
+	buf, _ := hex.DecodeString(v)
+	var addrs []string
+	for i := 0; i < len(buf); {
+		l, lv := unpackVarint(buf[i:])
+		j := i + lv + int(l)
+		_, vl := unpackVarint(buf[j:])
+		_, bl := unpackBigint(buf[j+vl:])
+		addrs = append(addrs, hex.EncodeToString(buf[i:j+vl+bl]))
+		i = j + vl + bl
+	}
+	sort.Strings(addrs)
+	return strings.Join(addrs, "")
+}
+
+// storeVersion0Format rewrites the db to the format of db version 0,
+// i.e. without the values of outputs, without addressbalance, blockundo and spendingtxs columns and with only block hashes in the height column
+func storeVersion0Format(t *testing.T, d *RocksDB, blocks []*bchain.Block) {
+	for _, b := range blocks {
+		hash, err := d.chainParser.PackBlockHash(b.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.db.PutCF(d.wo, d.cfh[cfHeight], packUint(b.Height), hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for k, v := range getColumn(t, d, cfUnspentTxs) {
+		key, _ := hex.DecodeString(k)
+		buf, _ := hex.DecodeString(v)
+		var old []byte
+		for i := 0; i < len(buf); {
+			l, lv := unpackVarint(buf[i:])
+			j := i + lv + int(l)
+			_, vl := unpackVarint(buf[j:])
+			_, bl := unpackBigint(buf[j+vl:])
+			old = append(old, buf[i:j+vl]...)
+			i = j + vl + bl
+		}
+		if err := d.db.PutCF(d.wo, d.cfh[cfUnspentTxs], key, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for k, v := range getColumn(t, d, cfBlockAddresses) {
+		key, _ := hex.DecodeString(k)
+		buf, _ := hex.DecodeString(v)
+		addrIDs, outpoints, _, err := d.unpackBlockAddresses(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var old []byte
+		for i, addrID := range addrIDs {
+			old = append(old, byte(len(addrID)*2))
+			old = append(old, addrID...)
+			old = append(old, byte(len(outpoints[i])*2))
+			old = append(old, d.packOutpoints(outpoints[i])...)
+		}
+		if err := d.db.PutCF(d.wo, d.cfh[cfBlockAddresses], key, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleteColumn(t, d, cfAddressBalance)
+	deleteColumn(t, d, cfBlockUndo)
+	deleteColumn(t, d, cfSpendingTxs)
+	setDbVersion(t, d, 0)
+}
+
+func TestRocksDB_MigrateFromVersion0(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: &btc.BitcoinParser{
+			BaseParser: &bchain.BaseParser{BlockAddressesToKeep: 1},
+			Params:     btc.GetChainParams("test"),
+		},
+	})
+	defer closeAndDestroyRocksDB(t, d)
+
+	block1 := getTestUTXOBlock1(t, d)
+	if err := d.ConnectBlock(block1); err != nil {
+		t.Fatal(err)
+	}
+	afterBlock1 := make(map[int]map[string]string)
+	for _, col := range []int{cfHeight, cfAddresses, cfUnspentTxs, cfAddressBalance, cfSpendingTxs} {
+		afterBlock1[col] = getColumn(t, d, col)
+	}
+	block2 := getTestUTXOBlock2(t, d)
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	expected := make(map[int]map[string]string)
+	for _, col := range []int{cfHeight, cfAddresses, cfUnspentTxs, cfBlockAddresses, cfAddressBalance, cfBlockUndo, cfSpendingTxs} {
+		expected[col] = getColumn(t, d, col)
+	}
+
+	// cached transaction of db version 0, the values of outputs are stored as double
+	btxID, err := d.chainParser.PackTxid(block2.Txs[0].Txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.PutCF(d.wo, d.cfh[cfTransactions], btxID, []byte{0x3a, 0x09, 0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}); err != nil {
+		t.Fatal(err)
+	}
+	storeVersion0Format(t, d, []*bchain.Block{block1, block2})
+	if !d.MigrationNeeded() {
+		t.Fatal("MigrationNeeded() = false for db version 0")
+	}
+	if err := d.Migrate(&testMigrationChain{blocks: []*bchain.Block{block1, block2}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d.MigrationNeeded() {
+		t.Fatal("MigrationNeeded() = true after migration")
+	}
+
+	for col, e := range expected {
+		got := getColumn(t, d, col)
+		if col == cfBlockUndo {
+			// only the blocks in the blockaddresses column have the undo data
+			for k := range e {
+				if _, ok := expected[cfBlockAddresses][k]; !ok {
+					delete(e, k)
+				}
+			}
+			for k, v := range got {
+				buf, _ := hex.DecodeString(v)
+				spentOutputs, err := d.unpackBlockUndo(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Slice(spentOutputs, func(i, j int) bool {
+					return outpointKey(spentOutputs[i].btxID, spentOutputs[i].vout) < outpointKey(spentOutputs[j].btxID, spentOutputs[j].vout)
+				})
+				got[k] = hex.EncodeToString(d.packBlockUndo(spentOutputs))
+			}
+			for k, v := range e {
+				buf, _ := hex.DecodeString(v)
+				spentOutputs, err := d.unpackBlockUndo(buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Slice(spentOutputs, func(i, j int) bool {
+					return outpointKey(spentOutputs[i].btxID, spentOutputs[i].vout) < outpointKey(spentOutputs[j].btxID, spentOutputs[j].vout)
+				})
+				e[k] = hex.EncodeToString(d.packBlockUndo(spentOutputs))
+			}
+		}
+		if !reflect.DeepEqual(got, e) {
+			t.Errorf("column %v after migration = %v, want %v", cfNames[col], got, e)
+		}
+	}
+	if txs := getColumn(t, d, cfTransactions); len(txs) != 0 {
+		t.Errorf("transactions cache not removed by migration, %v", txs)
+	}
+	if _, ok := getColumn(t, d, cfDefault)[hex.EncodeToString([]byte(migrateAddrBalancesHeightKey))]; ok {
+		t.Error("progress of the migration not removed")
+	}
+
+	// the migrated block can be disconnected
+	if err := d.DisconnectBlockRange(225494, 225494); err != nil {
+		t.Fatal(err)
+	}
+	for col, e := range afterBlock1 {
+		got := getColumn(t, d, col)
+		if col == cfUnspentTxs {
+			// the order of the restored outputs depends on the order of the undo data
+			for k := range got {
+				got[k] = sortUnspentAddrs(t, got[k])
+				e[k] = sortUnspentAddrs(t, e[k])
+			}
+		}
+		if !reflect.DeepEqual(got, e) {
+			t.Errorf("column %v after disconnect = %v, want %v", cfNames[col], got, e)
+		}
+	}
+}
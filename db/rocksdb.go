@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"math/big"
 	"os"
 	"path/filepath"
 	"time"
@@ -21,7 +22,12 @@ import (
 // when doing huge scan, it is better to close it and reopen from time to time to free the resources
 const refreshIterator = 5000000
 const packedHeightBytes = 4
-const dbVersion = 0
+
+// dbVersion is the version of the db schema, older versions can be upgraded by the migrations in migrate.go
+const dbVersion = 7
+
+// packedBlockHashLen is the length of packed block hash, it is the same for all supported coins
+const packedBlockHashLen = 32
 
 // RepairRocksDB calls RocksDb db repair function
 func RepairRocksDB(name string) error {
@@ -49,9 +55,14 @@ const (
 	cfUnspentTxs
 	cfTransactions
 	cfBlockAddresses
+	cfAddressBalance
+	cfBlockUndo
+	cfSpendingTxs
+	cfErc20Transfers
+	cfContractLogs
 )
 
-var cfNames = []string{"default", "height", "addresses", "unspenttxs", "transactions", "blockaddresses"}
+var cfNames = []string{"default", "height", "addresses", "unspenttxs", "transactions", "blockaddresses", "addressbalance", "blockundo", "spendingtxs", "erc20transfers", "contractlogs"}
 
 func openDB(path string) (*gorocksdb.DB, []*gorocksdb.ColumnFamilyHandle, error) {
 	c := gorocksdb.NewLRUCache(8 << 30) // 8GB
@@ -89,7 +100,7 @@ func openDB(path string) (*gorocksdb.DB, []*gorocksdb.ColumnFamilyHandle, error)
 	optsOutputs.SetMaxOpenFiles(25000)
 	optsOutputs.SetCompression(gorocksdb.NoCompression)
 
-	fcOptions := []*gorocksdb.Options{opts, opts, optsOutputs, opts, opts, opts}
+	fcOptions := []*gorocksdb.Options{opts, opts, optsOutputs, opts, opts, opts, opts, opts, opts, optsOutputs, optsOutputs}
 
 	db, cfh, err := gorocksdb.OpenDbColumnFamilies(opts, path, cfNames, fcOptions)
 	if err != nil {
@@ -162,11 +173,23 @@ func (d *RocksDB) GetTransactions(address string, lower uint32, higher uint32, f
 	if err != nil {
 		return err
 	}
+	return d.GetAddrIDTransactions(addrID, lower, higher, func(txid string, height uint32, vout uint32, isOutput bool) error {
+		return fn(txid, vout, isOutput)
+	})
+}
 
-	kstart := packAddressKey(addrID, lower)
-	kstop := packAddressKey(addrID, higher)
+// GetAddrIDTransactions finds all input/output transactions for addrID
+// Transaction are passed to callback function together with the height of the block.
+func (d *RocksDB) GetAddrIDTransactions(addrID []byte, lower uint32, higher uint32, fn func(txid string, height uint32, vout uint32, isOutput bool) error) (err error) {
+	return d.iterateOutpoints(cfAddresses, addrID, lower, higher, fn)
+}
 
-	it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddresses])
+// iterateOutpoints passes to the callback function the outpoints stored in the column cf under the keys id+height
+func (d *RocksDB) iterateOutpoints(cf int, id []byte, lower uint32, higher uint32, fn func(txid string, height uint32, vout uint32, isOutput bool) error) (err error) {
+	kstart := packAddressKey(id, lower)
+	kstop := packAddressKey(id, higher)
+
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cf])
 	defer it.Close()
 
 	for it.Seek(kstart); it.Valid(); it.Next() {
@@ -175,6 +198,10 @@ func (d *RocksDB) GetTransactions(address string, lower uint32, higher uint32, f
 		if bytes.Compare(key, kstop) > 0 {
 			break
 		}
+		_, height, err := unpackAddressKey(key)
+		if err != nil {
+			return err
+		}
 		outpoints, err := d.unpackOutpoints(val)
 		if err != nil {
 			return err
@@ -196,7 +223,7 @@ func (d *RocksDB) GetTransactions(address string, lower uint32, higher uint32, f
 			if err != nil {
 				return err
 			}
-			if err := fn(tx, vout, isOutput); err != nil {
+			if err := fn(tx, height, vout, isOutput); err != nil {
 				return err
 			}
 		}
@@ -204,6 +231,87 @@ func (d *RocksDB) GetTransactions(address string, lower uint32, higher uint32, f
 	return nil
 }
 
+// Utxo is unspent transaction output of an address
+type Utxo struct {
+	Txid     string
+	Vout     uint32
+	Height   uint32
+	ValueSat big.Int
+}
+
+// GetAddressUtxos returns unspent outputs of the address found in the column unspenttxs, ordered by height
+// It is supported only for UTXO chains
+func (d *RocksDB) GetAddressUtxos(address string) ([]Utxo, error) {
+	addrID, err := d.chainParser.GetAddrIDFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetAddrIDUtxos(addrID)
+}
+
+// GetAddrIDUtxos returns unspent outputs of the addrID found in the column unspenttxs, ordered by height
+// It is supported only for UTXO chains
+func (d *RocksDB) GetAddrIDUtxos(addrID []byte) ([]Utxo, error) {
+	if !d.chainParser.IsUTXOChain() {
+		return nil, errors.New("GetAddrIDUtxos is not supported for non UTXO chains")
+	}
+	kstart := packAddressKey(addrID, 0)
+	kstop := packAddressKey(addrID, ^uint32(0))
+
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddresses])
+	defer it.Close()
+
+	utxos := make([]Utxo, 0)
+	// unspent addresses of the txs already read, the found outputs are removed from them
+	unspentTxs := make(map[string][]byte)
+	for it.Seek(kstart); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		if bytes.Compare(key, kstop) > 0 {
+			break
+		}
+		_, height, err := unpackAddressKey(key)
+		if err != nil {
+			return nil, err
+		}
+		outpoints, err := d.unpackOutpoints(it.Value().Data())
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range outpoints {
+			// only outputs can be unspent
+			if o.vout < 0 {
+				continue
+			}
+			stxID := string(o.btxID)
+			unspentAddrs, exists := unspentTxs[stxID]
+			if !exists {
+				unspentAddrs, err = d.getUnspentTx(o.btxID)
+				if err != nil {
+					return nil, err
+				}
+			}
+			var uaddrID []byte
+			var valueSat *big.Int
+			uaddrID, valueSat, unspentAddrs = findAndRemoveUnspentAddr(unspentAddrs, uint32(o.vout))
+			unspentTxs[stxID] = unspentAddrs
+			if uaddrID == nil || !bytes.Equal(uaddrID, addrID) {
+				continue
+			}
+			txid, err := d.chainParser.UnpackTxid(o.btxID)
+			if err != nil {
+				return nil, err
+			}
+			utxos = append(utxos, Utxo{
+				Txid:     txid,
+				Vout:     uint32(o.vout),
+				Height:   height,
+				ValueSat: *valueSat,
+			})
+		}
+	}
+	return utxos, nil
+}
+
 const (
 	opInsert = 0
 	opDelete = 1
@@ -255,30 +363,63 @@ func (d *RocksDB) writeBlock(block *bchain.Block, op int) error {
 type outpoint struct {
 	btxID []byte
 	vout  int32
+	// valueSat is set only for outpoints spent in a block, stored in blockaddresses column
+	valueSat *big.Int
 }
 
-func (d *RocksDB) packBlockAddress(addrID []byte, spentTxs map[string][]outpoint) []byte {
+// addrBalanceDelta is the change of the amounts of an address caused by one block
+type addrBalanceDelta struct {
+	sentSat     big.Int
+	receivedSat big.Int
+}
+
+func getAddrBalanceDelta(deltas map[string]*addrBalanceDelta, addrID []byte) *addrBalanceDelta {
+	strAddrID := string(addrID)
+	bd, exists := deltas[strAddrID]
+	if !exists {
+		bd = &addrBalanceDelta{}
+		deltas[strAddrID] = bd
+	}
+	return bd
+}
+
+func (d *RocksDB) packBlockAddress(addrID []byte, spentTxs map[string][]outpoint, bd *addrBalanceDelta) []byte {
 	vBuf := make([]byte, vlq.MaxLen32)
 	vl := packVarint(int32(len(addrID)), vBuf)
 	blockAddress := append([]byte(nil), vBuf[:vl]...)
 	blockAddress = append(blockAddress, addrID...)
+	if bd == nil {
+		bd = &addrBalanceDelta{}
+	}
+	blockAddress = append(blockAddress, packBigint(&bd.sentSat)...)
+	blockAddress = append(blockAddress, packBigint(&bd.receivedSat)...)
 	if spentTxs == nil {
 	} else {
 		addrUnspentTxs := spentTxs[string(addrID)]
 		vl = packVarint(int32(len(addrUnspentTxs)), vBuf)
 		blockAddress = append(blockAddress, vBuf[:vl]...)
-		buf := d.packOutpoints(addrUnspentTxs)
-		blockAddress = append(blockAddress, buf...)
+		for _, o := range addrUnspentTxs {
+			vl = packVarint(o.vout, vBuf)
+			blockAddress = append(blockAddress, o.btxID...)
+			blockAddress = append(blockAddress, vBuf[:vl]...)
+			blockAddress = append(blockAddress, packBigint(o.valueSat)...)
+		}
 	}
 	return blockAddress
 }
 
-func (d *RocksDB) writeAddressRecords(wb *gorocksdb.WriteBatch, block *bchain.Block, op int, addresses map[string][]outpoint, spentTxs map[string][]outpoint) error {
+func (d *RocksDB) writeAddressRecords(wb *gorocksdb.WriteBatch, block *bchain.Block, op int, addresses map[string][]outpoint, spentTxs map[string][]outpoint, deltas map[string]*addrBalanceDelta) error {
 	keep := d.chainParser.KeepBlockAddresses()
 	blockAddresses := make([]byte, 0)
+	balances := make(map[string]*AddrBalance)
 	for addrID, outpoints := range addresses {
 		baddrID := []byte(addrID)
 		key := packAddressKey(baddrID, block.Height)
+		ab, err := d.getAddrBalanceForUpdate(balances, baddrID)
+		if err != nil {
+			return err
+		}
+		ab.applyDelta(countTxs(outpoints), deltas[addrID], op)
 		switch op {
 		case opInsert:
 			val := d.packOutpoints(outpoints)
@@ -286,13 +427,14 @@ func (d *RocksDB) writeAddressRecords(wb *gorocksdb.WriteBatch, block *bchain.Bl
 			if keep > 0 {
 				// collect all addresses be stored in blockaddresses
 				// they are used in disconnect blocks
-				blockAddress := d.packBlockAddress(baddrID, spentTxs)
+				blockAddress := d.packBlockAddress(baddrID, spentTxs, deltas[addrID])
 				blockAddresses = append(blockAddresses, blockAddress...)
 			}
 		case opDelete:
 			wb.DeleteCF(d.cfh[cfAddresses], key)
 		}
 	}
+	d.writeAddrBalances(wb, balances)
 	if keep > 0 && op == opInsert {
 		// write new block address and txs spent in this block
 		key := packUint(block.Height)
@@ -346,50 +488,59 @@ func (d *RocksDB) getUnspentTx(btxID []byte) ([]byte, error) {
 	return data, nil
 }
 
-func appendPackedAddrID(txAddrs []byte, addrID []byte, n uint32, remaining int) []byte {
+func appendPackedAddrID(txAddrs []byte, addrID []byte, n uint32, valueSat *big.Int, remaining int) []byte {
+	value := packBigint(valueSat)
 	// resize the addr buffer if necessary by a new estimate
-	if cap(txAddrs)-len(txAddrs) < 2*vlq.MaxLen32+len(addrID) {
-		txAddrs = append(txAddrs, make([]byte, vlq.MaxLen32+len(addrID)+remaining*32)...)[:len(txAddrs)]
+	if cap(txAddrs)-len(txAddrs) < 2*vlq.MaxLen32+len(addrID)+len(value) {
+		txAddrs = append(txAddrs, make([]byte, vlq.MaxLen32+len(addrID)+len(value)+remaining*32)...)[:len(txAddrs)]
 	}
-	// addrID is packed as number of bytes of the addrID + bytes of addrID + vout
+	// addrID is packed as number of bytes of the addrID + bytes of addrID + vout + value
 	lv := packVarint(int32(len(addrID)), txAddrs[len(txAddrs):len(txAddrs)+vlq.MaxLen32])
 	txAddrs = txAddrs[:len(txAddrs)+lv]
 	txAddrs = append(txAddrs, addrID...)
 	lv = packVarint(int32(n), txAddrs[len(txAddrs):len(txAddrs)+vlq.MaxLen32])
 	txAddrs = txAddrs[:len(txAddrs)+lv]
+	txAddrs = append(txAddrs, value...)
 	return txAddrs
 }
 
-func findAndRemoveUnspentAddr(unspentAddrs []byte, vout uint32) ([]byte, []byte) {
-	// the addresses are packed as lenaddrID addrID vout, where lenaddrID and vout are varints
+func findAndRemoveUnspentAddr(unspentAddrs []byte, vout uint32) ([]byte, *big.Int, []byte) {
+	// the addresses are packed as lenaddrID addrID vout value, where lenaddrID and vout are varints
 	for i := 0; i < len(unspentAddrs); {
 		l, lv1 := unpackVarint(unspentAddrs[i:])
 		// index of vout of address in unspentAddrs
 		j := i + int(l) + lv1
 		if j >= len(unspentAddrs) {
 			glog.Error("rocksdb: Inconsistent data in unspentAddrs ", hex.EncodeToString(unspentAddrs), ", ", vout)
-			return nil, unspentAddrs
+			return nil, nil, unspentAddrs
 		}
 		n, lv2 := unpackVarint(unspentAddrs[j:])
+		k := j + lv2
+		if k >= len(unspentAddrs) || k+int(unspentAddrs[k]) >= len(unspentAddrs) {
+			glog.Error("rocksdb: Inconsistent data in unspentAddrs ", hex.EncodeToString(unspentAddrs), ", ", vout)
+			return nil, nil, unspentAddrs
+		}
+		valueSat, lv3 := unpackBigint(unspentAddrs[k:])
 		if uint32(n) == vout {
 			addrID := append([]byte(nil), unspentAddrs[i+lv1:j]...)
-			unspentAddrs = append(unspentAddrs[:i], unspentAddrs[j+lv2:]...)
-			return addrID, unspentAddrs
+			unspentAddrs = append(unspentAddrs[:i], unspentAddrs[k+lv3:]...)
+			return addrID, &valueSat, unspentAddrs
 		}
-		i = j + lv2
+		i = k + lv3
 	}
-	return nil, unspentAddrs
+	return nil, nil, unspentAddrs
 }
 
 func (d *RocksDB) writeAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Block, op int) error {
 	if op == opDelete {
 		// block does not contain mapping tx-> input address, which is necessary to recreate
-		// unspentTxs; therefore it is not possible to DisconnectBlocks this way
-		return errors.New("DisconnectBlock is not supported for UTXO chains")
+		// unspentTxs; it is taken from the undo data of the block
+		return d.disconnectAddressesUTXO(wb, block)
 	}
 	addresses := make(map[string][]outpoint)
 	unspentTxs := make(map[string][]byte)
 	thisBlockTxs := make(map[string]struct{})
+	deltas := make(map[string]*addrBalanceDelta)
 	btxIDs := make([][]byte, len(block.Txs))
 	// first process all outputs, build mapping of addresses to outpoints and mappings of unspent txs to addresses
 	for txi, tx := range block.Txs {
@@ -413,7 +564,9 @@ func (d *RocksDB) writeAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Blo
 			if err != nil {
 				return err
 			}
-			txAddrs = appendPackedAddrID(txAddrs, addrID, output.N, len(tx.Vout)-i)
+			bd := getAddrBalanceDelta(deltas, addrID)
+			bd.receivedSat.Add(&bd.receivedSat, &output.ValueSat)
+			txAddrs = appendPackedAddrID(txAddrs, addrID, output.N, &output.ValueSat, len(tx.Vout)-i)
 		}
 		stxID := string(btxID)
 		unspentTxs[stxID] = txAddrs
@@ -422,6 +575,7 @@ func (d *RocksDB) writeAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Blo
 	// locate addresses spent by this tx and remove them from unspent addresses
 	// keep them so that they be stored for DisconnectBlock functionality
 	spentTxs := make(map[string][]outpoint)
+	spentOutputs := make([]spentOutput, 0)
 	for txi, tx := range block.Txs {
 		spendingTxid := btxIDs[txi]
 		for i, input := range tx.Vin {
@@ -433,6 +587,8 @@ func (d *RocksDB) writeAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Blo
 				}
 				return err
 			}
+			// index the input which spends the output
+			wb.PutCF(d.cfh[cfSpendingTxs], []byte(outpointKey(btxID, int32(input.Vout))), d.packSpendingTx(spendingTxid, uint32(i), block.Height))
 			// find the tx in current block or already processed
 			stxID := string(btxID)
 			unspentAddrs, exists := unspentTxs[stxID]
@@ -444,31 +600,39 @@ func (d *RocksDB) writeAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Blo
 				}
 				if unspentAddrs == nil {
 					glog.Warningf("rocksdb: height %d, tx %v, input tx %v vin %v %v missing in unspentTxs", block.Height, tx.Txid, input.Txid, input.Vout, i)
+					spentOutputs = append(spentOutputs, spentOutput{btxID: btxID, vout: int32(input.Vout)})
 					continue
 				}
 			}
 			var addrID []byte
-			addrID, unspentAddrs = findAndRemoveUnspentAddr(unspentAddrs, input.Vout)
+			var valueSat *big.Int
+			addrID, valueSat, unspentAddrs = findAndRemoveUnspentAddr(unspentAddrs, input.Vout)
 			if addrID == nil {
 				glog.Warningf("rocksdb: height %d, tx %v, input tx %v vin %v %v not found in unspentAddrs", block.Height, tx.Txid, input.Txid, input.Vout, i)
+				spentOutputs = append(spentOutputs, spentOutput{btxID: btxID, vout: int32(input.Vout)})
 				continue
 			}
 			// record what was spent in this tx
-			// skip transactions that were created in this block
+			// the outputs of transactions created in this block are recorded in the undo data only as spent outpoints
 			if _, exists := thisBlockTxs[stxID]; !exists {
 				saddrID := string(addrID)
 				rut := spentTxs[saddrID]
-				rut = append(rut, outpoint{btxID, int32(input.Vout)})
+				rut = append(rut, outpoint{btxID, int32(input.Vout), valueSat})
 				spentTxs[saddrID] = rut
+				spentOutputs = append(spentOutputs, spentOutput{btxID, int32(input.Vout), addrID, valueSat})
+			} else {
+				spentOutputs = append(spentOutputs, spentOutput{btxID: btxID, vout: int32(input.Vout)})
 			}
 			err = d.addAddrIDToRecords(op, wb, addresses, addrID, spendingTxid, int32(^i), block.Height)
 			if err != nil {
 				return err
 			}
+			bd := getAddrBalanceDelta(deltas, addrID)
+			bd.sentSat.Add(&bd.sentSat, valueSat)
 			unspentTxs[stxID] = unspentAddrs
 		}
 	}
-	if err := d.writeAddressRecords(wb, block, op, addresses, spentTxs); err != nil {
+	if err := d.writeAddressRecords(wb, block, op, addresses, spentTxs, deltas); err != nil {
 		return err
 	}
 	// save unspent txs from current block
@@ -479,12 +643,116 @@ func (d *RocksDB) writeAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Blo
 			wb.PutCF(d.cfh[cfUnspentTxs], []byte(tx), val)
 		}
 	}
+	// save the outputs spent in this block, they are used to disconnect the block
+	wb.PutCF(d.cfh[cfBlockUndo], packUint(block.Height), d.packBlockUndo(spentOutputs))
+	return nil
+}
+
+// disconnectAddressesUTXO reverts the changes made by writeAddressesUTXO using the block and its undo data
+func (d *RocksDB) disconnectAddressesUTXO(wb *gorocksdb.WriteBatch, block *bchain.Block) error {
+	key := packUint(block.Height)
+	spentOutputs, err := d.getBlockUndo(key)
+	if err != nil {
+		return err
+	}
+	// outputs spent in this block, which were created in previous blocks
+	spent := make(map[string]*spentOutput, len(spentOutputs))
+	for i := range spentOutputs {
+		so := &spentOutputs[i]
+		spent[outpointKey(so.btxID, so.vout)] = so
+	}
+	addresses := make(map[string][]outpoint)
+	deltas := make(map[string]*addrBalanceDelta)
+	// outputs created in this block
+	thisBlockOutputs := make(map[string]*spentOutput)
+	btxIDs := make([][]byte, len(block.Txs))
+	for txi, tx := range block.Txs {
+		btxID, err := d.chainParser.PackTxid(tx.Txid)
+		if err != nil {
+			return err
+		}
+		btxIDs[txi] = btxID
+		for i := range tx.Vout {
+			output := &tx.Vout[i]
+			addrID, err := d.chainParser.GetAddrIDFromVout(output)
+			if err != nil {
+				continue
+			}
+			err = d.addAddrIDToRecords(opDelete, wb, addresses, addrID, btxID, int32(output.N), block.Height)
+			if err != nil {
+				return err
+			}
+			bd := getAddrBalanceDelta(deltas, addrID)
+			bd.receivedSat.Add(&bd.receivedSat, &output.ValueSat)
+			thisBlockOutputs[outpointKey(btxID, int32(output.N))] = &spentOutput{btxID, int32(output.N), addrID, &output.ValueSat}
+		}
+		// unspent outputs of the transactions from this block are removed
+		wb.DeleteCF(d.cfh[cfUnspentTxs], btxID)
+	}
+	// recreate unspentTxs, which were spent by this block
+	unspentTxs := make(map[string][]byte)
+	for txi, tx := range block.Txs {
+		spendingTxid := btxIDs[txi]
+		for i, input := range tx.Vin {
+			btxID, err := d.chainParser.PackTxid(input.Txid)
+			if err != nil {
+				// do not process inputs without input txid
+				if err == bchain.ErrTxidMissing {
+					continue
+				}
+				return err
+			}
+			k := outpointKey(btxID, int32(input.Vout))
+			wb.DeleteCF(d.cfh[cfSpendingTxs], []byte(k))
+			so, exists := thisBlockOutputs[k]
+			if !exists {
+				so, exists = spent[k]
+				if !exists {
+					glog.Warningf("rocksdb: height %d, tx %v, input tx %v vin %v %v not found in undo data", block.Height, tx.Txid, input.Txid, input.Vout, i)
+					continue
+				}
+				// spent output without address
+				if len(so.addrID) == 0 {
+					continue
+				}
+				stxID := string(btxID)
+				txAddrs, exists := unspentTxs[stxID]
+				if !exists {
+					txAddrs, err = d.getUnspentTx(btxID)
+					if err != nil {
+						return err
+					}
+				}
+				unspentTxs[stxID] = appendPackedAddrID(txAddrs, so.addrID, input.Vout, so.valueSat, 1)
+			}
+			err = d.addAddrIDToRecords(opDelete, wb, addresses, so.addrID, spendingTxid, int32(^i), block.Height)
+			if err != nil {
+				return err
+			}
+			bd := getAddrBalanceDelta(deltas, so.addrID)
+			bd.sentSat.Add(&bd.sentSat, so.valueSat)
+		}
+	}
+	if err := d.writeAddressRecords(wb, block, opDelete, addresses, nil, deltas); err != nil {
+		return err
+	}
+	for tx, val := range unspentTxs {
+		wb.PutCF(d.cfh[cfUnspentTxs], []byte(tx), val)
+	}
+	wb.DeleteCF(d.cfh[cfBlockUndo], key)
+	if d.chainParser.KeepBlockAddresses() > 0 {
+		wb.DeleteCF(d.cfh[cfBlockAddresses], key)
+	}
 	return nil
 }
 
 func (d *RocksDB) writeAddressesNonUTXO(wb *gorocksdb.WriteBatch, block *bchain.Block, op int) error {
 	addresses := make(map[string][]outpoint)
-	for _, tx := range block.Txs {
+	erc20 := make(map[string][]outpoint)
+	logs := make(map[string][]contractLogEntry)
+	indexLogs := d.chainParser.IndexContractLogs()
+	for txi := range block.Txs {
+		tx := &block.Txs[txi]
 		btxID, err := d.chainParser.PackTxid(tx.Txid)
 		if err != nil {
 			return err
@@ -517,32 +785,93 @@ func (d *RocksDB) writeAddressesNonUTXO(wb *gorocksdb.WriteBatch, block *bchain.
 				}
 			}
 		}
+		// token transfers do not change the balance of the coin, they only add the transaction to the address
+		if err = d.addErc20TransfersToRecords(op, wb, addresses, erc20, tx, btxID, block.Height); err != nil {
+			return err
+		}
+		if err = d.addInternalTransfersToRecords(op, wb, addresses, tx, btxID, block.Height); err != nil {
+			return err
+		}
+		if indexLogs {
+			if err = d.addContractLogsToRecords(logs, tx, btxID, txi, block.Height); err != nil {
+				return err
+			}
+		}
+	}
+	d.writeErc20Records(wb, block.Height, op, erc20)
+	d.writeContractLogRecords(wb, block.Height, op, logs)
+	// the amounts cannot be computed from the transactions, the fees, rewards and internal transfers are missing,
+	// only the number of transactions of the addresses is kept, the balance is taken from the backend
+	return d.writeAddressRecords(wb, block, op, addresses, nil, nil)
+}
+
+// internalTransferIndexOffset separates the indexes of internal transfers in the addresses column
+// from the indexes of the transaction itself and of its token transfers
+const internalTransferIndexOffset = 1 << 24
+
+// addInternalTransfersToRecords adds the senders and receivers of the internal transfers of the transaction to the records,
+// the sender and the receiver of the k-th transfer are stored as input ^(offset+k) and output offset+k
+// like token transfers, internal transfers do not change the balances, they only add the transaction to the addresses
+func (d *RocksDB) addInternalTransfersToRecords(op int, wb *gorocksdb.WriteBatch, addresses map[string][]outpoint, tx *bchain.Tx, btxID []byte, height uint32) error {
+	transfers, err := d.chainParser.GetInternalTransfersFromTx(tx)
+	if err != nil {
+		return err
+	}
+	for k := range transfers {
+		t := &transfers[k]
+		index := int32(internalTransferIndexOffset + k)
+		for _, a := range []struct {
+			address string
+			index   int32
+		}{{t.From, ^index}, {t.To, index}} {
+			addrID, err := d.chainParser.GetAddrIDFromAddress(a.address)
+			if err != nil {
+				glog.Warningf("rocksdb: addrID: %v - height %d, tx %v, internal transfer address %v", err, height, tx.Txid, a.address)
+				continue
+			}
+			if err = d.addAddrIDToRecords(op, wb, addresses, addrID, btxID, a.index, height); err != nil {
+				return err
+			}
+		}
 	}
-	return d.writeAddressRecords(wb, block, op, addresses, nil)
+	return nil
 }
 
-func (d *RocksDB) unpackBlockAddresses(buf []byte) ([][]byte, [][]outpoint, error) {
+func (d *RocksDB) unpackBlockAddresses(buf []byte) ([][]byte, [][]outpoint, []*addrBalanceDelta, error) {
 	addresses := make([][]byte, 0)
 	outpointsArray := make([][]outpoint, 0)
-	// the addresses are packed as lenaddrID addrID vout, where lenaddrID and vout are varints
+	deltas := make([]*addrBalanceDelta, 0)
+	// the addresses are packed as lenaddrID addrID sentSat receivedSat spentOutpoints,
+	// where lenaddrID is varint and sentSat, receivedSat are packed bigints
 	for i := 0; i < len(buf); {
 		l, lv := unpackVarint(buf[i:])
 		j := i + int(l) + lv
-		if j > len(buf) {
+		if j >= len(buf) || j+int(buf[j])+1 >= len(buf) {
 			glog.Error("rocksdb: Inconsistent data in blockAddresses ", hex.EncodeToString(buf))
-			return nil, nil, errors.New("Inconsistent data in blockAddresses")
+			return nil, nil, nil, errors.New("Inconsistent data in blockAddresses")
 		}
 		addrID := append([]byte(nil), buf[i+lv:j]...)
+		bd := &addrBalanceDelta{}
+		var ll int
+		bd.sentSat, ll = unpackBigint(buf[j:])
+		j += ll
+		if j+int(buf[j]) >= len(buf) {
+			glog.Error("rocksdb: Inconsistent data in blockAddresses ", hex.EncodeToString(buf))
+			return nil, nil, nil, errors.New("Inconsistent data in blockAddresses")
+		}
+		bd.receivedSat, ll = unpackBigint(buf[j:])
+		j += ll
 		outpoints, ol, err := d.unpackNOutpoints(buf[j:])
 		if err != nil {
 			glog.Error("rocksdb: Inconsistent data in blockAddresses ", hex.EncodeToString(buf))
-			return nil, nil, errors.New("Inconsistent data in blockAddresses")
+			return nil, nil, nil, errors.New("Inconsistent data in blockAddresses")
 		}
 		addresses = append(addresses, addrID)
 		outpointsArray = append(outpointsArray, outpoints)
+		deltas = append(deltas, bd)
 		i = j + ol
 	}
-	return addresses, outpointsArray, nil
+	return addresses, outpointsArray, deltas, nil
 }
 
 func (d *RocksDB) packOutpoints(outpoints []outpoint) []byte {
@@ -572,6 +901,7 @@ func (d *RocksDB) unpackOutpoints(buf []byte) ([]outpoint, error) {
 	return outpoints, nil
 }
 
+// unpackNOutpoints unpacks the outpoints spent in a block, stored in blockaddresses column with their values
 func (d *RocksDB) unpackNOutpoints(buf []byte) ([]outpoint, int, error) {
 	txidUnpackedLen := d.chainParser.PackedTxidLen()
 	n, p := unpackVarint(buf)
@@ -584,9 +914,15 @@ func (d *RocksDB) unpackNOutpoints(buf []byte) ([]outpoint, int, error) {
 		p += txidUnpackedLen
 		vout, voutLen := unpackVarint(buf[p:])
 		p += voutLen
+		if p >= len(buf) || p+int(buf[p]) >= len(buf) {
+			return nil, 0, errors.New("Inconsistent data in unpackNOutpoints")
+		}
+		valueSat, valueLen := unpackBigint(buf[p:])
+		p += valueLen
 		outpoints[i] = outpoint{
-			btxID: btxID,
-			vout:  vout,
+			btxID:    btxID,
+			vout:     vout,
+			valueSat: &valueSat,
 		}
 	}
 	return outpoints, p, nil
@@ -612,32 +948,453 @@ func (d *RocksDB) unpackOutpoint(buf []byte) (string, int32, int) {
 	return txid, vout, txidUnpackedLen + o
 }
 
+// Block undo
+
+// spentOutput is an output spent in a block, stored in blockundo column
+// the outputs without address and the outputs created in the same block have empty addrID and zero value,
+// they are stored so that the spending records of all spent outputs can be removed when the block is disconnected
+type spentOutput struct {
+	btxID    []byte
+	vout     int32
+	addrID   []byte
+	valueSat *big.Int
+}
+
+func outpointKey(btxID []byte, vout int32) string {
+	buf := make([]byte, len(btxID)+vlq.MaxLen32)
+	copy(buf, btxID)
+	l := packVarint(vout, buf[len(btxID):])
+	return string(buf[:len(btxID)+l])
+}
+
+// packBlockUndo packs spent outputs as number of outputs followed by btxID vout lenaddrID addrID value for each output
+// the number of outputs distinguishes a block without spent outputs from missing undo data
+func (d *RocksDB) packBlockUndo(spentOutputs []spentOutput) []byte {
+	vBuf := make([]byte, vlq.MaxLen64)
+	l := packVaruint(uint(len(spentOutputs)), vBuf)
+	buf := append([]byte(nil), vBuf[:l]...)
+	for _, so := range spentOutputs {
+		buf = append(buf, so.btxID...)
+		l = packVarint(so.vout, vBuf)
+		buf = append(buf, vBuf[:l]...)
+		l = packVarint(int32(len(so.addrID)), vBuf)
+		buf = append(buf, vBuf[:l]...)
+		buf = append(buf, so.addrID...)
+		buf = append(buf, packBigint(so.valueSat)...)
+	}
+	return buf
+}
+
+func (d *RocksDB) unpackBlockUndo(buf []byte) ([]spentOutput, error) {
+	txidUnpackedLen := d.chainParser.PackedTxidLen()
+	n, p := unpackVaruint(buf)
+	spentOutputs := make([]spentOutput, n)
+	for i := uint(0); i < n; i++ {
+		if p+txidUnpackedLen >= len(buf) {
+			return nil, errors.New("Inconsistent data in blockundo")
+		}
+		btxID := append([]byte(nil), buf[p:p+txidUnpackedLen]...)
+		p += txidUnpackedLen
+		vout, l := unpackVarint(buf[p:])
+		p += l
+		if p >= len(buf) {
+			return nil, errors.New("Inconsistent data in blockundo")
+		}
+		al, l := unpackVarint(buf[p:])
+		p += l
+		if p+int(al) >= len(buf) || p+int(al)+int(buf[p+int(al)]) >= len(buf) {
+			return nil, errors.New("Inconsistent data in blockundo")
+		}
+		addrID := append([]byte(nil), buf[p:p+int(al)]...)
+		p += int(al)
+		valueSat, l := unpackBigint(buf[p:])
+		p += l
+		spentOutputs[i] = spentOutput{btxID, vout, addrID, &valueSat}
+	}
+	return spentOutputs, nil
+}
+
+func (d *RocksDB) getBlockUndo(key []byte) ([]spentOutput, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfBlockUndo], key)
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	// undo data is missing in DB
+	if val.Size() == 0 {
+		return nil, errors.New("Block undo data missing")
+	}
+	return d.unpackBlockUndo(val.Data())
+}
+
+// Spending transactions
+
+// SpendingTx is the input of a transaction which spends an output
+type SpendingTx struct {
+	Txid   string
+	Vin    uint32
+	Height uint32
+}
+
+// packSpendingTx packs the spending input as btxID, vin (varuint) and height (varuint)
+func (d *RocksDB) packSpendingTx(btxID []byte, vin uint32, height uint32) []byte {
+	buf := make([]byte, len(btxID)+2*vlq.MaxLen32)
+	copy(buf, btxID)
+	l := len(btxID)
+	l += packVaruint(uint(vin), buf[l:])
+	l += packVaruint(uint(height), buf[l:])
+	return buf[:l]
+}
+
+func (d *RocksDB) unpackSpendingTx(buf []byte) (*SpendingTx, error) {
+	txidUnpackedLen := d.chainParser.PackedTxidLen()
+	if len(buf) < txidUnpackedLen+2 {
+		return nil, errors.New("Inconsistent data in spendingtxs")
+	}
+	txid, err := d.chainParser.UnpackTxid(buf[:txidUnpackedLen])
+	if err != nil {
+		return nil, err
+	}
+	p := txidUnpackedLen
+	vin, l := unpackVaruint(buf[p:])
+	p += l
+	if p >= len(buf) {
+		return nil, errors.New("Inconsistent data in spendingtxs")
+	}
+	height, _ := unpackVaruint(buf[p:])
+	return &SpendingTx{Txid: txid, Vin: uint32(vin), Height: uint32(height)}, nil
+}
+
+// GetSpendingTx returns the input which spends the output vout of the transaction txid
+// or nil if the output is not spent by any transaction in a block
+func (d *RocksDB) GetSpendingTx(txid string, vout uint32) (*SpendingTx, error) {
+	btxID, err := d.chainParser.PackTxid(txid)
+	if err != nil {
+		return nil, err
+	}
+	val, err := d.db.GetCF(d.ro, d.cfh[cfSpendingTxs], []byte(outpointKey(btxID, int32(vout))))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	if val.Size() == 0 {
+		return nil, nil
+	}
+	return d.unpackSpendingTx(val.Data())
+}
+
+// Address balance
+
+// AddrBalance contains number of transactions and amounts of an address
+// the amounts are kept only for UTXO chains, they are zero for other chains
+type AddrBalance struct {
+	Txs        uint32
+	SentSat    big.Int
+	BalanceSat big.Int
+}
+
+// ReceivedSat computes the total received amount from the balance and the sent amount
+func (ab *AddrBalance) ReceivedSat() *big.Int {
+	var r big.Int
+	r.Add(&ab.BalanceSat, &ab.SentSat)
+	return &r
+}
+
+func (ab *AddrBalance) applyDelta(txs int, bd *addrBalanceDelta, op int) {
+	switch op {
+	case opInsert:
+		ab.Txs += uint32(txs)
+		if bd != nil {
+			ab.SentSat.Add(&ab.SentSat, &bd.sentSat)
+			ab.BalanceSat.Add(&ab.BalanceSat, &bd.receivedSat)
+			ab.BalanceSat.Sub(&ab.BalanceSat, &bd.sentSat)
+		}
+	case opDelete:
+		if ab.Txs > uint32(txs) {
+			ab.Txs -= uint32(txs)
+		} else {
+			ab.Txs = 0
+		}
+		if bd != nil {
+			ab.SentSat.Sub(&ab.SentSat, &bd.sentSat)
+			ab.BalanceSat.Sub(&ab.BalanceSat, &bd.receivedSat)
+			ab.BalanceSat.Add(&ab.BalanceSat, &bd.sentSat)
+		}
+	}
+}
+
+// GetAddressBalance returns the balance of an address or nil if the address is not found
+func (d *RocksDB) GetAddressBalance(address string) (*AddrBalance, error) {
+	addrID, err := d.chainParser.GetAddrIDFromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return d.GetAddrIDBalance(addrID)
+}
+
+// GetAddrIDBalance returns the balance of an addrID or nil if the addrID is not found
+func (d *RocksDB) GetAddrIDBalance(addrID []byte) (*AddrBalance, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfAddressBalance], addrID)
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	buf := val.Data()
+	// address is not in the db
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return unpackAddrBalance(buf)
+}
+
+// getAddrBalanceForUpdate returns the balance of addrID from the balances map, loading it from db if necessary
+func (d *RocksDB) getAddrBalanceForUpdate(balances map[string]*AddrBalance, addrID []byte) (*AddrBalance, error) {
+	strAddrID := string(addrID)
+	ab, exists := balances[strAddrID]
+	if exists {
+		return ab, nil
+	}
+	ab, err := d.GetAddrIDBalance(addrID)
+	if err != nil {
+		return nil, err
+	}
+	if ab == nil {
+		ab = &AddrBalance{}
+	}
+	balances[strAddrID] = ab
+	return ab, nil
+}
+
+func (d *RocksDB) writeAddrBalances(wb *gorocksdb.WriteBatch, balances map[string]*AddrBalance) {
+	for addrID, ab := range balances {
+		// address without transactions is removed from the column
+		if ab.Txs == 0 {
+			wb.DeleteCF(d.cfh[cfAddressBalance], []byte(addrID))
+		} else {
+			wb.PutCF(d.cfh[cfAddressBalance], []byte(addrID), packAddrBalance(ab))
+		}
+	}
+}
+
+// countTxs returns number of distinct transactions in outpoints
+func countTxs(outpoints []outpoint) int {
+	txs := make(map[string]struct{})
+	for _, o := range outpoints {
+		txs[string(o.btxID)] = struct{}{}
+	}
+	return len(txs)
+}
+
+func packAddrBalance(ab *AddrBalance) []byte {
+	buf := make([]byte, vlq.MaxLen32)
+	l := packVaruint(uint(ab.Txs), buf)
+	buf = buf[:l]
+	buf = append(buf, packBigint(&ab.SentSat)...)
+	buf = append(buf, packBigint(&ab.BalanceSat)...)
+	return buf
+}
+
+func unpackAddrBalance(buf []byte) (*AddrBalance, error) {
+	txs, l := unpackVaruint(buf)
+	if l >= len(buf) || l+int(buf[l]) >= len(buf) {
+		return nil, errors.New("Inconsistent data in addressbalance")
+	}
+	ab := &AddrBalance{Txs: uint32(txs)}
+	sentSat, ll := unpackBigint(buf[l:])
+	l += ll
+	if l+int(buf[l]) >= len(buf) {
+		return nil, errors.New("Inconsistent data in addressbalance")
+	}
+	balanceSat, _ := unpackBigint(buf[l:])
+	ab.SentSat = sentSat
+	ab.BalanceSat = balanceSat
+	return ab, nil
+}
+
 // Block index
 
+// BlockInfo holds information about blocks kept in column height
+type BlockInfo struct {
+	Hash   string
+	Time   int64
+	Txs    uint32
+	Size   uint32
+	Height uint32 // Height is not packed!
+}
+
+// packBlockInfo packs the block hash followed by varuint time, number of transactions and size
+func (d *RocksDB) packBlockInfo(block *BlockInfo) ([]byte, error) {
+	packed := make([]byte, 0, packedBlockHashLen+3*vlq.MaxLen64)
+	varBuf := make([]byte, vlq.MaxLen64)
+	b, err := d.chainParser.PackBlockHash(block.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != packedBlockHashLen {
+		return nil, errors.Errorf("Invalid length of packed block hash %v", block.Hash)
+	}
+	packed = append(packed, b...)
+	l := packVaruint(uint(block.Time), varBuf)
+	packed = append(packed, varBuf[:l]...)
+	l = packVaruint(uint(block.Txs), varBuf)
+	packed = append(packed, varBuf[:l]...)
+	l = packVaruint(uint(block.Size), varBuf)
+	packed = append(packed, varBuf[:l]...)
+	return packed, nil
+}
+
+// unpackBlockInfo unpacks the data from the height column
+// records written before dbVersion 3 contain only the block hash, the other fields are zero for them
+// until the records are filled by the migration from version 2, see migrateBlockInfo
+func (d *RocksDB) unpackBlockInfo(buf []byte) (*BlockInfo, error) {
+	if len(buf) < packedBlockHashLen {
+		return nil, errors.New("Inconsistent data in height column")
+	}
+	hash, err := d.chainParser.UnpackBlockHash(buf[:packedBlockHashLen])
+	if err != nil {
+		return nil, err
+	}
+	bi := &BlockInfo{Hash: hash}
+	if len(buf) == packedBlockHashLen {
+		return bi, nil
+	}
+	l := packedBlockHashLen
+	t, ll := unpackVaruint(buf[l:])
+	l += ll
+	if l >= len(buf) {
+		return nil, errors.New("Inconsistent data in height column")
+	}
+	txs, ll := unpackVaruint(buf[l:])
+	l += ll
+	if l >= len(buf) {
+		return nil, errors.New("Inconsistent data in height column")
+	}
+	size, _ := unpackVaruint(buf[l:])
+	bi.Time = int64(t)
+	bi.Txs = uint32(txs)
+	bi.Size = uint32(size)
+	return bi, nil
+}
+
+// isBlockInfoWithoutMetadata returns true if the height column record contains only the block hash
+func isBlockInfoWithoutMetadata(buf []byte) bool {
+	return len(buf) == packedBlockHashLen
+}
+
 // GetBestBlock returns the block hash of the block with highest height in the db
 func (d *RocksDB) GetBestBlock() (uint32, string, error) {
 	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
 	defer it.Close()
 	if it.SeekToLast(); it.Valid() {
 		bestHeight := unpackUint(it.Key().Data())
-		val, err := d.chainParser.UnpackBlockHash(it.Value().Data())
+		info, err := d.unpackBlockInfo(it.Value().Data())
+		if err != nil {
+			return 0, "", err
+		}
 		if glog.V(1) {
-			glog.Infof("rocksdb: bestblock %d %s", bestHeight, val)
+			glog.Infof("rocksdb: bestblock %d %s", bestHeight, info.Hash)
 		}
-		return bestHeight, val, err
+		return bestHeight, info.Hash, nil
 	}
 	return 0, "", nil
 }
 
 // GetBlockHash returns block hash at given height or empty string if not found
 func (d *RocksDB) GetBlockHash(height uint32) (string, error) {
+	info, err := d.GetBlockInfo(height)
+	if err != nil || info == nil {
+		return "", err
+	}
+	return info.Hash, nil
+}
+
+// GetBlockInfo returns block info stored in db, nil if the block is not found
+func (d *RocksDB) GetBlockInfo(height uint32) (*BlockInfo, error) {
 	key := packUint(height)
 	val, err := d.db.GetCF(d.ro, d.cfh[cfHeight], key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer val.Free()
-	return d.chainParser.UnpackBlockHash(val.Data())
+	if len(val.Data()) == 0 {
+		return nil, nil
+	}
+	bi, err := d.unpackBlockInfo(val.Data())
+	if err != nil {
+		return nil, err
+	}
+	bi.Height = height
+	return bi, nil
+}
+
+// getHeightBounds returns the lowest and the highest height stored in the height column
+func (d *RocksDB) getHeightBounds() (uint32, uint32, bool) {
+	it := d.db.NewIteratorCF(d.ro, d.cfh[cfHeight])
+	defer it.Close()
+	if it.SeekToFirst(); !it.Valid() {
+		return 0, 0, false
+	}
+	first := unpackUint(it.Key().Data())
+	if it.SeekToLast(); !it.Valid() {
+		return 0, 0, false
+	}
+	return first, unpackUint(it.Key().Data()), true
+}
+
+// GetHeightForTime returns the height of the first block with time greater than or equal to t
+// or the best height + 1 if there is no such block
+// the block times are not strictly increasing, the height is found by binary search over the stored block times
+func (d *RocksDB) GetHeightForTime(t int64) (uint32, error) {
+	first, best, ok := d.getHeightBounds()
+	if !ok {
+		return 0, nil
+	}
+	lo, hi := first, best+1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		bi, err := d.GetBlockInfo(mid)
+		if err != nil {
+			return 0, err
+		}
+		if bi == nil {
+			return 0, errors.Errorf("Missing block info at height %v", mid)
+		}
+		if bi.Time < t {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// GetHeightRangeForTime converts time range from-to (unix time in seconds, inclusive) to the range of block heights
+// zero from or to means that the range is not bounded from the given side
+// the last returned value is false if there is no block in the time range
+func (d *RocksDB) GetHeightRangeForTime(from, to int64) (uint32, uint32, bool, error) {
+	first, _, ok := d.getHeightBounds()
+	if !ok {
+		return 0, 0, false, nil
+	}
+	lower, higher := uint32(0), ^uint32(0)
+	var err error
+	if from > 0 {
+		if lower, err = d.GetHeightForTime(from); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	if to > 0 {
+		h, err := d.GetHeightForTime(to + 1)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		// all stored blocks are newer than to
+		if h <= first {
+			return 0, 0, false, nil
+		}
+		higher = h - 1
+	}
+	return lower, higher, lower <= higher, nil
 }
 
 func (d *RocksDB) writeHeight(
@@ -649,7 +1406,12 @@ func (d *RocksDB) writeHeight(
 
 	switch op {
 	case opInsert:
-		val, err := d.chainParser.PackBlockHash(block.Hash)
+		val, err := d.packBlockInfo(&BlockInfo{
+			Hash: block.Hash,
+			Time: block.Time,
+			Txs:  uint32(len(block.Txs)),
+			Size: uint32(block.Size),
+		})
 		if err != nil {
 			return err
 		}
@@ -661,28 +1423,43 @@ func (d *RocksDB) writeHeight(
 	return nil
 }
 
-func (d *RocksDB) getBlockAddresses(key []byte) ([][]byte, [][]outpoint, error) {
+func (d *RocksDB) getBlockAddresses(key []byte) ([][]byte, [][]outpoint, []*addrBalanceDelta, error) {
 	b, err := d.db.GetCF(d.ro, d.cfh[cfBlockAddresses], key)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	defer b.Free()
 	// block is missing in DB
 	if b.Data() == nil {
-		return nil, nil, errors.New("Block addresses missing")
+		return nil, nil, nil, errors.New("Block addresses missing")
 	}
 	return d.unpackBlockAddresses(b.Data())
 }
 
+// hasBlockAddresses returns true if the blockaddresses column contains the block at given height
+func (d *RocksDB) hasBlockAddresses(height uint32) (bool, error) {
+	b, err := d.db.GetCF(d.ro, d.cfh[cfBlockAddresses], packUint(height))
+	if err != nil {
+		return false, err
+	}
+	defer b.Free()
+	return b.Size() > 0, nil
+}
+
 func (d *RocksDB) allAddressesScan(lower uint32, higher uint32) ([][]byte, [][]byte, error) {
-	glog.Infof("db: doing full scan of addresses column")
+	return d.heightKeysScan(cfAddresses, lower, higher)
+}
+
+// heightKeysScan does full scan of the column cf with keys ending by height, returns keys and values in range lower-higher
+func (d *RocksDB) heightKeysScan(cf int, lower uint32, higher uint32) ([][]byte, [][]byte, error) {
+	glog.Infof("db: doing full scan of %v column", cfNames[cf])
 	addrKeys := [][]byte{}
 	addrValues := [][]byte{}
 	var totalOutputs, count uint64
 	var seekKey []byte
 	for {
 		var key []byte
-		it := d.db.NewIteratorCF(d.ro, d.cfh[cfAddresses])
+		it := d.db.NewIteratorCF(d.ro, d.cfh[cf])
 		if totalOutputs == 0 {
 			it.SeekToFirst()
 		} else {
@@ -715,27 +1492,39 @@ func (d *RocksDB) allAddressesScan(lower uint32, higher uint32) ([][]byte, [][]b
 			break
 		}
 	}
-	glog.Infof("rocksdb: scanned %d addresses, found %d to disconnect", totalOutputs, len(addrKeys))
+	glog.Infof("rocksdb: scanned %d %v, found %d to disconnect", totalOutputs, cfNames[cf], len(addrKeys))
 	return addrKeys, addrValues, nil
 }
 
 // DisconnectBlockRange removes all data belonging to blocks in range lower-higher
 // it finds the data in blockaddresses column if available,
-// otherwise by doing quite slow full scan of addresses column
+// otherwise by doing quite slow full scan of addresses column, which is possible only for non UTXO chains
+// the spendingtxs records of the outputs without address, which are not in blockaddresses, are removed using the blockundo column
 func (d *RocksDB) DisconnectBlockRange(lower uint32, higher uint32) error {
 	glog.Infof("db: disconnecting blocks %d-%d", lower, higher)
 	addrKeys := [][]byte{}
 	addrOutpoints := [][]byte{}
 	addrUnspentOutpoints := [][]outpoint{}
+	addrDeltas := []*addrBalanceDelta{}
+	var erc20Keys, logKeys, spendingKeys [][]byte
 	keep := d.chainParser.KeepBlockAddresses()
 	var err error
 	if keep > 0 {
 		for height := lower; height <= higher; height++ {
-			addresses, unspentOutpoints, err := d.getBlockAddresses(packUint(height))
+			addresses, unspentOutpoints, deltas, err := d.getBlockAddresses(packUint(height))
 			if err != nil {
 				glog.Error(err)
 				return err
 			}
+			if d.chainParser.IsUTXOChain() {
+				spentOutputs, err := d.getBlockUndo(packUint(height))
+				if err != nil {
+					glog.Warning("rocksdb: height ", height, ": ", err, ", spending records of outputs without address are not removed")
+				}
+				for _, so := range spentOutputs {
+					spendingKeys = append(spendingKeys, []byte(outpointKey(so.btxID, so.vout)))
+				}
+			}
 			for i, addrID := range addresses {
 				addrKey := packAddressKey(addrID, height)
 				val, err := d.db.GetCF(d.ro, d.cfh[cfAddresses], addrKey)
@@ -748,19 +1537,36 @@ func (d *RocksDB) DisconnectBlockRange(lower uint32, higher uint32) error {
 				val.Free()
 				addrOutpoints = append(addrOutpoints, av)
 				addrUnspentOutpoints = append(addrUnspentOutpoints, unspentOutpoints[i])
+				addrDeltas = append(addrDeltas, deltas[i])
 			}
 		}
 	} else {
+		// without blockaddresses, only the number of transactions of the addresses can be reverted,
+		// which is sufficient only for the chains without amounts in balances and without unspent transactions
+		if d.chainParser.IsUTXOChain() {
+			return errors.Errorf("Cannot disconnect blocks %d-%d of UTXO chain without blockaddresses", lower, higher)
+		}
 		addrKeys, addrOutpoints, err = d.allAddressesScan(lower, higher)
 		if err != nil {
 			return err
 		}
+		erc20Keys, _, err = d.heightKeysScan(cfErc20Transfers, lower, higher)
+		if err != nil {
+			return err
+		}
+		if d.chainParser.IndexContractLogs() {
+			logKeys, _, err = d.heightKeysScan(cfContractLogs, lower, higher)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	glog.Infof("rocksdb: about to disconnect %d addresses ", len(addrKeys))
 	wb := gorocksdb.NewWriteBatch()
 	defer wb.Destroy()
 	unspentTxs := make(map[string][]byte)
+	balances := make(map[string]*AddrBalance)
 	for addrIndex, addrKey := range addrKeys {
 		if glog.V(2) {
 			glog.Info("address ", hex.EncodeToString(addrKey))
@@ -771,8 +1577,14 @@ func (d *RocksDB) DisconnectBlockRange(lower uint32, higher uint32) error {
 		if err != nil {
 			return err
 		}
+		var unspentOutpoints []outpoint
+		var bd *addrBalanceDelta
+		if keep > 0 {
+			unspentOutpoints = addrUnspentOutpoints[addrIndex]
+			bd = addrDeltas[addrIndex]
+		}
 		// recreate unspentTxs, which were spent by this block (that is being disconnected)
-		for _, o := range addrUnspentOutpoints[addrIndex] {
+		for _, o := range unspentOutpoints {
 			stxID := string(o.btxID)
 			txAddrs, exists := unspentTxs[stxID]
 			if !exists {
@@ -781,22 +1593,43 @@ func (d *RocksDB) DisconnectBlockRange(lower uint32, higher uint32) error {
 					return err
 				}
 			}
-			txAddrs = appendPackedAddrID(txAddrs, addrID, uint32(o.vout), 1)
+			txAddrs = appendPackedAddrID(txAddrs, addrID, uint32(o.vout), o.valueSat, 1)
 			unspentTxs[stxID] = txAddrs
+			wb.DeleteCF(d.cfh[cfSpendingTxs], []byte(outpointKey(o.btxID, o.vout)))
 		}
 		// delete unspentTxs from this block
 		outpoints, err := d.unpackOutpoints(addrOutpoints[addrIndex])
 		if err != nil {
 			return err
 		}
+		// revert the balance of the address
+		ab, err := d.getAddrBalanceForUpdate(balances, addrID)
+		if err != nil {
+			return err
+		}
+		ab.applyDelta(countTxs(outpoints), bd, opDelete)
 		for _, o := range outpoints {
 			wb.DeleteCF(d.cfh[cfUnspentTxs], o.btxID)
 			d.internalDeleteTx(wb, o.btxID)
+			// outputs created in the disconnected blocks cannot be spent
+			if o.vout >= 0 {
+				wb.DeleteCF(d.cfh[cfSpendingTxs], []byte(outpointKey(o.btxID, o.vout)))
+			}
 		}
 	}
 	for key, val := range unspentTxs {
 		wb.PutCF(d.cfh[cfUnspentTxs], []byte(key), val)
 	}
+	for _, key := range spendingKeys {
+		wb.DeleteCF(d.cfh[cfSpendingTxs], key)
+	}
+	for _, key := range erc20Keys {
+		wb.DeleteCF(d.cfh[cfErc20Transfers], key)
+	}
+	for _, key := range logKeys {
+		wb.DeleteCF(d.cfh[cfContractLogs], key)
+	}
+	d.writeAddrBalances(wb, balances)
 	for height := lower; height <= higher; height++ {
 		if glog.V(2) {
 			glog.Info("height ", height)
@@ -805,6 +1638,7 @@ func (d *RocksDB) DisconnectBlockRange(lower uint32, higher uint32) error {
 		if keep > 0 {
 			wb.DeleteCF(d.cfh[cfBlockAddresses], key)
 		}
+		wb.DeleteCF(d.cfh[cfBlockUndo], key)
 		wb.DeleteCF(d.cfh[cfHeight], key)
 	}
 	err = d.db.Write(d.wo, wb)
@@ -926,15 +1760,26 @@ func (d *RocksDB) LoadInternalState(rpcCoin string) (*common.InternalState, erro
 	}
 	// make sure that column stats match the columns
 	sc := is.DbColumns
+	if len(sc) == 0 {
+		// new db
+		is.DbVersion = dbVersion
+	} else if is.DbVersion == 0 {
+		// the internal state stored by older versions of blockbook contains only the versions of columns
+		is.DbVersion = sc[0].Version
+	}
+	// check the version of the db, if it does not match and cannot be migrated, the db is not compatible
+	if is.DbVersion != dbVersion && !canMigrate(is.DbVersion) {
+		return nil, errors.Errorf("DB version %v does not match the required version %v and cannot be migrated. DB is not compatible.", is.DbVersion, dbVersion)
+	}
 	nc := make([]common.InternalStateColumn, len(cfNames))
 	for i := 0; i < len(nc); i++ {
 		nc[i].Name = cfNames[i]
-		nc[i].Version = dbVersion
+		nc[i].Version = is.DbVersion
 		for j := 0; j < len(sc); j++ {
 			if sc[j].Name == nc[i].Name {
-				// check the version of the column, if it does not match, the db is not compatible
-				if sc[j].Version != dbVersion {
-					return nil, errors.Errorf("DB version %v of column '%v' does not match the required version %v. DB is not compatible.", sc[j].Version, sc[j].Name, dbVersion)
+				// check the version of the column, all columns must have the version of the db
+				if sc[j].Version != is.DbVersion {
+					return nil, errors.Errorf("DB version %v of column '%v' does not match the version %v of the DB. DB is not compatible.", sc[j].Version, sc[j].Name, is.DbVersion)
 				}
 				nc[i].Rows = sc[j].Rows
 				nc[i].KeyBytes = sc[j].KeyBytes
@@ -955,10 +1800,13 @@ func (d *RocksDB) SetInternalState(is *common.InternalState) {
 
 // StoreInternalState stores the internal state to db
 func (d *RocksDB) StoreInternalState(is *common.InternalState) error {
-	for c := 0; c < len(cfNames); c++ {
-		rows, keyBytes, valueBytes := d.is.GetDBColumnStatValues(c)
-		d.metrics.DbColumnRows.With(common.Labels{"column": cfNames[c]}).Set(float64(rows))
-		d.metrics.DbColumnSize.With(common.Labels{"column": cfNames[c]}).Set(float64(keyBytes + valueBytes))
+	// metrics are not set in tests
+	if d.metrics != nil {
+		for c := 0; c < len(cfNames); c++ {
+			rows, keyBytes, valueBytes := d.is.GetDBColumnStatValues(c)
+			d.metrics.DbColumnRows.With(common.Labels{"column": cfNames[c]}).Set(float64(rows))
+			d.metrics.DbColumnSize.With(common.Labels{"column": cfNames[c]}).Set(float64(keyBytes + valueBytes))
+		}
 	}
 	buf, err := is.Pack()
 	if err != nil {
@@ -967,6 +1815,27 @@ func (d *RocksDB) StoreInternalState(is *common.InternalState) error {
 	return d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(internalStateKey), buf)
 }
 
+const mempoolStateKey = "mempoolState"
+
+// LoadMempoolState returns the mempool state stored by StoreMempoolState, nil if not stored
+func (d *RocksDB) LoadMempoolState() ([]byte, error) {
+	val, err := d.db.GetCF(d.ro, d.cfh[cfDefault], []byte(mempoolStateKey))
+	if err != nil {
+		return nil, err
+	}
+	defer val.Free()
+	data := val.Data()
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// StoreMempoolState stores the mempool state so that the mempool does not have to be synchronized from scratch after restart
+func (d *RocksDB) StoreMempoolState(buf []byte) error {
+	return d.db.PutCF(d.wo, d.cfh[cfDefault], []byte(mempoolStateKey), buf)
+}
+
 func (d *RocksDB) computeColumnSize(col int, stopCompute chan os.Signal) (int64, int64, int64, error) {
 	var rows, keysSum, valuesSum int64
 	var seekKey []byte
@@ -1055,3 +1924,39 @@ func unpackVarint(buf []byte) (int32, int) {
 	i, ofs := vlq.Int(buf)
 	return int32(i), ofs
 }
+
+func packVaruint(i uint, buf []byte) int {
+	return vlq.PutUint(buf, uint64(i))
+}
+
+func unpackVaruint(buf []byte) (uint, int) {
+	i, ofs := vlq.Uint(buf)
+	return uint(i), ofs
+}
+
+// maxPackedBigintBytes is the maximum size of packed big.Int, the length is stored in one byte
+const maxPackedBigintBytes = 256
+
+// packBigint packs absolute value of big.Int as one byte length followed by big endian bytes of the value
+// nil is packed as zero
+func packBigint(bi *big.Int) []byte {
+	if bi == nil {
+		return []byte{0}
+	}
+	b := bi.Bytes()
+	if len(b) >= maxPackedBigintBytes {
+		glog.Error("rocksdb: big.Int too large to pack ", bi)
+		return []byte{0}
+	}
+	buf := make([]byte, 0, len(b)+1)
+	buf = append(buf, byte(len(b)))
+	return append(buf, b...)
+}
+
+// unpackBigint unpacks big.Int packed by packBigint, returns the value and number of bytes read
+func unpackBigint(buf []byte) (big.Int, int) {
+	var r big.Int
+	l := int(buf[0]) + 1
+	r.SetBytes(buf[1:l])
+	return r, l
+}
@@ -5,9 +5,11 @@ package db
 import (
 	"blockbook/bchain"
 	"blockbook/bchain/coins/btc"
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"reflect"
 	"sort"
@@ -16,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/juju/errors"
+	"github.com/tecbot/gorocksdb"
 )
 
 // simplified explanation of signed varint packing, used in many index data structures
@@ -119,19 +122,23 @@ func getTestUTXOBlock1(t *testing.T, d *RocksDB) *bchain.Block {
 		BlockHeader: bchain.BlockHeader{
 			Height: 225493,
 			Hash:   "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+			Size:   31839,
+			Time:   1534858021,
 		},
 		Txs: []bchain.Tx{
 			bchain.Tx{
 				Txid: "00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840",
 				Vout: []bchain.Vout{
 					bchain.Vout{
-						N: 0,
+						N:        0,
+						ValueSat: *big.NewInt(100000000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d),
 						},
 					},
 					bchain.Vout{
-						N: 1,
+						N:        1,
+						ValueSat: *big.NewInt(12345),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d),
 						},
@@ -144,19 +151,22 @@ func getTestUTXOBlock1(t *testing.T, d *RocksDB) *bchain.Block {
 				Txid: "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75",
 				Vout: []bchain.Vout{
 					bchain.Vout{
-						N: 0,
+						N:        0,
+						ValueSat: *big.NewInt(1000000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d),
 						},
 					},
 					bchain.Vout{
-						N: 1,
+						N:        1,
+						ValueSat: *big.NewInt(1234567890123),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d),
 						},
 					},
 					bchain.Vout{
-						N: 2,
+						N:        2,
+						ValueSat: *big.NewInt(9876),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d),
 						},
@@ -174,6 +184,8 @@ func getTestUTXOBlock2(t *testing.T, d *RocksDB) *bchain.Block {
 		BlockHeader: bchain.BlockHeader{
 			Height: 225494,
 			Hash:   "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6",
+			Size:   2345,
+			Time:   1534859123,
 		},
 		Txs: []bchain.Tx{
 			bchain.Tx{
@@ -190,13 +202,15 @@ func getTestUTXOBlock2(t *testing.T, d *RocksDB) *bchain.Block {
 				},
 				Vout: []bchain.Vout{
 					bchain.Vout{
-						N: 0,
+						N:        0,
+						ValueSat: *big.NewInt(98000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mzB8cYrfRwFRFAGTDzV8LkUQy5BQicxGhX", t, d),
 						},
 					},
 					bchain.Vout{
-						N: 1,
+						N:        1,
+						ValueSat: *big.NewInt(7000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL", t, d),
 						},
@@ -221,13 +235,15 @@ func getTestUTXOBlock2(t *testing.T, d *RocksDB) *bchain.Block {
 				},
 				Vout: []bchain.Vout{
 					bchain.Vout{
-						N: 0,
+						N:        0,
+						ValueSat: *big.NewInt(1200000000000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mwwoKQE5Lb1G4picHSHDQKg8jw424PF9SC", t, d),
 						},
 					},
 					bchain.Vout{
-						N: 1,
+						N:        1,
+						ValueSat: *big.NewInt(34567800000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP", t, d),
 						},
@@ -247,7 +263,8 @@ func getTestUTXOBlock2(t *testing.T, d *RocksDB) *bchain.Block {
 				},
 				Vout: []bchain.Vout{
 					bchain.Vout{
-						N: 0,
+						N:        0,
+						ValueSat: *big.NewInt(9000),
 						ScriptPubKey: bchain.ScriptPubKey{
 							Hex: addressToPubKeyHex("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d),
 						},
@@ -262,7 +279,7 @@ func getTestUTXOBlock2(t *testing.T, d *RocksDB) *bchain.Block {
 
 func verifyAfterUTXOBlock1(t *testing.T, d *RocksDB, noBlockAddresses bool) {
 	if err := checkColumn(d, cfHeight, []keyPair{
-		keyPair{"000370d5", "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997", nil},
+		keyPair{"000370d5", "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997" + "85dbf0a625" + "02" + "81f85f", nil},
 	}); err != nil {
 		{
 			t.Fatal(err)
@@ -285,8 +302,8 @@ func verifyAfterUTXOBlock1(t *testing.T, d *RocksDB, noBlockAddresses bool) {
 			"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", "",
 			func(v string) bool {
 				return compareFuncBlockAddresses(t, v, []string{
-					addressToPubKeyHexWithLength("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d) + "00",
-					addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "02",
+					addressToPubKeyHexWithLength("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d) + "00" + "0405f5e100",
+					addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "02" + "023039",
 				})
 			},
 		},
@@ -294,9 +311,9 @@ func verifyAfterUTXOBlock1(t *testing.T, d *RocksDB, noBlockAddresses bool) {
 			"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", "",
 			func(v string) bool {
 				return compareFuncBlockAddresses(t, v, []string{
-					addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "00",
-					addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "02",
-					addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "04",
+					addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "00" + "030f4240",
+					addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "02" + "06011f71fb04cb",
+					addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "04" + "022694",
 				})
 			},
 		},
@@ -315,11 +332,11 @@ func verifyAfterUTXOBlock1(t *testing.T, d *RocksDB, noBlockAddresses bool) {
 			keyPair{"000370d5", "",
 				func(v string) bool {
 					return compareFuncBlockAddresses(t, v, []string{
-						addressToPubKeyHexWithLength("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d) + "00",
-						addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "00",
-						addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "00",
-						addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "00",
-						addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "00",
+						addressToPubKeyHexWithLength("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d) + "00" + "0405f5e100" + "00",
+						addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "00" + "023039" + "00",
+						addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "00" + "030f4240" + "00",
+						addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "00" + "06011f71fb04cb" + "00",
+						addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "00" + "022694" + "00",
 					})
 				},
 			},
@@ -330,12 +347,37 @@ func verifyAfterUTXOBlock1(t *testing.T, d *RocksDB, noBlockAddresses bool) {
 			t.Fatal(err)
 		}
 	}
+	// the balance is packed as number of transactions (varuint), sent and balance amounts (packed bigints)
+	if err := checkColumn(d, cfAddressBalance, []keyPair{
+		keyPair{addressToPubKeyHex("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d), "01" + "00" + "0405f5e100", nil},
+		keyPair{addressToPubKeyHex("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d), "01" + "00" + "023039", nil},
+		keyPair{addressToPubKeyHex("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d), "01" + "00" + "030f4240", nil},
+		keyPair{addressToPubKeyHex("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d), "01" + "00" + "06011f71fb04cb", nil},
+		keyPair{addressToPubKeyHex("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d), "01" + "00" + "022694", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+	// the undo data are packed as number of spent outputs followed by btxID vout addrID value of each output
+	if err := checkColumn(d, cfBlockUndo, []keyPair{
+		keyPair{"000370d5", "00", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+	if err := checkColumn(d, cfSpendingTxs, []keyPair{}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
 }
 
 func verifyAfterUTXOBlock2(t *testing.T, d *RocksDB) {
 	if err := checkColumn(d, cfHeight, []keyPair{
-		keyPair{"000370d5", "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997", nil},
-		keyPair{"000370d6", "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6", nil},
+		keyPair{"000370d5", "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997" + "85dbf0a625" + "02" + "81f85f", nil},
+		keyPair{"000370d6", "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6" + "85dbf0ae73" + "03" + "9229", nil},
 	}); err != nil {
 		{
 			t.Fatal(err)
@@ -363,26 +405,26 @@ func verifyAfterUTXOBlock2(t *testing.T, d *RocksDB) {
 	if err := checkColumn(d, cfUnspentTxs, []keyPair{
 		keyPair{
 			"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840",
-			addressToPubKeyHexWithLength("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d) + "00",
+			addressToPubKeyHexWithLength("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d) + "00" + "0405f5e100",
 			nil,
 		},
 		keyPair{
 			"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25",
-			addressToPubKeyHexWithLength("mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL", t, d) + "02",
+			addressToPubKeyHexWithLength("mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL", t, d) + "02" + "021b58",
 			nil,
 		},
 		keyPair{
 			"3d90d15ed026dc45e19ffb52875ed18fa9e8012ad123d7f7212176e2b0ebdb71", "",
 			func(v string) bool {
 				return compareFuncBlockAddresses(t, v, []string{
-					addressToPubKeyHexWithLength("mwwoKQE5Lb1G4picHSHDQKg8jw424PF9SC", t, d) + "00",
-					addressToPubKeyHexWithLength("mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP", t, d) + "02",
+					addressToPubKeyHexWithLength("mwwoKQE5Lb1G4picHSHDQKg8jw424PF9SC", t, d) + "00" + "0601176592e000",
+					addressToPubKeyHexWithLength("mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP", t, d) + "02" + "05080c66c4c0",
 				})
 			},
 		},
 		keyPair{
 			"05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07",
-			addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "00",
+			addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "00" + "022328",
 			nil,
 		},
 	}); err != nil {
@@ -394,14 +436,14 @@ func verifyAfterUTXOBlock2(t *testing.T, d *RocksDB) {
 		keyPair{"000370d6", "",
 			func(v string) bool {
 				return compareFuncBlockAddresses(t, v, []string{
-					addressToPubKeyHexWithLength("mzB8cYrfRwFRFAGTDzV8LkUQy5BQicxGhX", t, d) + "00",
-					addressToPubKeyHexWithLength("mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL", t, d) + "00",
-					addressToPubKeyHexWithLength("mwwoKQE5Lb1G4picHSHDQKg8jw424PF9SC", t, d) + "00",
-					addressToPubKeyHexWithLength("mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP", t, d) + "00",
-					addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "02" + "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "00",
-					addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "02" + "00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840" + "02",
-					addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "02" + "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "02",
-					addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "02" + "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "04",
+					addressToPubKeyHexWithLength("mzB8cYrfRwFRFAGTDzV8LkUQy5BQicxGhX", t, d) + "03017ed0" + "03017ed0" + "00",
+					addressToPubKeyHexWithLength("mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL", t, d) + "00" + "021b58" + "00",
+					addressToPubKeyHexWithLength("mwwoKQE5Lb1G4picHSHDQKg8jw424PF9SC", t, d) + "00" + "0601176592e000" + "00",
+					addressToPubKeyHexWithLength("mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP", t, d) + "00" + "05080c66c4c0" + "00",
+					addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "030f4240" + "00" + "02" + "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "00" + "030f4240",
+					addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "023039" + "00" + "02" + "00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840" + "02" + "023039",
+					addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "06011f71fb04cb" + "00" + "02" + "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "02" + "06011f71fb04cb",
+					addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "022694" + "022328" + "02" + "effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "04" + "022694",
 				})
 			},
 		},
@@ -410,6 +452,48 @@ func verifyAfterUTXOBlock2(t *testing.T, d *RocksDB) {
 			t.Fatal(err)
 		}
 	}
+	// the balance is packed as number of transactions (varuint), sent and balance amounts (packed bigints)
+	if err := checkColumn(d, cfAddressBalance, []keyPair{
+		keyPair{addressToPubKeyHex("mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", t, d), "01" + "00" + "0405f5e100", nil},
+		keyPair{addressToPubKeyHex("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d), "02" + "023039" + "00", nil},
+		keyPair{addressToPubKeyHex("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d), "02" + "030f4240" + "00", nil},
+		keyPair{addressToPubKeyHex("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d), "02" + "06011f71fb04cb" + "00", nil},
+		keyPair{addressToPubKeyHex("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d), "02" + "022694" + "022328", nil},
+		keyPair{addressToPubKeyHex("mzB8cYrfRwFRFAGTDzV8LkUQy5BQicxGhX", t, d), "02" + "03017ed0" + "00", nil},
+		keyPair{addressToPubKeyHex("mtR97eM2HPWVM6c8FGLGcukgaHHQv7THoL", t, d), "01" + "00" + "021b58", nil},
+		keyPair{addressToPubKeyHex("mwwoKQE5Lb1G4picHSHDQKg8jw424PF9SC", t, d), "01" + "00" + "0601176592e000", nil},
+		keyPair{addressToPubKeyHex("mmJx9Y8ayz9h14yd9fgCW1bUKoEpkBAquP", t, d), "01" + "00" + "05080c66c4c0", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+	if err := checkColumn(d, cfBlockUndo, []keyPair{
+		keyPair{"000370d5", "00", nil},
+		keyPair{"000370d6", "05" +
+			"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "00" + addressToPubKeyHexWithLength("mv9uLThosiEnGRbVPS7Vhyw6VssbVRsiAw", t, d) + "030f4240" +
+			"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840" + "02" + addressToPubKeyHexWithLength("mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", t, d) + "023039" +
+			// the output created in the same block is stored without address and value
+			"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25" + "00" + "00" + "00" +
+			"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "02" + addressToPubKeyHexWithLength("2Mz1CYoppGGsLNUGF2YDhTif6J661JitALS", t, d) + "06011f71fb04cb" +
+			"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "04" + addressToPubKeyHexWithLength("2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", t, d) + "022694", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+	// the spent outpoint (btxID, vout) is mapped to the spending btxID, vin (varuint) and height (varuint)
+	if err := checkColumn(d, cfSpendingTxs, []keyPair{
+		keyPair{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "00", "7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25" + "00" + "8de156", nil},
+		keyPair{"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840" + "02", "7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25" + "01" + "8de156", nil},
+		keyPair{"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25" + "00", "3d90d15ed026dc45e19ffb52875ed18fa9e8012ad123d7f7212176e2b0ebdb71" + "00" + "8de156", nil},
+		keyPair{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "02", "3d90d15ed026dc45e19ffb52875ed18fa9e8012ad123d7f7212176e2b0ebdb71" + "01" + "8de156", nil},
+		keyPair{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75" + "04", "05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07" + "00" + "8de156", nil},
+	}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
 }
 
 type txidVoutOutput struct {
@@ -434,6 +518,16 @@ func verifyGetTransactions(t *testing.T, d *RocksDB, addr string, low, high uint
 	}
 }
 
+func verifyGetAddressUtxos(t *testing.T, d *RocksDB, addr string, want []Utxo) {
+	got, err := d.GetAddressUtxos(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAddressUtxos() = %+v, want %+v", got, want)
+	}
+}
+
 type testBitcoinParser struct {
 	*btc.BitcoinParser
 }
@@ -517,6 +611,15 @@ func TestRocksDB_Index_UTXO(t *testing.T) {
 	}, nil)
 	verifyGetTransactions(t, d, "mtGXQvBowMkBpnhLckhxhbwYK44Gs9eBad", 500000, 1000000, []txidVoutOutput{}, errors.New("checksum mismatch"))
 
+	// get unspent outputs of addresses
+	verifyGetAddressUtxos(t, d, "mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti", []Utxo{
+		Utxo{"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", 0, 225493, *big.NewInt(100000000)},
+	})
+	verifyGetAddressUtxos(t, d, "2NEVv9LJmAnY99W1pFoc5UJjVdypBqdnvu1", []Utxo{
+		Utxo{"05e2e48aeabdd9b75def7b48d756ba304713c2aba7b522bf9dbc893fc4231b07", 0, 225494, *big.NewInt(9000)},
+	})
+	verifyGetAddressUtxos(t, d, "mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz", []Utxo{})
+
 	// GetBestBlock
 	height, hash, err := d.GetBestBlock()
 	if err != nil {
@@ -538,6 +641,53 @@ func TestRocksDB_Index_UTXO(t *testing.T) {
 		t.Fatalf("GetBlockHash: got hash %v, expected %v", hash, "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997")
 	}
 
+	// GetBlockInfo
+	info, err := d.GetBlockInfo(225494)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iw := &BlockInfo{
+		Hash:   "00000000eb0443fd7dc4a1ed5c686a8e995057805f9a161d9a5a77a95e72b7b6",
+		Time:   1534859123,
+		Txs:    3,
+		Size:   2345,
+		Height: 225494,
+	}
+	if !reflect.DeepEqual(info, iw) {
+		t.Fatalf("GetBlockInfo() = %+v, want %+v", info, iw)
+	}
+	info, err = d.GetBlockInfo(225495)
+	if err != nil || info != nil {
+		t.Fatalf("GetBlockInfo() = %+v, %v, want nil", info, err)
+	}
+
+	// GetSpendingTx
+	stx, err := d.GetSpendingTx("effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := &SpendingTx{Txid: "3d90d15ed026dc45e19ffb52875ed18fa9e8012ad123d7f7212176e2b0ebdb71", Vin: 1, Height: 225494}
+	if !reflect.DeepEqual(stx, sw) {
+		t.Fatalf("GetSpendingTx() = %+v, want %+v", stx, sw)
+	}
+	stx, err = d.GetSpendingTx("00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", 0)
+	if err != nil || stx != nil {
+		t.Fatalf("GetSpendingTx() = %+v, %v, want nil", stx, err)
+	}
+
+	// GetHeightForTime, GetHeightRangeForTime
+	verifyGetHeightForTime(t, d, 0, 225493)
+	verifyGetHeightForTime(t, d, 1534858021, 225493)
+	verifyGetHeightForTime(t, d, 1534858022, 225494)
+	verifyGetHeightForTime(t, d, 1534859123, 225494)
+	verifyGetHeightForTime(t, d, 1534859124, 225495)
+	verifyGetHeightRangeForTime(t, d, 0, 0, 0, ^uint32(0), true)
+	verifyGetHeightRangeForTime(t, d, 1534858022, 0, 225494, ^uint32(0), true)
+	verifyGetHeightRangeForTime(t, d, 0, 1534858021, 0, 225493, true)
+	verifyGetHeightRangeForTime(t, d, 1534858021, 1534859123, 225493, 225494, true)
+	verifyGetHeightRangeForTime(t, d, 0, 1534858020, 0, 0, false)
+	verifyGetHeightRangeForTime(t, d, 1534858022, 1534859000, 0, 0, false)
+
 	// Test tx caching functionality, leave one tx in db to test cleanup in DisconnectBlock
 	testTxCache(t, d, block1, &block1.Txs[0])
 	testTxCache(t, d, block2, &block2.Txs[0])
@@ -554,12 +704,34 @@ func TestRocksDB_Index_UTXO(t *testing.T) {
 		}
 	}
 
-	// DisconnectBlock for UTXO chains is not possible
+	// disconnect the 2nd block using the undo data, verify that the db contains only data from the 1st block
+	// with restored unspentTxs and that the cached tx is removed
 	err = d.DisconnectBlock(block2)
-	if err == nil || err.Error() != "DisconnectBlock is not supported for UTXO chains" {
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyAfterUTXOBlock1(t, d, true)
+	if err := checkColumn(d, cfTransactions, []keyPair{}); err != nil {
+		{
+			t.Fatal(err)
+		}
+	}
+
+	// connect the 2nd block again with an input spending an output without address and cache one of its txs,
+	// the spending record of the output without address is not in blockaddresses, it is removed using the undo data
+	spentWithoutAddress := "fdd824a780cbb718eeb766eb05d83fdefc793a27082cd5e67f856d69798cf7db"
+	block2WithInput := *block2
+	block2WithInput.Txs = append([]bchain.Tx(nil), block2.Txs...)
+	block2WithInput.Txs[0].Vin = append(append([]bchain.Vin(nil), block2.Txs[0].Vin...), bchain.Vin{Txid: spentWithoutAddress, Vout: 1})
+	if err := d.ConnectBlock(&block2WithInput); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err := d.GetSpendingTx(spentWithoutAddress, 1); err != nil || tx == nil || tx.Txid != block2.Txs[0].Txid {
+		t.Fatalf("GetSpendingTx() = %+v, %v, want spending tx %v", tx, err, block2.Txs[0].Txid)
+	}
+	if err = d.PutTx(&block2.Txs[1], block2.Height, block2.Txs[1].Blocktime); err != nil {
 		t.Fatal(err)
 	}
-	verifyAfterUTXOBlock2(t, d)
 
 	// disconnect the 2nd block, verify that the db contains only data from the 1st block with restored unspentTxs
 	// and that the cached tx is removed
@@ -575,6 +747,15 @@ func TestRocksDB_Index_UTXO(t *testing.T) {
 		}
 	}
 
+	// reconnect the 2nd block, without blockaddresses the blocks cannot be disconnected by full scan
+	if err := d.ConnectBlock(block2); err != nil {
+		t.Fatal(err)
+	}
+	d.chainParser.(*testBitcoinParser).BaseParser.BlockAddressesToKeep = 0
+	if err = d.DisconnectBlockRange(225493, 225494); err == nil {
+		t.Fatal("DisconnectBlockRange() without blockaddresses expected error")
+	}
+	verifyAfterUTXOBlock2(t, d)
 }
 
 func Test_findAndRemoveUnspentAddr(t *testing.T) {
@@ -586,34 +767,38 @@ func Test_findAndRemoveUnspentAddr(t *testing.T) {
 		name  string
 		args  args
 		want  string
+		want1 string
 		want2 string
 	}{
 		{
 			name: "3",
 			args: args{
-				unspentAddrs: "029c0010517a0115887452870212709393588893935687040e64635167006868060e76519351880087080a7b7b0115870a3276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c08636751680e04578710029112026114",
+				unspentAddrs: "029c000010517a011588745287020101127093935888939356870402012c0e64635167006868060301e2400e7651935188008708030f42400a7b7b0115870a01053276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c0405f5e0ff08636751680e070775f05a074000045787100107029112023039026114020258",
 				vout:         3,
 			},
 			want:  "64635167006868",
-			want2: "029c0010517a0115887452870212709393588893935687040e76519351880087080a7b7b0115870a3276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c08636751680e04578710029112026114",
+			want1: "123456",
+			want2: "029c000010517a011588745287020101127093935888939356870402012c0e7651935188008708030f42400a7b7b0115870a01053276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c0405f5e0ff08636751680e070775f05a074000045787100107029112023039026114020258",
 		},
 		{
 			name: "10",
 			args: args{
-				unspentAddrs: "029c0010517a0115887452870212709393588893935687040e64635167006868060e76519351880087080a7b7b0115870a3276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c08636751680e04578710029112026114",
+				unspentAddrs: "029c000010517a011588745287020101127093935888939356870402012c0e64635167006868060301e2400e7651935188008708030f42400a7b7b0115870a01053276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c0405f5e0ff08636751680e070775f05a074000045787100107029112023039026114020258",
 				vout:         10,
 			},
 			want:  "61",
-			want2: "029c0010517a0115887452870212709393588893935687040e64635167006868060e76519351880087080a7b7b0115870a3276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c08636751680e04578710029112",
+			want1: "600",
+			want2: "029c000010517a011588745287020101127093935888939356870402012c0e64635167006868060301e2400e7651935188008708030f42400a7b7b0115870a01053276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c0405f5e0ff08636751680e070775f05a074000045787100107029112023039",
 		},
 		{
 			name: "not there",
 			args: args{
-				unspentAddrs: "029c0010517a0115887452870212709393588893935687040e64635167006868060e76519351880087080a7b7b0115870a3276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c08636751680e04578710029112026114",
+				unspentAddrs: "029c000010517a011588745287020101127093935888939356870402012c0e64635167006868060301e2400e7651935188008708030f42400a7b7b0115870a01053276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c0405f5e0ff08636751680e070775f05a074000045787100107029112023039026114020258",
 				vout:         11,
 			},
 			want:  "",
-			want2: "029c0010517a0115887452870212709393588893935687040e64635167006868060e76519351880087080a7b7b0115870a3276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c08636751680e04578710029112026114",
+			want1: "",
+			want2: "029c000010517a011588745287020101127093935888939356870402012c0e64635167006868060301e2400e7651935188008708030f42400a7b7b0115870a01053276a9144150837fb91d9461d6b95059842ab85262c2923f88ac0c0405f5e0ff08636751680e070775f05a074000045787100107029112023039026114020258",
 		},
 	}
 	for _, tt := range tests {
@@ -622,11 +807,18 @@ func Test_findAndRemoveUnspentAddr(t *testing.T) {
 			if err != nil {
 				panic(err)
 			}
-			got, got2 := findAndRemoveUnspentAddr(b, tt.args.vout)
+			got, got1, got2 := findAndRemoveUnspentAddr(b, tt.args.vout)
 			h := hex.EncodeToString(got)
 			if !reflect.DeepEqual(h, tt.want) {
 				t.Errorf("findAndRemoveUnspentAddr() got = %v, want %v", h, tt.want)
 			}
+			var v1 string
+			if got1 != nil {
+				v1 = got1.String()
+			}
+			if v1 != tt.want1 {
+				t.Errorf("findAndRemoveUnspentAddr() got1 = %v, want %v", v1, tt.want1)
+			}
 			h2 := hex.EncodeToString(got2)
 			if !reflect.DeepEqual(h2, tt.want2) {
 				t.Errorf("findAndRemoveUnspentAddr() got2 = %v, want %v", h2, tt.want2)
@@ -636,8 +828,14 @@ func Test_findAndRemoveUnspentAddr(t *testing.T) {
 }
 
 type hexoutpoint struct {
-	txID string
-	vout int32
+	txID     string
+	vout     int32
+	valueSat string
+}
+
+type hexdelta struct {
+	sentSat     string
+	receivedSat string
 }
 
 func Test_unpackBlockAddresses(t *testing.T) {
@@ -651,44 +849,61 @@ func Test_unpackBlockAddresses(t *testing.T) {
 		args    args
 		want    []string
 		want2   [][]hexoutpoint
+		want3   []hexdelta
 		wantErr bool
 	}{
 		{
 			name: "1",
-			args: args{"029c0010517a011588745287047c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d250000b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa38400612709393588893935687000e64635167006868000e7651935188008702effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac7502"},
+			args: args{"029c0003017ed00010517a011588745287030f7279021b58047c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d2500030f424000b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa384006023039127093935888939356870000000e646351670068680005080c66c4c0000e7651935188008706011f71fb04cb02232802effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac750206011f71fb04cb"},
 			want: []string{"9c", "517a011588745287", "709393588893935687", "64635167006868", "76519351880087"},
 			want2: [][]hexoutpoint{
 				[]hexoutpoint{},
 				[]hexoutpoint{
-					hexoutpoint{"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25", 0},
-					hexoutpoint{"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", 3},
+					hexoutpoint{"7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25", 0, "1000000"},
+					hexoutpoint{"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", 3, "12345"},
 				},
 				[]hexoutpoint{},
 				[]hexoutpoint{},
 				[]hexoutpoint{
-					hexoutpoint{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 1},
+					hexoutpoint{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 1, "1234567890123"},
 				},
 			},
+			want3: []hexdelta{
+				hexdelta{"0", "98000"},
+				hexdelta{"1012345", "7000"},
+				hexdelta{"0", "0"},
+				hexdelta{"0", "34567800000"},
+				hexdelta{"1234567890123", "9000"},
+			},
 		},
 		{
 			name: "1",
-			args: args{"3276A914B434EB0C1A3B7A02E8A29CC616E791EF1E0BF51F88AC003276A9143F8BA3FDA3BA7B69F5818086E12223C6DD25E3C888AC003276A914A08EAE93007F22668AB5E4A9C83C8CD1C325E3E088AC02EFFD9EF509383D536B1C8AF5BF434C8EFBF521A4F2BEFD4022BBD68694B4AC75003276A9148BDF0AA3C567AA5975C2E61321B8BEBBE7293DF688AC0200B2C06055E5E90E9C82BD4181FDE310104391A7FA4F289B1704E5D90CAA3840022EA9144A21DB08FB6882CB152E1FF06780A430740F77048702EFFD9EF509383D536B1C8AF5BF434C8EFBF521A4F2BEFD4022BBD68694B4AC75023276A914CCAAAF374E1B06CB83118453D102587B4273D09588AC003276A9148D802C045445DF49613F6A70DDD2E48526F3701F88AC00"},
+			args: args{"3276a914b434eb0c1a3b7a02e8a29cc616e791ef1e0bf51f88ac0003017ed0003276a9143f8ba3fda3ba7b69f5818086e12223c6dd25e3c888ac00021b58003276a914a08eae93007f22668ab5e4a9c83c8cd1c325e3e088ac030f42400002effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac7500030f42403276a9148bdf0aa3c567aa5975c2e61321b8bebbe7293df688ac02303905080c66c4c00200b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840020230392ea9144a21db08fb6882cb152e1ff06780a430740f77048706011f71fb04cb02232802effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac750206011f71fb04cb3276a914ccaaaf374e1b06cb83118453d102587b4273d09588ac0000003276a9148d802c045445df49613f6a70ddd2e48526f3701f88ac00011500"},
 			want: []string{"76a914b434eb0c1a3b7a02e8a29cc616e791ef1e0bf51f88ac", "76a9143f8ba3fda3ba7b69f5818086e12223c6dd25e3c888ac", "76a914a08eae93007f22668ab5e4a9c83c8cd1c325e3e088ac", "76a9148bdf0aa3c567aa5975c2e61321b8bebbe7293df688ac", "a9144a21db08fb6882cb152e1ff06780a430740f770487", "76a914ccaaaf374e1b06cb83118453d102587b4273d09588ac", "76a9148d802c045445df49613f6a70ddd2e48526f3701f88ac"},
 			want2: [][]hexoutpoint{
 				[]hexoutpoint{},
 				[]hexoutpoint{},
 				[]hexoutpoint{
-					hexoutpoint{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 0},
+					hexoutpoint{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 0, "1000000"},
 				},
 				[]hexoutpoint{
-					hexoutpoint{"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", 1},
+					hexoutpoint{"00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840", 1, "12345"},
 				},
 				[]hexoutpoint{
-					hexoutpoint{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 1},
+					hexoutpoint{"effd9ef509383d536b1c8af5bf434c8efbf521a4f2befd4022bbd68694b4ac75", 1, "1234567890123"},
 				},
 				[]hexoutpoint{},
 				[]hexoutpoint{},
 			},
+			want3: []hexdelta{
+				hexdelta{"0", "98000"},
+				hexdelta{"0", "7000"},
+				hexdelta{"1000000", "0"},
+				hexdelta{"12345", "34567800000"},
+				hexdelta{"1234567890123", "9000"},
+				hexdelta{"0", "0"},
+				hexdelta{"0", "21"},
+			},
 		},
 	}
 	for _, tt := range tests {
@@ -697,7 +912,7 @@ func Test_unpackBlockAddresses(t *testing.T) {
 			if err != nil {
 				panic(err)
 			}
-			got, got2, err := d.unpackBlockAddresses(b)
+			got, got2, got3, err := d.unpackBlockAddresses(b)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("unpackBlockAddresses() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -713,13 +928,257 @@ func Test_unpackBlockAddresses(t *testing.T) {
 			for i, g := range got2 {
 				ho := make([]hexoutpoint, len(g))
 				for j, o := range g {
-					ho[j] = hexoutpoint{hex.EncodeToString(o.btxID), o.vout}
+					ho[j] = hexoutpoint{hex.EncodeToString(o.btxID), o.vout, o.valueSat.String()}
 				}
 				h2[i] = ho
 			}
 			if !reflect.DeepEqual(h2, tt.want2) {
 				t.Errorf("unpackBlockAddresses() = %v, want %v", h2, tt.want2)
 			}
+			h3 := make([]hexdelta, len(got3))
+			for i, g := range got3 {
+				h3[i] = hexdelta{g.sentSat.String(), g.receivedSat.String()}
+			}
+			if !reflect.DeepEqual(h3, tt.want3) {
+				t.Errorf("unpackBlockAddresses() = %v, want %v", h3, tt.want3)
+			}
+		})
+	}
+}
+
+func verifyGetHeightForTime(t *testing.T, d *RocksDB, time int64, want uint32) {
+	got, err := d.GetHeightForTime(time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("GetHeightForTime(%v) = %v, want %v", time, got, want)
+	}
+}
+
+func verifyGetHeightRangeForTime(t *testing.T, d *RocksDB, from, to int64, wantLower, wantHigher uint32, wantInRange bool) {
+	lower, higher, inRange, err := d.GetHeightRangeForTime(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inRange != wantInRange || (inRange && (lower != wantLower || higher != wantHigher)) {
+		t.Fatalf("GetHeightRangeForTime(%v, %v) = %v, %v, %v, want %v, %v, %v", from, to, lower, higher, inRange, wantLower, wantHigher, wantInRange)
+	}
+}
+
+func Test_unpackBlockInfo(t *testing.T) {
+	d := &RocksDB{chainParser: &testBitcoinParser{BitcoinParser: &btc.BitcoinParser{BaseParser: &bchain.BaseParser{}}}}
+	tests := []struct {
+		name    string
+		hex     string
+		want    *BlockInfo
+		wantErr bool
+	}{
+		{
+			name: "with metadata",
+			hex:  "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997" + "85dbf0a625" + "02" + "81f85f",
+			want: &BlockInfo{
+				Hash: "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+				Time: 1534858021,
+				Txs:  2,
+				Size: 31839,
+			},
+		},
+		{
+			name: "hash only (dbVersion 2)",
+			hex:  "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+			want: &BlockInfo{
+				Hash: "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997",
+			},
+		},
+		{
+			name:    "truncated",
+			hex:     "0000000076fbbed90fd75b0e18856aa35baa984e9c9d444cf746ad85e94e2997" + "85dbf0a625",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := hex.DecodeString(tt.hex)
+			got, err := d.unpackBlockInfo(b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unpackBlockInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unpackBlockInfo() = %+v, want %+v", got, tt.want)
+			}
+			if tt.want != nil {
+				p, err := d.packBlockInfo(tt.want)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !isBlockInfoWithoutMetadata(b) && !bytes.Equal(p, b) {
+					t.Errorf("packBlockInfo() = %v, want %v", hex.EncodeToString(p), tt.hex)
+				}
+			}
+		})
+	}
+}
+
+func Test_packUnpackContractLogs(t *testing.T) {
+	d := &RocksDB{chainParser: &testBitcoinParser{BitcoinParser: &btc.BitcoinParser{BaseParser: &bchain.BaseParser{}}}}
+	hexToBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	entries := []contractLogEntry{
+		{
+			btxID:    hexToBytes("7c3be24063f268aaa1ed81b64776798f56088757641a34fb156c4f51ed2e9d25"),
+			txIndex:  0,
+			logIndex: 1,
+			topics:   [][]byte{},
+		},
+		{
+			btxID:    hexToBytes("00b2c06055e5e90e9c82bd4181fde310104391a7fa4f289b1704e5d90caa3840"),
+			txIndex:  200,
+			logIndex: 300,
+			topics: [][]byte{
+				hexToBytes("0000000000000000000000003e3a3d69dc66ba10737f531ed088954a9ec89d97"),
+				hexToBytes("000000000000000000000000555ee11fbddc0e49a9bab358a8941ad95ffdb48f"),
+			},
+		},
+	}
+	b := d.packContractLogs(entries)
+	got, err := d.unpackContractLogs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("unpackContractLogs() = %+v, want %+v", got, entries)
+	}
+	if _, err = d.unpackContractLogs(b[:len(b)-1]); err == nil {
+		t.Error("unpackContractLogs() expected error for truncated data")
+	}
+}
+
+func TestRocksDB_GetContractLogs(t *testing.T) {
+	d := setupRocksDB(t, &testBitcoinParser{
+		BitcoinParser: &btc.BitcoinParser{
+			BaseParser: &bchain.BaseParser{BlockAddressesToKeep: 1},
+			Params:     btc.GetChainParams("test"),
+		},
+	})
+	defer closeAndDestroyRocksDB(t, d)
+	contract := "mfcWp7DB6NuaZsExybTTXpVgWz559Np4Ti"
+	other := "mtGXQvBowMkBpnhLckhxhbwYK44Gs9eEtz"
+	topic1 := strings.Repeat("11", topicLen)
+	topic2 := strings.Repeat("22", topicLen)
+	topicX := strings.Repeat("aa", topicLen)
+	topicY := strings.Repeat("bb", topicLen)
+	txid := func(i int) string {
+		return strings.Repeat(fmt.Sprintf("%02x", i), 32)
+	}
+	hexToBytes := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	type record struct {
+		contract string
+		topic0   string
+		height   uint32
+		entries  []contractLogEntry
+	}
+	records := []record{
+		{contract, topic1, 10, []contractLogEntry{{btxID: hexToBytes(txid(1)), txIndex: 0, logIndex: 0, topics: [][]byte{hexToBytes(topicX)}}}},
+		{contract, topic2, 10, []contractLogEntry{{btxID: hexToBytes(txid(1)), txIndex: 0, logIndex: 1, topics: [][]byte{}}}},
+		{contract, topic2, 11, []contractLogEntry{{btxID: hexToBytes(txid(2)), txIndex: 1, logIndex: 0, topics: [][]byte{}}}},
+		{contract, topic1, 11, []contractLogEntry{{btxID: hexToBytes(txid(3)), txIndex: 0, logIndex: 0, topics: [][]byte{hexToBytes(topicY)}}}},
+		{contract, topic1, 12, []contractLogEntry{{btxID: hexToBytes(txid(4)), txIndex: 0, logIndex: 0, topics: [][]byte{}}}},
+		{contract, strings.Repeat("00", topicLen), 13, []contractLogEntry{{btxID: hexToBytes(txid(5)), txIndex: 2, logIndex: 3, topics: [][]byte{}}}},
+		{other, topic1, 11, []contractLogEntry{{btxID: hexToBytes(txid(6)), txIndex: 0, logIndex: 0, topics: [][]byte{}}}},
+	}
+	wb := gorocksdb.NewWriteBatch()
+	defer wb.Destroy()
+	for _, r := range records {
+		contractID, err := d.chainParser.GetAddrIDFromAddress(r.contract)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs := map[string][]contractLogEntry{string(contractID) + string(hexToBytes(r.topic0)): r.entries}
+		d.writeContractLogRecords(wb, r.height, opInsert, logs)
+	}
+	if err := d.db.Write(d.wo, wb); err != nil {
+		t.Fatal(err)
+	}
+	ref := func(i int, height uint32, logIndex uint32) ContractLogRef {
+		return ContractLogRef{Txid: txid(i), Height: height, LogIndex: logIndex}
+	}
+	tests := []struct {
+		name   string
+		topics []string
+		lower  uint32
+		higher uint32
+		skip   int
+		count  int
+		want   []ContractLogRef
+	}{
+		{
+			name:   "all logs",
+			higher: ^uint32(0),
+			want:   []ContractLogRef{ref(1, 10, 0), ref(1, 10, 1), ref(3, 11, 0), ref(2, 11, 0), ref(4, 12, 0), ref(5, 13, 3)},
+		},
+		{
+			name:   "range",
+			lower:  11,
+			higher: 12,
+			want:   []ContractLogRef{ref(3, 11, 0), ref(2, 11, 0), ref(4, 12, 0)},
+		},
+		{
+			name:   "empty range",
+			lower:  14,
+			higher: 20,
+			want:   []ContractLogRef{},
+		},
+		{
+			name:   "topic0",
+			topics: []string{topic1},
+			higher: ^uint32(0),
+			want:   []ContractLogRef{ref(1, 10, 0), ref(3, 11, 0), ref(4, 12, 0)},
+		},
+		{
+			name:   "topic0 and range",
+			topics: []string{topic1},
+			lower:  11,
+			higher: 11,
+			want:   []ContractLogRef{ref(3, 11, 0)},
+		},
+		{
+			name:   "topic1",
+			topics: []string{"", topicY},
+			higher: ^uint32(0),
+			want:   []ContractLogRef{ref(3, 11, 0)},
+		},
+		{
+			name:   "page",
+			higher: ^uint32(0),
+			skip:   2,
+			count:  2,
+			want:   []ContractLogRef{ref(3, 11, 0), ref(2, 11, 0)},
+		},
+		{
+			name:   "last page",
+			lower:  10,
+			higher: 13,
+			skip:   5,
+			count:  2,
+			want:   []ContractLogRef{ref(5, 13, 3)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.GetContractLogs(contract, tt.topics, tt.lower, tt.higher, tt.skip, tt.count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetContractLogs() = %+v, want %+v", got, tt.want)
+			}
 		})
 	}
 }
@@ -350,9 +350,17 @@ func (w *SyncWorker) getBlockChain(out chan blockResult, done chan struct{}) {
 // otherwise doing full scan
 func (w *SyncWorker) DisconnectBlocks(lower uint32, higher uint32, hashes []string) error {
 	glog.Infof("sync: disconnecting blocks %d-%d", lower, higher)
-	// if the chain uses Block to Addresses mapping, always use DisconnectBlockRange
-	if w.chain.GetChainParser().KeepBlockAddresses() > 0 {
-		return w.db.DisconnectBlockRange(lower, higher)
+	parser := w.chain.GetChainParser()
+	// if the chain uses Block to Addresses mapping and the blocks are still kept there, use DisconnectBlockRange
+	if parser.KeepBlockAddresses() > 0 {
+		has, err := w.db.hasBlockAddresses(lower)
+		if err != nil {
+			return err
+		}
+		if has {
+			return w.db.DisconnectBlockRange(lower, higher)
+		}
+		glog.Infof("sync: block %d is not in blockaddresses, disconnecting blocks one by one using undo data", lower)
 	}
 	blocks := make([]*bchain.Block, len(hashes))
 	var err error
@@ -360,6 +368,11 @@ func (w *SyncWorker) DisconnectBlocks(lower uint32, higher uint32, hashes []stri
 	for i, hash := range hashes {
 		blocks[i], err = w.chain.GetBlock(hash, 0)
 		if err != nil {
+			// UTXO chains cannot be disconnected by full range scan, the block data are necessary
+			if parser.IsUTXOChain() {
+				glog.Error("sync: cannot get block ", hash, ": ", err)
+				return err
+			}
 			// cannot get a block, we must do full range scan
 			return w.db.DisconnectBlockRange(lower, higher)
 		}
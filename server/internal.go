@@ -41,6 +41,7 @@ type resAboutBlockbookInternal struct {
 	InSyncMempool   bool                         `json:"inSyncMempool"`
 	LastMempoolTime time.Time                    `json:"lastMempoolTime"`
 	MempoolSize     int                          `json:"mempoolSize"`
+	DbVersion       uint32                       `json:"dbVersion"`
 	DbColumns       []common.InternalStateColumn `json:"dbColumns"`
 }
 
@@ -124,6 +125,7 @@ func (s *InternalServer) index(w http.ResponseWriter, r *http.Request) {
 		InSyncMempool:   ms,
 		LastMempoolTime: mt,
 		MempoolSize:     msz,
+		DbVersion:       s.is.DbVersion,
 		DbColumns:       s.is.GetAllDBColumnStats(),
 	}
 	buf, err := json.MarshalIndent(a, "", "    ")
@@ -164,6 +166,8 @@ func (s *InternalServer) getAddress(r *http.Request) (address string, err error)
 	return
 }
 
+// getAddressAndHeightRange returns the address and the height range from the path
+// the range is narrowed by the optional query parameters from and to specifying the time range
 func (s *InternalServer) getAddressAndHeightRange(r *http.Request) (address string, lower, higher uint32, err error) {
 	address, err = s.getAddress(r)
 	if err != nil {
@@ -177,7 +181,26 @@ func (s *InternalServer) getAddressAndHeightRange(r *http.Request) (address stri
 	if err != nil {
 		return
 	}
-	return address, uint32(lower64), uint32(higher64), err
+	lower, higher = uint32(lower64), uint32(higher64)
+	from, to, err := getTimeRange(r)
+	if err != nil || (from == 0 && to == 0) {
+		return
+	}
+	tl, th, inRange, err := s.db.GetHeightRangeForTime(from, to)
+	if err != nil {
+		return
+	}
+	if !inRange {
+		// empty range
+		return address, 1, 0, nil
+	}
+	if tl > lower {
+		lower = tl
+	}
+	if th < higher {
+		higher = th
+	}
+	return
 }
 
 type transactionList struct {
@@ -226,10 +249,13 @@ func (s *InternalServer) transactions(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		respondError(w, err, fmt.Sprint("transactions for address", address))
 	}
-	txs, err := s.chain.GetMempoolTransactions(address)
-	if err != nil {
-		respondError(w, err, fmt.Sprint("transactions for address", address))
+	// mempool transactions belong only to the time range not bounded from above
+	if r.URL.Query().Get("to") == "" {
+		txs, err := s.chain.GetMempoolTransactions(address)
+		if err != nil {
+			respondError(w, err, fmt.Sprint("transactions for address", address))
+		}
+		txList.Txid = append(txList.Txid, txs...)
 	}
-	txList.Txid = append(txList.Txid, txs...)
 	json.NewEncoder(w).Encode(txList)
 }
@@ -35,6 +35,7 @@ type PublicServer struct {
 	is          *common.InternalState
 	txTpl       *template.Template
 	addressTpl  *template.Template
+	blockTpl    *template.Template
 }
 
 // NewPublicServerS creates new public server http interface to blockbook and returns its handle
@@ -82,39 +83,74 @@ func NewPublicServer(binding string, certFiles string, db *db.RocksDB, chain bch
 	// explorer
 	serveMux.HandleFunc(path+"explorer/tx/", s.explorerTx)
 	serveMux.HandleFunc(path+"explorer/address/", s.explorerAddress)
+	serveMux.HandleFunc(path+"explorer/block/", s.explorerBlock)
 	serveMux.Handle(path+"static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static/"))))
 	// API calls
 	serveMux.HandleFunc(path+"api/block-index/", s.apiBlockIndex)
+	serveMux.HandleFunc(path+"api/block/", s.apiBlock)
 	serveMux.HandleFunc(path+"api/tx/", s.apiTx)
 	serveMux.HandleFunc(path+"api/address/", s.apiAddress)
+	serveMux.HandleFunc(path+"api/utxo/", s.apiAddressUtxo)
+	serveMux.HandleFunc(path+"api/xpub/", s.apiXpub)
+	serveMux.HandleFunc(path+"api/export/", s.apiExport)
+	serveMux.HandleFunc(path+"api/tokentransfers/", s.apiTokenTransfers)
+	serveMux.HandleFunc(path+"api/accountstate/", s.apiAccountState)
+	serveMux.HandleFunc(path+"api/logs/", s.apiContractLogs)
+	serveMux.HandleFunc(path+"api/estimatefee/", s.apiEstimateFee)
+	serveMux.HandleFunc(path+"api/mempool", s.apiMempool)
 	// handle socket.io
 	serveMux.Handle(path+"socket.io/", socketio.GetHandler())
 	// default handler
 	serveMux.HandleFunc(path, s.index)
 
-	s.txTpl, s.addressTpl = parseTemplates()
+	s.txTpl, s.addressTpl, s.blockTpl = parseTemplates()
 
 	return s, nil
 }
 
-func parseTemplates() (txTpl, addressTpl *template.Template) {
+func parseTemplates() (txTpl, addressTpl, blockTpl *template.Template) {
 	templateFuncMap := template.FuncMap{
 		"formatUnixTime":      formatUnixTime,
-		"formatAmount":        formatAmount,
 		"setTxToTemplateData": setTxToTemplateData,
 		"stringInSlice":       stringInSlice,
 	}
 	txTpl = template.Must(template.New("tx").Funcs(templateFuncMap).ParseFiles("./static/templates/tx.html", "./static/templates/txdetail.html", "./static/templates/base.html"))
 	addressTpl = template.Must(template.New("address").Funcs(templateFuncMap).ParseFiles("./static/templates/address.html", "./static/templates/txdetail.html", "./static/templates/base.html"))
+	blockTpl = template.Must(template.New("block").Funcs(templateFuncMap).ParseFiles("./static/templates/block.html", "./static/templates/txdetail.html", "./static/templates/base.html"))
 	return
 }
 
-func formatUnixTime(ut int64) string {
-	return time.Unix(ut, 0).Format(time.RFC1123)
+// parseTime parses time given as unix time in seconds or as date in the format YYYY-MM-DD (UTC)
+// if endOfDay is set, the date is parsed as the last second of the day, empty string is parsed as zero
+func parseTime(s string, endOfDay bool) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if t, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, err
+	}
+	if endOfDay {
+		return t.Unix() + 24*60*60 - 1, nil
+	}
+	return t.Unix(), nil
 }
 
-func formatAmount(a float64) string {
-	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%0.8f", a), "0"), ".")
+// getTimeRange returns the time range given by the query parameters from and to, both are inclusive
+func getTimeRange(r *http.Request) (from int64, to int64, err error) {
+	q := r.URL.Query()
+	if from, err = parseTime(q.Get("from"), false); err != nil {
+		return
+	}
+	to, err = parseTime(q.Get("to"), true)
+	return
+}
+
+func formatUnixTime(ut int64) string {
+	return time.Unix(ut, 0).Format(time.RFC1123)
 }
 
 // Run starts the server
@@ -149,6 +185,11 @@ func (s *PublicServer) OnNewTxAddr(txid string, addr string) {
 	s.socketio.OnNewTxAddr(txid, addr)
 }
 
+// OnReplacedTx notifies users subscribed to bitcoind/txreplaced about replaced mempool transaction
+func (s *PublicServer) OnReplacedTx(txid string, replacedBy string) {
+	s.socketio.OnReplacedTx(txid, replacedBy)
+}
+
 func splitBinding(binding string) (addr string, path string) {
 	i := strings.Index(binding, "/")
 	if i >= 0 {
@@ -187,6 +228,9 @@ type TemplateData struct {
 	Address      *api.Address
 	AddrStr      string
 	Tx           *api.Tx
+	Block        *api.Block
+	PrevPage     int
+	NextPage     int
 }
 
 func setTxToTemplateData(td *TemplateData, tx *api.Tx) *TemplateData {
@@ -210,7 +254,7 @@ func (s *PublicServer) explorerTx(w http.ResponseWriter, r *http.Request) {
 
 	// temporarily reread the template on each request
 	// to reflect changes during development
-	s.txTpl, s.addressTpl = parseTemplates()
+	s.txTpl, s.addressTpl, s.blockTpl = parseTemplates()
 
 	data := &TemplateData{
 		CoinName:     s.is.Coin,
@@ -231,7 +275,11 @@ func (s *PublicServer) explorerAddress(w http.ResponseWriter, r *http.Request) {
 			page = 0
 		}
 		addrID := r.URL.Path[i+1:]
-		address, err = s.api.GetAddress(addrID, page)
+		var from, to int64
+		from, to, err = getTimeRange(r)
+		if err == nil {
+			address, err = s.api.GetAddress(addrID, page, from, to)
+		}
 		if err != nil {
 			glog.Error(err)
 		}
@@ -240,7 +288,7 @@ func (s *PublicServer) explorerAddress(w http.ResponseWriter, r *http.Request) {
 
 	// temporarily reread the template on each request
 	// to reflect changes during development
-	s.txTpl, s.addressTpl = parseTemplates()
+	s.txTpl, s.addressTpl, s.blockTpl = parseTemplates()
 
 	data := &TemplateData{
 		CoinName:     s.is.Coin,
@@ -253,6 +301,39 @@ func (s *PublicServer) explorerAddress(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *PublicServer) explorerBlock(w http.ResponseWriter, r *http.Request) {
+	var block *api.Block
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+		if ec != nil {
+			page = 0
+		}
+		block, err = s.api.GetBlock(r.URL.Path[i+1:], page)
+		if err != nil {
+			glog.Error(err)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	// temporarily reread the template on each request
+	// to reflect changes during development
+	s.txTpl, s.addressTpl, s.blockTpl = parseTemplates()
+
+	data := &TemplateData{
+		CoinName:     s.is.Coin,
+		CoinShortcut: s.is.CoinShortcut,
+		Block:        block,
+	}
+	if block != nil {
+		data.PrevPage = block.Page - 1
+		data.NextPage = block.Page + 1
+	}
+	if err := s.blockTpl.ExecuteTemplate(w, "base.html", data); err != nil {
+		glog.Error(err)
+	}
+}
+
 type resAboutBlockbookPublic struct {
 	Coin            string    `json:"coin"`
 	Host            string    `json:"host"`
@@ -322,6 +403,25 @@ func (s *PublicServer) apiBlockIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *PublicServer) apiBlock(w http.ResponseWriter, r *http.Request) {
+	var block *api.Block
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+		if ec != nil {
+			page = 0
+		}
+		block, err = s.api.GetBlock(r.URL.Path[i+1:], page)
+		if err != nil {
+			glog.Error(err)
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(block)
+	}
+}
+
 func (s *PublicServer) apiTx(w http.ResponseWriter, r *http.Request) {
 	var tx *api.Tx
 	var err error
@@ -349,7 +449,11 @@ func (s *PublicServer) apiAddress(w http.ResponseWriter, r *http.Request) {
 			page = 0
 		}
 		addrID := r.URL.Path[i+1:]
-		address, err = s.api.GetAddress(addrID, page)
+		var from, to int64
+		from, to, err = getTimeRange(r)
+		if err == nil {
+			address, err = s.api.GetAddress(addrID, page, from, to)
+		}
 		if err != nil {
 			glog.Error(err)
 		}
@@ -359,3 +463,213 @@ func (s *PublicServer) apiAddress(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(address)
 	}
 }
+
+func (s *PublicServer) apiAddressUtxo(w http.ResponseWriter, r *http.Request) {
+	var utxo []api.AddressUtxo
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		addrID := r.URL.Path[i+1:]
+		utxo, err = s.api.GetAddressUtxo(addrID)
+		if err != nil {
+			glog.Error(err)
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(utxo)
+	}
+}
+
+func (s *PublicServer) apiXpub(w http.ResponseWriter, r *http.Request) {
+	var xpub *api.Xpub
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+		if ec != nil {
+			page = 0
+		}
+		xpub, err = s.api.GetXpub(r.URL.Path[i+1:], page)
+		if err != nil {
+			glog.Error(err)
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(xpub)
+	}
+}
+
+// apiTokenTransfers returns transactions with ERC20 transfers of the token given by the parameter contract from or to the address
+func (s *PublicServer) apiTokenTransfers(w http.ResponseWriter, r *http.Request) {
+	var tt *api.TokenTransfers
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		page, ec := strconv.Atoi(r.URL.Query().Get("page"))
+		if ec != nil {
+			page = 0
+		}
+		tt, err = s.api.GetAddressTokenTransfers(r.URL.Path[i+1:], r.URL.Query().Get("contract"), page)
+		if err != nil {
+			glog.Error(err)
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(tt)
+	}
+}
+
+// apiAccountState returns the balance and the nonce of the address at the best block or at the block given by the parameter height
+func (s *PublicServer) apiAccountState(w http.ResponseWriter, r *http.Request) {
+	var as *api.AccountState
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		height := int64(-1)
+		if h := r.URL.Query().Get("height"); h != "" {
+			if height, err = strconv.ParseInt(h, 10, 64); err != nil || height < 0 {
+				http.Error(w, "Invalid height", http.StatusBadRequest)
+				return
+			}
+		}
+		as, err = s.api.GetAccountState(r.URL.Path[i+1:], height)
+		if err != nil {
+			glog.Error(err)
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(as)
+	}
+}
+
+// apiContractLogs returns the event logs of the contract matching the parameters topic0-topic3 in blocks from-to
+func (s *PublicServer) apiContractLogs(w http.ResponseWriter, r *http.Request) {
+	var cl *api.ContractLogs
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		q := r.URL.Query()
+		var heights [2]uint32
+		for j, p := range []string{"from", "to"} {
+			if h := q.Get(p); h != "" {
+				v, ec := strconv.ParseUint(h, 10, 32)
+				if ec != nil {
+					http.Error(w, "Invalid parameter "+p, http.StatusBadRequest)
+					return
+				}
+				heights[j] = uint32(v)
+			}
+		}
+		topics := []string{q.Get("topic0"), q.Get("topic1"), q.Get("topic2"), q.Get("topic3")}
+		page, ec := strconv.Atoi(q.Get("page"))
+		if ec != nil {
+			page = 0
+		}
+		cl, err = s.api.GetContractLogs(r.URL.Path[i+1:], topics, heights[0], heights[1], page)
+		if err != nil {
+			glog.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(cl)
+	}
+}
+
+// apiEstimateFee returns the slow, standard and fast fee levels for the confirmation within the number of blocks
+func (s *PublicServer) apiEstimateFee(w http.ResponseWriter, r *http.Request) {
+	var fl *api.FeeLevels
+	var err error
+	if i := strings.LastIndexByte(r.URL.Path, '/'); i > 0 {
+		blocks, ec := strconv.Atoi(r.URL.Path[i+1:])
+		if ec != nil || blocks < 1 {
+			http.Error(w, "Invalid number of blocks", http.StatusBadRequest)
+			return
+		}
+		fl, err = s.api.EstimateFeeLevels(blocks)
+		if err != nil {
+			glog.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(fl)
+	}
+}
+
+// apiMempool returns the statistics and the fee rate histogram of the mempool
+func (s *PublicServer) apiMempool(w http.ResponseWriter, r *http.Request) {
+	ms, err := s.api.GetMempoolStats()
+	if err != nil {
+		glog.Error(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(ms)
+}
+
+// exportMaxTxs is the maximal number of transactions exported by one request of the export api
+const exportMaxTxs = 100000
+
+// apiExport streams the history of comma separated addresses in csv (default) or jsonl format
+// the transactions can be restricted by the time range from-to, the height range fromheight-toheight and their number maxtxs (at most exportMaxTxs)
+func (s *PublicServer) apiExport(w http.ResponseWriter, r *http.Request) {
+	i := strings.LastIndexByte(r.URL.Path, '/')
+	if i < 0 || i == len(r.URL.Path)-1 {
+		http.Error(w, "Missing addresses", http.StatusBadRequest)
+		return
+	}
+	addresses := strings.Split(r.URL.Path[i+1:], ",")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = api.ExportFormatCSV
+	}
+	if !api.IsExportFormat(format) {
+		http.Error(w, "Unsupported format", http.StatusBadRequest)
+		return
+	}
+	from, to, err := getTimeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := &api.ExportOptions{FromTime: from, ToTime: to, MaxTxs: exportMaxTxs}
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		v    *uint32
+	}{{"fromheight", &opts.FromHeight}, {"toheight", &opts.ToHeight}} {
+		if h := q.Get(p.name); h != "" {
+			v, ec := strconv.ParseUint(h, 10, 32)
+			if ec != nil {
+				http.Error(w, "Invalid parameter "+p.name, http.StatusBadRequest)
+				return
+			}
+			*p.v = uint32(v)
+		}
+	}
+	if m := q.Get("maxtxs"); m != "" {
+		v, ec := strconv.Atoi(m)
+		if ec != nil || v <= 0 {
+			http.Error(w, "Invalid parameter maxtxs", http.StatusBadRequest)
+			return
+		}
+		if v < opts.MaxTxs {
+			opts.MaxTxs = v
+		}
+	}
+	if format == api.ExportFormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\"export."+format+"\"")
+	// the response is already being sent, the error can be only logged
+	if err = s.api.ExportAddressHistory(addresses, opts, format, w); err != nil {
+		glog.Error("export: ", err)
+	}
+}
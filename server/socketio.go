@@ -6,6 +6,7 @@ import (
 	"blockbook/common"
 	"blockbook/db"
 	"encoding/json"
+	"math/big"
 	"net/http"
 	"strings"
 	"time"
@@ -26,6 +27,7 @@ type SocketIoServer struct {
 	chainParser bchain.BlockChainParser
 	metrics     *common.Metrics
 	is          *common.InternalState
+	api         *api.Worker
 }
 
 // NewSocketIoServer creates new SocketIo interface to blockbook and returns its handle
@@ -50,6 +52,10 @@ func NewSocketIoServer(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCa
 		Name    string `json:"name"`
 		Message string `json:"message"`
 	}
+	api, err := api.NewWorker(db, chain, txCache, is)
+	if err != nil {
+		return nil, err
+	}
 	s := &SocketIoServer{
 		server:      server,
 		db:          db,
@@ -58,6 +64,7 @@ func NewSocketIoServer(db *db.RocksDB, chain bchain.BlockChain, txCache *db.TxCa
 		chainParser: chain.GetChainParser(),
 		metrics:     metrics,
 		is:          is,
+		api:         api,
 	}
 
 	server.On("message", s.onMessage)
@@ -72,11 +79,13 @@ func (s *SocketIoServer) GetHandler() http.Handler {
 }
 
 type addrOpts struct {
-	Start            int  `json:"start"`
-	End              int  `json:"end"`
-	QueryMempoolOnly bool `json:"queryMempoolOnly"`
-	From             int  `json:"from"`
-	To               int  `json:"to"`
+	Start            int   `json:"start"`
+	End              int   `json:"end"`
+	QueryMempoolOnly bool  `json:"queryMempoolOnly"`
+	From             int   `json:"from"`
+	To               int   `json:"to"`
+	FromTime         int64 `json:"fromTime"`
+	ToTime           int64 `json:"toTime"`
 }
 
 var onMessageHandlers = map[string]func(*SocketIoServer, json.RawMessage) (interface{}, error){
@@ -94,6 +103,20 @@ var onMessageHandlers = map[string]func(*SocketIoServer, json.RawMessage) (inter
 		}
 		return
 	},
+	"getAddressUtxo": func(s *SocketIoServer, params json.RawMessage) (rv interface{}, err error) {
+		addr, err := unmarshalGetAddressUtxo(params)
+		if err == nil {
+			rv, err = s.getAddressUtxo(addr)
+		}
+		return
+	},
+	"getXpub": func(s *SocketIoServer, params json.RawMessage) (rv interface{}, err error) {
+		xpub, page, err := unmarshalGetXpub(params)
+		if err == nil {
+			rv, err = s.getXpub(xpub, page)
+		}
+		return
+	},
 	"getBlockHeader": func(s *SocketIoServer, params json.RawMessage) (rv interface{}, err error) {
 		height, hash, err := unmarshalGetBlockHeader(params)
 		if err == nil {
@@ -204,6 +227,28 @@ type resultAddressTxids struct {
 func (s *SocketIoServer) getAddressTxids(addr []string, opts *addrOpts) (res resultAddressTxids, err error) {
 	txids := make([]string, 0)
 	lower, higher := uint32(opts.End), uint32(opts.Start)
+	// the time range narrows the height range, mempool transactions belong only to the range not bounded from above
+	if opts.QueryMempoolOnly {
+		if opts.ToTime != 0 {
+			res.Result = txids
+			return res, nil
+		}
+	} else if opts.FromTime != 0 || opts.ToTime != 0 {
+		tl, th, inRange, err := s.db.GetHeightRangeForTime(opts.FromTime, opts.ToTime)
+		if err != nil {
+			return res, err
+		}
+		if !inRange {
+			res.Result = txids
+			return res, nil
+		}
+		if tl > lower {
+			lower = tl
+		}
+		if th < higher {
+			higher = th
+		}
+	}
 	for _, address := range addr {
 		if !opts.QueryMempoolOnly {
 			err = s.db.GetTransactions(address, lower, higher, func(txid string, vout uint32, isOutput bool) error {
@@ -329,7 +374,7 @@ func (s *SocketIoServer) getAddressHistory(addr []string, opts *addrOpts) (res r
 			for _, vout := range tx.Vout {
 				aoh := vout.ScriptPubKey.Hex
 				ao := txOutputs{
-					Satoshis: int64(vout.Value*1E8 + 0.5),
+					Satoshis: vout.ValueSat.Int64(),
 					Script:   &aoh,
 				}
 				if vout.Address != nil {
@@ -365,6 +410,66 @@ func (s *SocketIoServer) getAddressHistory(addr []string, opts *addrOpts) (res r
 	return
 }
 
+func unmarshalGetAddressUtxo(params []byte) (addr []string, err error) {
+	var p []json.RawMessage
+	err = json.Unmarshal(params, &p)
+	if err != nil {
+		return
+	}
+	if len(p) != 1 {
+		err = errors.New("incorrect number of parameters")
+		return
+	}
+	err = json.Unmarshal(p[0], &addr)
+	return
+}
+
+type resultGetAddressUtxo struct {
+	Result []api.AddressUtxo `json:"result"`
+}
+
+func (s *SocketIoServer) getAddressUtxo(addr []string) (res resultGetAddressUtxo, err error) {
+	res.Result = make([]api.AddressUtxo, 0)
+	for _, address := range addr {
+		utxo, err := s.api.GetAddressUtxo(address)
+		if err != nil {
+			return res, err
+		}
+		res.Result = append(res.Result, utxo...)
+	}
+	return
+}
+
+// unmarshalGetXpub unmarshals params [xpub] or [xpub, page]
+func unmarshalGetXpub(params []byte) (xpub string, page int, err error) {
+	var p []json.RawMessage
+	err = json.Unmarshal(params, &p)
+	if err != nil {
+		return
+	}
+	if len(p) != 1 && len(p) != 2 {
+		err = errors.New("incorrect number of parameters")
+		return
+	}
+	err = json.Unmarshal(p[0], &xpub)
+	if err != nil {
+		return
+	}
+	if len(p) == 2 {
+		err = json.Unmarshal(p[1], &page)
+	}
+	return
+}
+
+type resultGetXpub struct {
+	Result *api.Xpub `json:"result"`
+}
+
+func (s *SocketIoServer) getXpub(xpub string, page int) (res resultGetXpub, err error) {
+	res.Result, err = s.api.GetXpub(xpub, page)
+	return
+}
+
 func unmarshalArray(params []byte, np int) (p []interface{}, err error) {
 	err = json.Unmarshal(params, &p)
 	if err != nil {
@@ -413,13 +518,15 @@ type resultGetBlockHeader struct {
 
 func (s *SocketIoServer) getBlockHeader(height uint32, hash string) (res resultGetBlockHeader, err error) {
 	if hash == "" {
-		// trezor is interested only in hash
-		hash, err = s.db.GetBlockHash(height)
-		if err != nil {
-			return
+		// trezor is interested only in hash, the height and time are available in the db as well
+		bi, err := s.db.GetBlockInfo(height)
+		if err != nil || bi == nil {
+			return res, err
 		}
-		res.Result.Hash = hash
-		return
+		res.Result.Hash = bi.Hash
+		res.Result.Height = int(bi.Height)
+		res.Result.Time = int(bi.Time)
+		return res, nil
 	}
 	bh, err := s.chain.GetBlockHeader(hash)
 	if err != nil {
@@ -452,10 +559,20 @@ func unmarshalEstimateSmartFee(params []byte) (blocks int, conservative bool, er
 }
 
 type resultEstimateSmartFee struct {
-	Result float64 `json:"result"`
+	Result float64        `json:"result"`
+	Levels *api.FeeLevels `json:"levels,omitempty"`
 }
 
+// estimateSmartFee returns the fee estimate of the backend, non UTXO chains return also the slow, standard and fast levels
 func (s *SocketIoServer) estimateSmartFee(blocks int, conservative bool) (res resultEstimateSmartFee, err error) {
+	if !s.chainParser.IsUTXOChain() {
+		res.Levels, err = s.api.EstimateFeeLevels(blocks)
+		if err != nil {
+			return
+		}
+		res.Result, _ = new(big.Float).SetInt(res.Levels.Standard).Float64()
+		return
+	}
 	fee, err := s.chain.EstimateSmartFee(blocks, conservative)
 	if err != nil {
 		return
@@ -588,7 +705,7 @@ func (s *SocketIoServer) getDetailedTransaction(txid string) (res resultGetDetai
 					a := vout.Address.String()
 					ai.Address = &a
 				}
-				ai.Satoshis = int64(vout.Value*1E8 + 0.5)
+				ai.Satoshis = vout.ValueSat.Int64()
 			}
 		}
 		hi = append(hi, ai)
@@ -596,7 +713,7 @@ func (s *SocketIoServer) getDetailedTransaction(txid string) (res resultGetDetai
 	for _, vout := range tx.Vout {
 		aos := vout.ScriptPubKey.Hex
 		ao := txOutputs{
-			Satoshis: int64(vout.Value*1E8 + 0.5),
+			Satoshis: vout.ValueSat.Int64(),
 			Script:   &aos,
 		}
 		if vout.Address != nil {
@@ -641,8 +758,9 @@ func (s *SocketIoServer) getMempoolEntry(txid string) (res resultGetMempoolEntry
 	return
 }
 
-// onSubscribe expects two event subscriptions based on the req parameter (including the doublequotes):
+// onSubscribe expects three event subscriptions based on the req parameter (including the doublequotes):
 // "bitcoind/hashblock"
+// "bitcoind/txreplaced"
 // "bitcoind/addresstxid",["2MzTmvPJLZaLzD9XdN3jMtQA5NexC3rAPww","2NAZRJKr63tSdcTxTN3WaE9ZNDyXy6PgGuv"]
 func (s *SocketIoServer) onSubscribe(c *gosocketio.Channel, req []byte) interface{} {
 	defer func() {
@@ -677,8 +795,8 @@ func (s *SocketIoServer) onSubscribe(c *gosocketio.Channel, req []byte) interfac
 		}
 	} else {
 		sc = r[1 : len(r)-1]
-		if sc != "bitcoind/hashblock" {
-			onError(c.Id(), sc, "invalid data", "expecting bitcoind/hashblock, req: "+r)
+		if sc != "bitcoind/hashblock" && sc != "bitcoind/txreplaced" {
+			onError(c.Id(), sc, "invalid data", "expecting bitcoind/hashblock or bitcoind/txreplaced, req: "+r)
 			return nil
 		}
 		c.Join(sc)
@@ -693,6 +811,14 @@ func (s *SocketIoServer) OnNewBlockHash(hash string) {
 	glog.Info("broadcasting new block hash ", hash, " to ", c, " channels")
 }
 
+// OnReplacedTx notifies users subscribed to bitcoind/txreplaced about mempool transaction replaced by another transaction
+func (s *SocketIoServer) OnReplacedTx(txid string, replacedBy string) {
+	c := s.server.BroadcastTo("bitcoind/txreplaced", "bitcoind/txreplaced", map[string]string{"txid": txid, "replacedBy": replacedBy})
+	if c > 0 {
+		glog.Info("broadcasting replaced txid ", txid, " to ", c, " channels")
+	}
+}
+
 // OnNewTxAddr notifies users subscribed to bitcoind/addresstxid about new block
 func (s *SocketIoServer) OnNewTxAddr(txid string, addr string) {
 	c := s.server.BroadcastTo("bitcoind/addresstxid-"+addr, "bitcoind/addresstxid", map[string]string{"address": addr, "txid": txid})